@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/collector"
+	"github.com/metal-toolbox/alloy/internal/model"
+	"github.com/metal-toolbox/alloy/internal/store/fleetdb"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// diffAssetID is the asset to compare the inventory registered in fleetdb for, or to collect inventory from.
+	diffAssetID string
+
+	// diffCollect when set collects the inventory out of band to be compared.
+	diffCollect bool
+
+	// diffOutput is the diff output format - text, json.
+	diffOutput string
+
+	// diffKind is the inventory kind compared - inband, outofband.
+	diffKind string
+)
+
+// diff command to compare inventories
+var cmdDiff = &cobra.Command{
+	Use:   "diff [before] [after]",
+	Short: "Compare server inventories and print the added, removed and changed components",
+	Long: `Compare server inventories and print the added, removed and changed components, firmware and BIOS configuration.
+
+Inventory documents are files written by --output-stdout or by the csv, file stores.
+
+  alloy diff before.json after.json                                       # compare two documents
+  alloy diff --store fleetdb --asset-id <id> after.json                   # compare the fleetdb inventory with a document
+  alloy diff --store fleetdb --asset-id <id> --collect                    # compare the fleetdb inventory with a live collection
+  alloy diff --store fleetdb --asset-id <id> --collect before.json        # compare a document with a live collection`,
+	Args: cobra.MaximumNArgs(2), // nolint:gomnd // obvious int is obvious
+	Run: func(cmd *cobra.Command, args []string) {
+		alloy, err := app.New(model.AppKind(diffKind), model.StoreKind(storeKind), cfgFile, model.LogLevel(logLevel))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if diffOutput != "text" && diffOutput != "json" {
+			log.Fatal("--output expected to be one of text, json")
+		}
+
+		if diffCollect && len(args) > 1 {
+			log.Fatal("--collect accepts at most one inventory document to compare with")
+		}
+
+		if diffCollect && diffKind != string(model.AppKindOutOfBand) {
+			log.Fatal("--collect collects the outofband inventory, --kind outofband expected")
+		}
+
+		if !diffCollect && len(args) == 0 {
+			log.Fatal("inventory documents to compare or --collect expected")
+		}
+
+		diff, err := runDiff(cmd.Context(), alloy, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if diffOutput == "json" {
+			b, err := json.MarshalIndent(diff, "", " ")
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Println(string(b))
+
+			return
+		}
+
+		if err := diff.WriteText(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func runDiff(ctx context.Context, alloy *app.App, args []string) (*fleetdb.InventoryDiff, error) {
+	// two documents are compared without a store.
+	if len(args) == 2 { // nolint:gomnd // obvious int is obvious
+		before, err := readInventoryDocument(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		after, err := readInventoryDocument(args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return fleetdb.NewOffline(model.AppKind(diffKind), alloy.Logger).DiffInventory(ctx, before, after)
+	}
+
+	var after *model.Asset
+
+	var document *model.Asset
+
+	if len(args) == 1 {
+		var err error
+
+		document, err = readInventoryDocument(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if diffAssetID == "" {
+			diffAssetID = document.ID
+		}
+	}
+
+	if diffAssetID == "" {
+		return nil, errors.New("--asset-id expected")
+	}
+
+	switch {
+	case diffCollect:
+		c, err := collector.NewDeviceCollector(ctx, model.StoreKind(storeKind), model.AppKindOutOfBand, alloy.Config, alloy.Logger)
+		if err != nil {
+			return nil, err
+		}
+
+		// a partial collection would be reported as components, BIOS configuration removed.
+		after = &model.Asset{ID: diffAssetID}
+		if err := c.QueryOutofband(ctx, after); err != nil {
+			return nil, errors.Wrap(err, "collect outofband inventory")
+		}
+
+		// a live collection is compared with the given document.
+		if document != nil {
+			return fleetdb.NewOffline(model.AppKindOutOfBand, alloy.Logger).DiffInventory(ctx, document, after)
+		}
+	default:
+		after = document
+	}
+
+	// the inventory registered in fleetdb is compared with the document or live collection.
+	if model.StoreKind(storeKind) != model.StoreKindFleetDB {
+		return nil, errors.New("--store fleetdb expected to compare with the inventory registered in fleetdb")
+	}
+
+	// the diff is read-only, component types are not created in fleetdb.
+	store, err := fleetdb.NewReadOnly(ctx, model.AppKind(diffKind), alloy.Config.FleetDBAPIOptions, alloy.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	after.ID = diffAssetID
+
+	return store.DiffStoredInventory(ctx, after)
+}
+
+// readInventoryDocument reads an asset inventory written by --output-stdout or an asset snapshot written by the csv, file stores.
+func readInventoryDocument(file string) (*model.Asset, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading inventory document")
+	}
+
+	// --output-stdout documents are model.Asset objects.
+	asset := &model.Asset{}
+	if err := json.Unmarshal(b, asset); err != nil {
+		return nil, errors.Wrap(err, file)
+	}
+
+	// store snapshots are model.AssetSnapshot objects, the field names match apart from the BIOS configuration.
+	snapshot := &model.AssetSnapshot{}
+	if err := json.Unmarshal(b, snapshot); err != nil {
+		return nil, errors.Wrap(err, file)
+	}
+
+	if asset.BiosConfig == nil {
+		asset.BiosConfig = snapshot.BiosConfig
+	}
+
+	if asset.Inventory == nil && asset.BiosConfig == nil {
+		return nil, errors.New(file + ": no inventory or BIOS configuration found")
+	}
+
+	return asset, nil
+}
+
+// install command flags
+func init() {
+	cmdDiff.Flags().StringVar(&diffAssetID, "asset-id", "", "The asset ID to compare the fleetdb inventory for, or collect inventory from.")
+	cmdDiff.Flags().BoolVar(&diffCollect, "collect", false, "Collect the inventory out of band from the asset BMC to compare.")
+	cmdDiff.Flags().StringVar(&diffOutput, "output", "text", "Output format - text, json.")
+	cmdDiff.Flags().StringVar(&diffKind, "kind", string(model.AppKindOutOfBand), "The inventory kind compared with fleetdb - inband, outofband.")
+
+	rootCmd.AddCommand(cmdDiff)
+}
@@ -4,8 +4,10 @@ import (
 	"log"
 	"time"
 
+	"github.com/equinix-labs/otel-init-go/otelinit"
 	"github.com/metal-toolbox/alloy/internal/app"
 	"github.com/metal-toolbox/alloy/internal/collector"
+	"github.com/metal-toolbox/alloy/internal/metrics"
 	"github.com/metal-toolbox/alloy/internal/model"
 	"github.com/metal-toolbox/alloy/internal/version"
 	"github.com/sirupsen/logrus"
@@ -30,14 +32,27 @@ var cmdInband = &cobra.Command{
 			log.Fatal(err)
 		}
 
+		if cmd.Flags().Changed("sqlite-file") {
+			alloy.Config.SqliteFile = sqliteFile
+		}
+
 		if outputStdout {
 			storeKind = string(model.StoreKindMock)
 		}
 
+		if cmd.Flags().Changed("dry-run") {
+			setDryRun(alloy.Config)
+		}
+
 		if storeKind == string(model.StoreKindFleetDB) && assetID == "" {
 			log.Fatal("--asset-id flag required for inband command with fleetdb store")
 		}
 
+		if asWorker {
+			runInbandWorker(cmd.Context(), alloy)
+			return
+		}
+
 		// execution timeout
 		timeoutC := time.NewTimer(inbandTimeout).C
 
@@ -76,6 +91,31 @@ var cmdInband = &cobra.Command{
 	},
 }
 
+// runInbandWorker runs Alloy as a worker that fulfills inband inventory conditions for this host.
+func runInbandWorker(ctx context.Context, alloy *app.App) {
+	if assetID == "" {
+		log.Fatal("--asset-id flag required for inband worker")
+	}
+
+	// serve metrics endpoint
+	metrics.ListenAndServe()
+
+	ctx, otelShutdown := otelinit.InitOpenTelemetry(ctx, "alloy")
+	defer otelShutdown(ctx)
+
+	// setup cancel context with cancel func
+	ctx, cancelFunc := context.WithCancel(ctx)
+	defer cancelFunc()
+
+	// routine listens for termination signal and cancels the context
+	go func() {
+		<-alloy.TermCh
+		cancelFunc()
+	}()
+
+	runWorker(ctx, alloy, model.AppKindInband, assetID)
+}
+
 func collectInband(ctx context.Context, cfg *app.Configuration, logger *logrus.Logger) {
 	v := version.Current()
 	logger.WithFields(
@@ -110,6 +150,9 @@ func collectInband(ctx context.Context, cfg *app.Configuration, logger *logrus.L
 // install command flags
 func init() {
 	cmdInband.PersistentFlags().StringVarP(&assetID, "asset-id", "", "", "The asset identifier - required when store is set to fleetdb")
+	cmdInband.PersistentFlags().BoolVar(&asWorker, "worker", false, "Run Alloy as a worker listening for inband inventory conditions on NATS")
+	cmdInband.PersistentFlags().StringVar(&facilityCode, "facility-code", "sandbox", "The facility code this Alloy instance is associated with")
+	cmdInband.PersistentFlags().IntVarP(&replicaCount, "replica-count", "r", 3, "The number of replicaCount to use for NATS KV data") // nolint:gomnd // obvious int is obvious
 	cmdInband.PersistentFlags().DurationVar(&inbandTimeout, "timeout", 1*time.Minute, "timeout inventory collection if the duration exceeds the given parameter, accepted values are int time.Duration string format - 12h, 5d...")
 
 	rootCmd.AddCommand(cmdInband)
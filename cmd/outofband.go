@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/equinix-labs/otel-init-go/otelinit"
 	"github.com/metal-toolbox/rivets/v2/events"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
 
@@ -29,17 +33,42 @@ var (
 	// assetIDs is the list of asset IDs to lookup out of band inventory for.
 	assetIDs []string
 
+	// assetIDsFile is the file listing asset IDs to lookup out of band inventory for, - to read from stdin.
+	assetIDsFile string
+
 	// csvfile holds the path to the csv file
 	csvFile string
 
-	// facilityCode to limit Alloy to when running as a worker.
-	facilityCode string
+	// csvOutputDir holds the path to the directory the csv store writes collected data to.
+	csvOutputDir string
+
+	// fileStoreDir holds the path to the directory the file store reads assets from and writes collected data to.
+	fileStoreDir string
+
+	// asController when true runs Alloy as a controller that periodically collects data for all assets in the store.
+	asController bool
+
+	// concurrency is the number of assets collected concurrently.
+	concurrency int
 
-	// asWorker when true runs Alloy as a worker listening on the NATS JS for Conditions to act on.
-	asWorker bool
+	// select* flags select the assets the controller collects data for.
+	selectVendor          string
+	selectModel           string
+	selectSerialPrefix    string
+	selectAttributes      []string
+	selectCollectedBefore string
 
-	// The number of replicaCount to use NATS KV data
-	replicaCount int
+	// resume when true resumes the controller collection from the checkpoint.
+	resume bool
+
+	// checkpointFile is the file the controller collection checkpoint is written to.
+	checkpointFile string
+
+	// shard when true shards the assets across the live controller replicas.
+	shard bool
+
+	// controllerStream is the NATS stream the controller KV buckets are accessed through, opened when required.
+	controllerStream events.Stream
 )
 
 // outofband inventory, bios configuration collection command
@@ -54,6 +83,26 @@ var cmdOutofband = &cobra.Command{
 
 		alloy.Config.CsvFile = csvFile
 
+		if cmd.Flags().Changed("csv-output-dir") {
+			alloy.Config.CsvOutputDir = csvOutputDir
+		}
+
+		if cmd.Flags().Changed("sqlite-file") {
+			alloy.Config.SqliteFile = sqliteFile
+		}
+
+		if cmd.Flags().Changed("file-store-dir") {
+			alloy.Config.FileStoreDir = fileStoreDir
+		}
+
+		if cmd.Flags().Changed("dry-run") {
+			setDryRun(alloy.Config)
+		}
+
+		if cmd.Flags().Changed("concurrency") {
+			alloy.Config.Concurrency = concurrency
+		}
+
 		// profiling endpoint
 		if enableProfiling {
 			helpers.EnablePProfile()
@@ -76,25 +125,58 @@ var cmdOutofband = &cobra.Command{
 
 		switch {
 		case asWorker:
-			runWorker(ctx, alloy)
+			runWorker(ctx, alloy, model.AppKindOutOfBand, "")
+			return
+
+		case asController:
+			// flags override the collect interval, splay configuration parameters.
+			if cmd.Flags().Changed("collect-interval") || alloy.Config.CollectInterval == 0 {
+				alloy.Config.CollectInterval = interval
+			}
+
+			if cmd.Flags().Changed("collect-splay") || alloy.Config.CollectIntervalSplay == 0 {
+				alloy.Config.CollectIntervalSplay = splay
+			}
+
+			selector, err := assetSelectorFromFlags()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			alloy.Config.AssetSelector = selector
+
+			if cmd.Flags().Changed("checkpoint-file") {
+				alloy.Config.OutofbandOptions.Checkpoint.File = checkpointFile
+			}
+
+			if cmd.Flags().Changed("shard") {
+				alloy.Config.OutofbandOptions.Shard.Enabled = shard
+			}
+
+			runController(ctx, alloy)
 			return
 
-		case len(assetIDs) > 0:
-			runOnAssets(ctx, alloy)
+		case len(assetIDs) > 0 || assetIDsFile != "":
+			if failed := runOnAssets(ctx, alloy); failed {
+				// deferred funcs are not run on exit.
+				otelShutdown(ctx)
+				os.Exit(1)
+			}
+
 			return
 		}
 
-		log.Fatal("either --asset-ids OR --controller-mode was expected")
+		log.Fatal("either --asset-ids, --asset-ids-file OR --controller OR --worker was expected")
 	},
 }
 
-func runWorker(ctx context.Context, alloy *app.App) {
+func runWorker(ctx context.Context, alloy *app.App, appKind model.AppKind, workerAssetID string) {
 	stream, err := events.NewStream(*alloy.Config.NatsOptions)
 	if err != nil {
 		alloy.Logger.Fatal(err)
 	}
 
-	w, err := worker.New(ctx, facilityCode, replicaCount, stream, alloy.Config, alloy.SyncWg, alloy.Logger)
+	w, err := worker.New(ctx, appKind, workerAssetID, facilityCode, replicaCount, stream, alloy.Config, alloy.SyncWg, alloy.Logger)
 	if err != nil {
 		alloy.Logger.Fatal(err)
 	}
@@ -102,25 +184,225 @@ func runWorker(ctx context.Context, alloy *app.App) {
 	w.Run(ctx)
 }
 
-func runOnAssets(ctx context.Context, alloy *app.App) {
-	c, err := collector.NewDeviceCollector(
+func runController(ctx context.Context, alloy *app.App) {
+	c, err := collector.NewAssetIterCollector(
 		ctx,
 		model.StoreKind(storeKind),
 		model.AppKindOutOfBand,
 		alloy.Config,
+		alloy.SyncWg,
 		alloy.Logger,
 	)
+	if err != nil {
+		alloy.Logger.Fatal(err)
+	}
+
+	defer c.Close()
+
+	checkpoint, err := newCheckpointer(alloy)
+	if err != nil {
+		alloy.Logger.Fatal(err)
+	}
+
+	if checkpoint != nil {
+		c.SetCheckpointer(checkpoint)
+	}
+
+	windows, err := collector.NewMaintenanceWindows(alloy.Config.OutofbandOptions.MaintenanceWindows)
+	if err != nil {
+		alloy.Logger.Fatal(err)
+	}
+
+	c.SetMaintenanceWindows(windows, facilityCode)
+
+	if alloy.Config.OutofbandOptions.Shard.Enabled {
+		membership, err := newShardMembership(alloy)
+		if err != nil {
+			alloy.Logger.Fatal(err)
+		}
+
+		c.SetShard(membership.Shard())
+
+		alloy.SyncWg.Add(1)
+
+		go func() {
+			defer alloy.SyncWg.Done()
+			membership.Run(ctx)
+		}()
+	}
+
+	alloy.Logger.WithFields(logrus.Fields{
+		"interval": alloy.Config.CollectInterval.String(),
+		"splay":    alloy.Config.CollectIntervalSplay.String(),
+	}).Info("Alloy controller running")
+
+	c.CollectAtIntervals(ctx, alloy.Config.CollectInterval, alloy.Config.CollectIntervalSplay)
+
+	// wait for dispatched collection routines to return.
+	alloy.SyncWg.Wait()
+}
+
+// newCheckpointer returns the checkpointer for the controller collection, nil when no checkpoint is configured.
+//
+// The checkpoint is written to the checkpoint file when set, or to the key in the NATS KV bucket.
+func newCheckpointer(alloy *app.App) (*collector.Checkpointer, error) {
+	opts := alloy.Config.OutofbandOptions.Checkpoint
+
+	if !opts.Enabled() {
+		if resume {
+			return nil, errors.New("--resume requires the --checkpoint-file or the checkpoint file, kv_key configuration parameters")
+		}
+
+		return nil, nil // nolint:nilnil // the checkpoint is optional
+	}
+
+	// the checkpoint records the progress through the assets in the order listed by the store.
+	if alloy.Config.OutofbandOptions.Schedule.Order == app.ScheduleOrderStaleness {
+		return nil, errors.Wrap(app.ErrConfig, "checkpoint is not supported with the staleness schedule order")
+	}
+
+	var store collector.CheckpointStore
+
+	if opts.File != "" {
+		store = collector.NewFileCheckpointStore(opts.File)
+	} else {
+		stream, err := openControllerStream(alloy)
+		if err != nil {
+			return nil, err
+		}
+
+		if store, err = worker.NewKVCheckpointStore(stream, opts.KVKey, replicaCount); err != nil {
+			return nil, err
+		}
+	}
+
+	return collector.NewCheckpointer(store, opts.MaxTotalChange, resume, alloy.Logger), nil
+}
+
+// newShardMembership registers the controller in the liveness registry, to shard the assets across the live controller replicas.
+func newShardMembership(alloy *app.App) (*worker.ShardMembership, error) {
+	name := alloy.Config.OutofbandOptions.Shard.Name
+	if name == "" {
+		name = fmt.Sprintf("%s-%s-controller-%s", model.AppName, model.AppKindOutOfBand, facilityCode)
+	}
+
+	stream, err := openControllerStream(alloy)
+	if err != nil {
+		return nil, err
+	}
+
+	return worker.NewShardMembership(stream, name, replicaCount, alloy.Logger)
+}
+
+// openControllerStream returns the NATS stream for the controller KV buckets, the stream is opened on the first call.
+func openControllerStream(alloy *app.App) (events.Stream, error) {
+	if controllerStream != nil {
+		return controllerStream, nil
+	}
+
+	stream, err := events.NewStream(*alloy.Config.NatsOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stream.Open(); err != nil {
+		return nil, err
+	}
+
+	controllerStream = stream
+
+	return stream, nil
+}
 
+// runOnAssets collects data for the assets identified by assetIDs, assetIDsFile and writes a summary of the results to stderr,
+// it returns true when the collection failed for any of the assets.
+func runOnAssets(ctx context.Context, alloy *app.App) (failed bool) {
+	assets, err := assetsFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c, err := collector.NewAssetIterCollector(
+		ctx,
+		model.StoreKind(storeKind),
+		model.AppKindOutOfBand,
+		alloy.Config,
+		alloy.SyncWg,
+		alloy.Logger,
+	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	for _, assetID := range assetIDs {
-		asset := &model.Asset{ID: assetID}
-		if err := c.CollectOutofband(ctx, asset, outputStdout); err != nil {
-			alloy.Logger.Warn(err)
+	defer c.Close()
+
+	results := c.CollectAssets(ctx, assets, outputStdout)
+
+	// the summary is written to stderr to keep the --output-stdout data parseable.
+	if err := collector.WriteResults(os.Stderr, results); err != nil {
+		alloy.Logger.Warn(err)
+	}
+
+	for _, result := range results {
+		if !result.Success() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// assetsFromFlags returns the assets listed by the --asset-ids and --asset-ids-file flags.
+func assetsFromFlags() ([]*model.Asset, error) {
+	assets := make([]*model.Asset, 0, len(assetIDs))
+	for _, id := range assetIDs {
+		assets = append(assets, &model.Asset{ID: id})
+	}
+
+	if assetIDsFile != "" {
+		listed, err := helpers.ReadAssetIDsFile(assetIDsFile, os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, listed...)
+	}
+
+	if len(assets) == 0 {
+		return nil, errors.New("no asset IDs listed in --asset-ids-file " + assetIDsFile)
+	}
+
+	return assets, nil
+}
+
+// assetSelectorFromFlags returns the asset selector set by the --select-* flags, nil when none are set.
+func assetSelectorFromFlags() (*model.AssetSelector, error) {
+	selector := &model.AssetSelector{
+		Vendor:       selectVendor,
+		Model:        selectModel,
+		SerialPrefix: selectSerialPrefix,
+	}
+
+	for _, s := range selectAttributes {
+		attribute, err := model.ParseAttributeSelector(s)
+		if err != nil {
+			return nil, err
+		}
+
+		selector.Attributes = append(selector.Attributes, attribute)
+	}
+
+	if selectCollectedBefore != "" {
+		if err := selector.SetCollectedBefore(selectCollectedBefore); err != nil {
+			return nil, err
 		}
 	}
+
+	if selector.IsEmpty() {
+		return nil, nil
+	}
+
+	return selector, nil
 }
 
 // install command flags
@@ -128,9 +410,22 @@ func init() {
 	cmdOutofband.PersistentFlags().DurationVar(&interval, "collect-interval", app.DefaultCollectInterval, "interval sets the periodic data collection interval")
 	cmdOutofband.PersistentFlags().DurationVar(&splay, "collect-splay", app.DefaultCollectSplay, "splay adds jitter to the collection interval")
 	cmdOutofband.PersistentFlags().StringSliceVar(&assetIDs, "asset-ids", []string{}, "Collect inventory for the given comma separated list of asset IDs.")
+	cmdOutofband.PersistentFlags().StringVar(&assetIDsFile, "asset-ids-file", "", "Collect inventory for the asset IDs listed in the file, one per line or CSV with an optional vendor column, - to read from stdin.")
 	cmdOutofband.PersistentFlags().StringVar(&csvFile, "csv-file", "assets.csv", "CSV file containing BMC credentials for assets.")
+	cmdOutofband.PersistentFlags().StringVar(&csvOutputDir, "csv-output-dir", "", "Directory the csv store writes collected data to, one JSON file per asset ID.")
+	cmdOutofband.PersistentFlags().StringVar(&fileStoreDir, "file-store-dir", "", "Directory the file store reads assets from and writes collected data snapshots to.")
 	cmdOutofband.PersistentFlags().StringVar(&facilityCode, "facility-code", "sandbox", "The facility code this Alloy instance is associated with")
 	cmdOutofband.PersistentFlags().BoolVar(&asWorker, "worker", false, "Run Alloy as a worker listening for conditions on NATS")
+	cmdOutofband.PersistentFlags().BoolVar(&asController, "controller", false, "Run Alloy as a controller that periodically collects data for all assets in the store")
+	cmdOutofband.PersistentFlags().IntVar(&concurrency, "concurrency", model.ConcurrencyDefault, "The number of assets to collect data for concurrently, overrides the concurrency configuration parameter")
+	cmdOutofband.PersistentFlags().StringVar(&selectVendor, "select-vendor", "", "Collect data for assets with the vendor as registered in the store, with --controller")
+	cmdOutofband.PersistentFlags().StringVar(&selectModel, "select-model", "", "Collect data for assets with the model as registered in the store, with --controller")
+	cmdOutofband.PersistentFlags().StringVar(&selectSerialPrefix, "select-serial-prefix", "", "Collect data for assets with a serial beginning with the prefix, with --controller")
+	cmdOutofband.PersistentFlags().StringArrayVar(&selectAttributes, "select-attribute", []string{}, "Collect data for assets with the attribute namespace[~key[~value]], nested keys are dot separated, can be repeated, with --controller")
+	cmdOutofband.PersistentFlags().StringVar(&selectCollectedBefore, "select-collected-before", "", "Collect data for assets last collected before the RFC3339 time or the duration ago, or never collected, with --controller")
+	cmdOutofband.PersistentFlags().BoolVar(&resume, "resume", false, "Resume the collection from the checkpoint of an interrupted collection, with --controller")
+	cmdOutofband.PersistentFlags().StringVar(&checkpointFile, "checkpoint-file", "", "File the collection progress is recorded in to resume from, overrides the checkpoint file configuration parameter, with --controller")
+	cmdOutofband.PersistentFlags().BoolVar(&shard, "shard", false, "Shard the assets across the live controller replicas registered in the NATS liveness registry, with --controller")
 	cmdOutofband.PersistentFlags().IntVarP(&replicaCount, "replica-count", "r", 3, "The number of replicaCount to use for NATS KV data") // nolint:gomnd // obvious int is obvious
 
 	rootCmd.AddCommand(cmdOutofband)
@@ -17,8 +17,10 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
 
+	"github.com/metal-toolbox/alloy/internal/app"
 	"github.com/metal-toolbox/alloy/internal/model"
 	"github.com/spf13/cobra"
 )
@@ -34,6 +36,21 @@ var (
 	outputStdout bool
 
 	enableProfiling bool
+
+	// facilityCode to limit Alloy to when running as a worker.
+	facilityCode string
+
+	// asWorker when true runs Alloy as a worker listening on the NATS JS for Conditions to act on.
+	asWorker bool
+
+	// The number of replicaCount to use NATS KV data
+	replicaCount int
+
+	// sqliteFile holds the path to the sqlite store database file.
+	sqliteFile string
+
+	// dryRun when set prints the changes planned for the fleetdb store instead of writing them.
+	dryRun bool
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -51,10 +68,25 @@ func Execute() {
 	}
 }
 
+// setDryRun applies the --dry-run flag to the fleetdb store configuration.
+func setDryRun(cfg *app.Configuration) {
+	if storeKind != string(model.StoreKindFleetDB) {
+		log.Fatal("--dry-run is supported with the fleetdb store, --store fleetdb expected")
+	}
+
+	if cfg.FleetDBAPIOptions == nil {
+		cfg.FleetDBAPIOptions = &app.FleetDBAPIOptions{}
+	}
+
+	cfg.FleetDBAPIOptions.DryRun = dryRun
+}
+
 func init() {
 	// Read in env vars with appName as prefix
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "configuration file")
-	rootCmd.PersistentFlags().StringVar(&storeKind, "store", "mock", "The inventory store kind (fleetdb, csv)")
+	rootCmd.PersistentFlags().StringVar(&storeKind, "store", "mock", "The inventory store kind (fleetdb, csv, file, sqlite)")
+	rootCmd.PersistentFlags().StringVar(&sqliteFile, "sqlite-file", "", "SQLite database file for the sqlite store, created when it does not exist.")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the changes planned for the fleetdb store to stdout instead of writing them.")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "set logging level - debug, trace")
 	rootCmd.PersistentFlags().BoolVarP(&outputStdout, "output-stdout", "", false, "Output collected data to STDOUT instead of the store")
 	rootCmd.PersistentFlags().BoolVarP(&enableProfiling, "enable-pprof", "", false, "Enable profiling endpoint at: "+model.ProfilingEndpoint)
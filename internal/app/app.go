@@ -6,6 +6,7 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/metal-toolbox/alloy/internal/helpers"
 	"github.com/metal-toolbox/alloy/internal/model"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -69,6 +70,9 @@ func New(appKind model.AppKind, storeKind model.StoreKind, cfgFile string, logle
 
 	app.Logger.SetFormatter(&logrus.JSONFormatter{})
 
+	// redact secrets from logs
+	app.Logger.AddHook(helpers.NewRedactHook())
+
 	// register for SIGINT, SIGTERM
 	signal.Notify(app.TermCh, syscall.SIGINT, syscall.SIGTERM)
 
@@ -8,6 +8,7 @@ import (
 
 	"github.com/jeremywohl/flatten"
 	"github.com/metal-toolbox/alloy/internal/model"
+	common "github.com/metal-toolbox/bmc-common"
 	"github.com/metal-toolbox/rivets/v2/events"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
@@ -20,6 +21,12 @@ var (
 const (
 	DefaultCollectInterval = 72 * time.Hour
 	DefaultCollectSplay    = 4 * time.Hour
+
+	// DefaultComponentRemovalMaxCount is the maximum number of components removed from a server in a collection run.
+	DefaultComponentRemovalMaxCount = 4
+
+	// DefaultComponentRemovalMaxFraction is the maximum fraction of a servers components removed in a collection run.
+	DefaultComponentRemovalMaxFraction = 0.25
 )
 
 // Configuration holds application configuration read from a YAML or set by env variables.
@@ -39,6 +46,15 @@ type Configuration struct {
 	// CSV file path when StoreKind is set to csv.
 	CsvFile string `mapstructure:"csv_file"`
 
+	// CsvOutputDir is the directory collected asset data is written to when StoreKind is set to csv.
+	CsvOutputDir string `mapstructure:"csv_output_dir"`
+
+	// FileStoreDir is the directory assets are read from and collected asset data is written to when StoreKind is set to file.
+	FileStoreDir string `mapstructure:"file_store_dir"`
+
+	// SqliteFile is the path to the SQLite database file when StoreKind is set to sqlite.
+	SqliteFile string `mapstructure:"sqlite_file"`
+
 	// FacilityCode limits this alloy to events in a facility.
 	FacilityCode string `mapstructure:"facility_code"`
 
@@ -47,9 +63,19 @@ type Configuration struct {
 	// This parameter is required when StoreKind is set to fleetdb.
 	FleetDBAPIOptions *FleetDBAPIOptions `mapstructure:"fleetdb"`
 
+	// OutofbandOptions defines the out of band BMC collection configuration parameters.
+	OutofbandOptions *OutofbandOptions `mapstructure:"outofband"`
+
+	// SecretsOptions defines the secret provider the BMC credentials referenced by key in the store are resolved from.
+	SecretsOptions *SecretsOptions `mapstructure:"secrets"`
+
 	// Controller Out of band collector concurrency
 	Concurrency int `mapstructure:"concurrency"`
 
+	// AssetSelector selects the assets the controller collects data for, set from the command flags,
+	// data is collected for all assets when nil.
+	AssetSelector *model.AssetSelector `mapstructure:"-"`
+
 	CollectInterval time.Duration `mapstructure:"collect_interval"`
 
 	CollectIntervalSplay time.Duration `mapstructure:"collect_interval_splay"`
@@ -77,6 +103,368 @@ type FleetDBAPIOptions struct {
 	OidcClientID         string   `mapstructure:"oidc_client_id"`
 	OidcClientScopes     []string `mapstructure:"oidc_client_scopes"`
 	DisableOAuth         bool     `mapstructure:"disable_oauth"`
+
+	// ComponentRemoval configures the removal of server components
+	// that are no longer present in the collected inventory.
+	ComponentRemoval ComponentRemovalOptions `mapstructure:"component_removal"`
+
+	// DryRun when set, the changes to be registered in fleetdb are printed as a plan to stdout instead of being written.
+	DryRun bool `mapstructure:"dry_run"`
+}
+
+// SecretsOptions defines the secret provider configuration.
+type SecretsOptions struct {
+	// Provider is the kind of secret provider - env, dir, age_file.
+	//
+	// The BMC credentials referenced by key in the store are not resolved when this is unset.
+	Provider string `mapstructure:"provider"`
+
+	// EnvPrefix is the prefix of the environment variables the env provider reads,
+	// the credential for a key is read from the <prefix><KEY>_USERNAME, <prefix><KEY>_PASSWORD variables.
+	EnvPrefix string `mapstructure:"env_prefix"`
+
+	// Dir is the directory the dir provider reads, the credential for a key is read from the
+	// <dir>/<key>/username, <dir>/<key>/password files, as a Kubernetes secret is mounted.
+	Dir string `mapstructure:"dir"`
+
+	// File is the age encrypted YAML credentials file the age_file provider reads.
+	File string `mapstructure:"file"`
+
+	// IdentityFile is the age identity file the credentials file is decrypted with.
+	IdentityFile string `mapstructure:"identity_file"`
+}
+
+// ComponentRemovalOptions defines the safeguards applied when removing server components from fleetdb.
+type ComponentRemovalOptions struct {
+	// Enabled when set, components not present in the collected inventory are removed.
+	Enabled bool `mapstructure:"enabled"`
+
+	// MaxCount is the maximum number of components that may be removed from a server in a collection run.
+	MaxCount int `mapstructure:"max_count"`
+
+	// MaxFraction is the maximum fraction (0 - 1) of a servers components that may be removed in a collection run.
+	MaxFraction float64 `mapstructure:"max_fraction"`
+}
+
+// OutofbandOptions defines the out of band BMC collection configuration.
+type OutofbandOptions struct {
+	// Retry configures the retries of BMC operations that failed with a transient error.
+	Retry OutofbandRetryOptions `mapstructure:"retry"`
+
+	// CircuitBreaker configures skipping BMCs that failed consecutive collections.
+	CircuitBreaker CircuitBreakerOptions `mapstructure:"circuit_breaker"`
+
+	// BMCClients selects the bmclib providers and timeouts by the asset vendor, model.
+	//
+	// The first entry matching the asset applies, followed by the DefaultBMCClients.
+	BMCClients []BMCClientOptions `mapstructure:"bmc_clients"`
+
+	// CredentialsFile is a YAML file with the default BMC credentials by vendor, model,
+	// these are tried in order when the login with the inventory store credential fails as unauthorized.
+	CredentialsFile string `mapstructure:"credentials_file"`
+
+	// Schedule configures the order assets are collected in the periodic collections.
+	Schedule ScheduleOptions `mapstructure:"schedule"`
+
+	// Checkpoint configures where the progress of the periodic collections is recorded, to resume from when interrupted.
+	Checkpoint CheckpointOptions `mapstructure:"checkpoint"`
+
+	// Shard configures sharding the assets across the controller replicas collecting data from the same store.
+	Shard ShardOptions `mapstructure:"shard"`
+
+	// MaintenanceWindows limits the times assets are collected by their facility, vendor, model.
+	//
+	// The first entry matching the asset applies, assets not matching an entry are collected at any time.
+	MaintenanceWindows []MaintenanceWindowOptions `mapstructure:"maintenance_windows"`
+
+	// AdaptiveConcurrency configures adjusting the collections in flight to the BMC error rate and query latencies.
+	AdaptiveConcurrency AdaptiveConcurrencyOptions `mapstructure:"adaptive_concurrency"`
+
+	// BMCLimits limits the collections in flight for the BMCs in a subnet or rack, in addition to the concurrency.
+	BMCLimits BMCLimitsOptions `mapstructure:"bmc_limits"`
+}
+
+// BMCLimitsOptions defines the collections allowed in flight for the BMCs sharing a subnet or rack.
+type BMCLimitsOptions struct {
+	// Subnets limits the collections in flight for the BMCs with an address in a subnet,
+	// the first subnet including the BMC address applies.
+	Subnets []SubnetLimitOptions `mapstructure:"subnets"`
+
+	// RackAttribute is the attribute in the inventory store holding the asset rack, in the namespace~key form,
+	// nested keys are separated by a dot.
+	RackAttribute string `mapstructure:"rack_attribute"`
+
+	// RackLimit when set with the RackAttribute, limits the collections in flight for the assets in each rack.
+	RackLimit int `mapstructure:"rack_limit"`
+}
+
+// SubnetLimitOptions defines the collections allowed in flight for the BMCs in a subnet.
+type SubnetLimitOptions struct {
+	// CIDR is the IPv4 or IPv6 subnet - 10.1.2.0/24.
+	CIDR string `mapstructure:"cidr"`
+
+	// Limit is the maximum count of collections in flight for the BMCs in the subnet.
+	Limit int `mapstructure:"limit"`
+}
+
+// AdaptiveConcurrencyOptions defines how the collections in flight are adjusted,
+// the concurrency is increased additively while the BMCs respond, and decreased multiplicatively
+// when the rolling error rate or BMC query latencies exceed the thresholds.
+type AdaptiveConcurrencyOptions struct {
+	// Enabled when set, adjusts the collections in flight, starting from the configured concurrency.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Min is the lower bound of the collections in flight.
+	Min int `mapstructure:"min"`
+
+	// Max is the upper bound of the collections in flight, defaults to the configured concurrency.
+	Max int `mapstructure:"max"`
+
+	// Window is the count of the most recent collections, and BMC queries of each kind, the error rate and latencies are measured over.
+	Window int `mapstructure:"window"`
+
+	// MaxErrorRate is the fraction (0 - 1) of collections failing with a BMC error above which the concurrency is decreased.
+	MaxErrorRate float64 `mapstructure:"max_error_rate"`
+
+	// LatencyThresholds are the mean BMC query times by query kind - conn_open, inventory, GetBiosConfiguration,
+	// above which the concurrency is decreased.
+	LatencyThresholds map[string]time.Duration `mapstructure:"latency_thresholds"`
+
+	// DecreaseFactor is the factor (0 - 1) the concurrency is multiplied by when decreased.
+	DecreaseFactor float64 `mapstructure:"decrease_factor"`
+}
+
+// validate returns an error when the adaptive concurrency is enabled with invalid options.
+func (o *AdaptiveConcurrencyOptions) validate() error {
+	if !o.Enabled {
+		return nil
+	}
+
+	if o.Min < 1 || (o.Max != 0 && o.Max < o.Min) {
+		return errors.Wrap(ErrConfig, "invalid adaptive_concurrency bounds, expected 1 <= min <= max")
+	}
+
+	if o.Window < 1 {
+		return errors.Wrap(ErrConfig, "invalid adaptive_concurrency window, expected a positive count")
+	}
+
+	if o.MaxErrorRate <= 0 || o.MaxErrorRate > 1 {
+		return errors.Wrap(ErrConfig, "invalid adaptive_concurrency max_error_rate, expected a fraction between 0 and 1")
+	}
+
+	if o.DecreaseFactor <= 0 || o.DecreaseFactor >= 1 {
+		return errors.Wrap(ErrConfig, "invalid adaptive_concurrency decrease_factor, expected a fraction between 0 and 1")
+	}
+
+	return nil
+}
+
+// MaintenanceWindowOptions defines the time windows assets matching the facility, vendor, model patterns are collected in.
+type MaintenanceWindowOptions struct {
+	// Facility, Vendor, Model are the patterns matched with the asset facility, vendor, model by model.MatchPattern.
+	//
+	// An entry with only the facility set pauses the controller collection while its windows are closed.
+	Facility string `mapstructure:"facility"`
+	Vendor   string `mapstructure:"vendor"`
+	Model    string `mapstructure:"model"`
+
+	// Timezone is the IANA time zone the window schedules are evaluated in, defaults to UTC.
+	Timezone string `mapstructure:"timezone"`
+
+	// Windows are the time windows collections are allowed in.
+	Windows []WindowOptions `mapstructure:"windows"`
+}
+
+// WindowOptions defines a recurring time window.
+type WindowOptions struct {
+	// Start is a cron expression - minute hour day-of-month month day-of-week, for the times the window opens.
+	Start string `mapstructure:"start"`
+
+	// Duration is how long the window remains open.
+	Duration time.Duration `mapstructure:"duration"`
+}
+
+// ShardOptions defines how assets are sharded across controller replicas.
+type ShardOptions struct {
+	// Enabled when set, shards the assets across the live replicas registered in the NATS liveness registry.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Name identifies the replicas the assets are sharded across, defaults to alloy-outofband-controller-<facility code>.
+	Name string `mapstructure:"name"`
+}
+
+// CheckpointOptions defines where the checkpoint of the collection over all assets is recorded.
+type CheckpointOptions struct {
+	// File is the local file the checkpoint is written to.
+	File string `mapstructure:"file"`
+
+	// KVKey when File is not set, is the key in the alloy-checkpoints NATS KV bucket the checkpoint is written to.
+	KVKey string `mapstructure:"kv_key"`
+
+	// MaxTotalChange is the fraction (0 - 1) by which the count of assets in the store may change
+	// for the checkpoint to be resumed from, the checkpoint is discarded on a larger change.
+	MaxTotalChange float64 `mapstructure:"max_total_change"`
+}
+
+// Enabled returns true when a checkpoint file or NATS KV key is set.
+func (o *CheckpointOptions) Enabled() bool {
+	return o.File != "" || o.KVKey != ""
+}
+
+const (
+	// ScheduleOrderStore collects assets in the order they are listed by the inventory store.
+	ScheduleOrderStore = "store"
+
+	// ScheduleOrderStaleness collects assets never collected or that failed the last collection first,
+	// followed by the assets last collected more than the MaxAge ago, each in the order they were last collected.
+	ScheduleOrderStaleness = "staleness"
+)
+
+// ScheduleOptions defines the order assets are collected in.
+type ScheduleOptions struct {
+	// Order is the order assets are collected in - store (default) or staleness.
+	Order string `mapstructure:"order"`
+
+	// MaxAge when set with the staleness order, prioritizes collecting assets last collected more than the duration ago.
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// validate returns an error when the schedule options are invalid.
+func (o *ScheduleOptions) validate() error {
+	switch o.Order {
+	case "", ScheduleOrderStore, ScheduleOrderStaleness:
+	default:
+		return errors.Wrap(ErrConfig, "invalid schedule order, expected store or staleness: "+o.Order)
+	}
+
+	if o.MaxAge < 0 {
+		return errors.Wrap(ErrConfig, "invalid schedule max_age, expected a positive duration: "+o.MaxAge.String())
+	}
+
+	return nil
+}
+
+// BMCClientOptions defines the bmclib providers and timeouts for assets matching the vendor, model patterns.
+type BMCClientOptions struct {
+	// Vendor, Model are the patterns matched with the asset vendor, model by model.MatchPattern.
+	Vendor string `mapstructure:"vendor"`
+	Model  string `mapstructure:"model"`
+
+	// Providers is the ordered list of bmclib providers attempted,
+	// each is either a provider name - gofish, dell, asrockrack, supermicro, openbmc...
+	// or a provider protocol - redfish, vendorapi, ipmi.
+	Providers []string `mapstructure:"providers"`
+
+	// ProviderTimeout is the maximum time each provider spends on a BMC query.
+	ProviderTimeout time.Duration `mapstructure:"provider_timeout"`
+
+	// Deadline when set is the maximum time spent on the collection from the BMC, across all providers and retries.
+	Deadline time.Duration `mapstructure:"deadline"`
+}
+
+// DefaultBMCClients returns the bmclib providers and timeouts applied to assets not matching a configured entry.
+//
+// nolint:gomnd // the default values are clearer inline.
+func DefaultBMCClients() []BMCClientOptions {
+	return []BMCClientOptions{
+		{Vendor: common.VendorDell, Providers: []string{"redfish"}, ProviderTimeout: 180 * time.Second},
+		{Vendor: common.VendorHPE, Providers: []string{"redfish"}, ProviderTimeout: 180 * time.Second},
+		{Vendor: common.VendorAsrockrack, Providers: []string{"vendorapi"}, ProviderTimeout: 180 * time.Second},
+		// both protocols are attempted when the vendor is unknown
+		{Providers: []string{"redfish", "vendorapi"}, ProviderTimeout: 180 * time.Second},
+	}
+}
+
+// CircuitBreakerOptions defines when the circuit for a BMC opens, and for how long collection is skipped.
+type CircuitBreakerOptions struct {
+	// Enabled when set skips BMCs with an open circuit.
+	Enabled bool `mapstructure:"enabled"`
+
+	// FailureThreshold is the number of consecutive BMC login failures after which the circuit opens.
+	FailureThreshold int `mapstructure:"failure_threshold"`
+
+	// Cooldown is the duration the circuit remains open, after which a collection is attempted again.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// OutofbandRetryOptions defines the retry policy for each BMC operation.
+type OutofbandRetryOptions struct {
+	Login      RetryPolicy `mapstructure:"login"`
+	Inventory  RetryPolicy `mapstructure:"inventory"`
+	BiosConfig RetryPolicy `mapstructure:"bios_config"`
+}
+
+// RetryPolicy defines how an operation is retried with exponential backoff.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	// InitialInterval is the backoff interval before the first retry.
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+
+	// MaxInterval is the maximum backoff interval.
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+
+	// Multiplier is applied to the backoff interval after each retry.
+	Multiplier float64 `mapstructure:"multiplier"`
+
+	// Jitter is the fraction (0 - 1) of the backoff interval that is randomized.
+	Jitter float64 `mapstructure:"jitter"`
+}
+
+// DefaultOutofbandOptions returns the out of band collection configuration defaults.
+//
+// nolint:gomnd // the default values are clearer inline.
+func DefaultOutofbandOptions() *OutofbandOptions {
+	return &OutofbandOptions{
+		Retry: OutofbandRetryOptions{
+			Login:      RetryPolicy{MaxAttempts: 3, InitialInterval: 5 * time.Second, MaxInterval: 30 * time.Second, Multiplier: 2, Jitter: 0.2},
+			Inventory:  RetryPolicy{MaxAttempts: 2, InitialInterval: 10 * time.Second, MaxInterval: 30 * time.Second, Multiplier: 2, Jitter: 0.2},
+			BiosConfig: RetryPolicy{MaxAttempts: 2, InitialInterval: 10 * time.Second, MaxInterval: 30 * time.Second, Multiplier: 2, Jitter: 0.2},
+		},
+		CircuitBreaker: CircuitBreakerOptions{
+			FailureThreshold: 3,
+			Cooldown:         time.Hour,
+		},
+		Checkpoint: CheckpointOptions{
+			MaxTotalChange: 0.1,
+		},
+		AdaptiveConcurrency: AdaptiveConcurrencyOptions{
+			Min:          1,
+			Window:       20,
+			MaxErrorRate: 0.25,
+			LatencyThresholds: map[string]time.Duration{
+				"conn_open": 30 * time.Second,
+				"inventory": 5 * time.Minute,
+			},
+			DecreaseFactor: 0.5,
+		},
+	}
+}
+
+// withDefaults returns the retry policy with the unset parameters set from the given defaults.
+func (p RetryPolicy) withDefaults(defaults RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+
+	if p.InitialInterval == 0 {
+		p.InitialInterval = defaults.InitialInterval
+	}
+
+	if p.MaxInterval == 0 {
+		p.MaxInterval = defaults.MaxInterval
+	}
+
+	if p.Multiplier == 0 {
+		p.Multiplier = defaults.Multiplier
+	}
+
+	if p.Jitter == 0 {
+		p.Jitter = defaults.Jitter
+	}
+
+	return p
 }
 
 // LoadConfiguration loads application configuration
@@ -91,6 +479,8 @@ func (a *App) LoadConfiguration(cfgFile string, storeKind model.StoreKind) error
 	// these are initialized here so viper can read in configuration from env vars
 	// once https://github.com/spf13/viper/pull/1429 is merged, this can go.
 	a.Config.FleetDBAPIOptions = &FleetDBAPIOptions{}
+	a.Config.OutofbandOptions = &OutofbandOptions{}
+	a.Config.SecretsOptions = &SecretsOptions{}
 	a.Config.NatsOptions = &events.NatsOptions{
 		Stream:   &events.NatsStreamOptions{},
 		Consumer: &events.NatsConsumerOptions{},
@@ -120,6 +510,15 @@ func (a *App) LoadConfiguration(cfgFile string, storeKind model.StoreKind) error
 	}
 
 	a.envVarAppOverrides()
+	a.outofbandDefaults()
+
+	if err := a.Config.OutofbandOptions.Schedule.validate(); err != nil {
+		return err
+	}
+
+	if err := a.Config.OutofbandOptions.AdaptiveConcurrency.validate(); err != nil {
+		return err
+	}
 
 	if a.Config.EventsBorkerKind == "nats" {
 		if err := a.envVarNatsOverrides(); err != nil {
@@ -152,6 +551,66 @@ func (a *App) envVarAppOverrides() {
 	if a.v.GetString("csv.file") != "" {
 		a.Config.CsvFile = a.v.GetString("csv.file")
 	}
+
+	if a.v.GetString("csv.output.dir") != "" {
+		a.Config.CsvOutputDir = a.v.GetString("csv.output.dir")
+	}
+
+	if a.v.GetString("file.store.dir") != "" {
+		a.Config.FileStoreDir = a.v.GetString("file.store.dir")
+	}
+
+	if a.v.GetString("sqlite.file") != "" {
+		a.Config.SqliteFile = a.v.GetString("sqlite.file")
+	}
+}
+
+// outofbandDefaults sets the out of band collection parameters not configured to their defaults.
+func (a *App) outofbandDefaults() {
+	if a.Config.OutofbandOptions == nil {
+		a.Config.OutofbandOptions = &OutofbandOptions{}
+	}
+
+	defaults := DefaultOutofbandOptions()
+	retry := &a.Config.OutofbandOptions.Retry
+
+	retry.Login = retry.Login.withDefaults(defaults.Retry.Login)
+	retry.Inventory = retry.Inventory.withDefaults(defaults.Retry.Inventory)
+	retry.BiosConfig = retry.BiosConfig.withDefaults(defaults.Retry.BiosConfig)
+
+	breaker := &a.Config.OutofbandOptions.CircuitBreaker
+	if breaker.FailureThreshold == 0 {
+		breaker.FailureThreshold = defaults.CircuitBreaker.FailureThreshold
+	}
+
+	if breaker.Cooldown == 0 {
+		breaker.Cooldown = defaults.CircuitBreaker.Cooldown
+	}
+
+	if a.Config.OutofbandOptions.Checkpoint.MaxTotalChange == 0 {
+		a.Config.OutofbandOptions.Checkpoint.MaxTotalChange = defaults.Checkpoint.MaxTotalChange
+	}
+
+	adaptive := &a.Config.OutofbandOptions.AdaptiveConcurrency
+	if adaptive.Min == 0 {
+		adaptive.Min = defaults.AdaptiveConcurrency.Min
+	}
+
+	if adaptive.Window == 0 {
+		adaptive.Window = defaults.AdaptiveConcurrency.Window
+	}
+
+	if adaptive.MaxErrorRate == 0 {
+		adaptive.MaxErrorRate = defaults.AdaptiveConcurrency.MaxErrorRate
+	}
+
+	if adaptive.LatencyThresholds == nil {
+		adaptive.LatencyThresholds = defaults.AdaptiveConcurrency.LatencyThresholds
+	}
+
+	if adaptive.DecreaseFactor == 0 {
+		adaptive.DecreaseFactor = defaults.AdaptiveConcurrency.DecreaseFactor
+	}
 }
 
 // envBindVars binds environment variables to the struct
@@ -294,6 +753,18 @@ func (a *App) envVarFleetDBAPIOverrides() error {
 
 	a.Config.FleetDBAPIOptions.EndpointURL = endpointURL
 
+	if a.Config.FleetDBAPIOptions.ComponentRemoval.MaxCount == 0 {
+		a.Config.FleetDBAPIOptions.ComponentRemoval.MaxCount = DefaultComponentRemovalMaxCount
+	}
+
+	if a.Config.FleetDBAPIOptions.ComponentRemoval.MaxFraction == 0 {
+		a.Config.FleetDBAPIOptions.ComponentRemoval.MaxFraction = DefaultComponentRemovalMaxFraction
+	}
+
+	if a.v.GetString("fleetdb.dry.run") != "" {
+		a.Config.FleetDBAPIOptions.DryRun = a.v.GetBool("fleetdb.dry.run")
+	}
+
 	if a.v.GetString("fleetdb.disable.oauth") != "" {
 		a.Config.FleetDBAPIOptions.DisableOAuth = a.v.GetBool("fleetdb.disable.oauth")
 	}
@@ -0,0 +1,219 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
+	"github.com/metal-toolbox/alloy/internal/metrics"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+// rollingWindow holds the most recent values added, up to its size.
+type rollingWindow struct {
+	values []float64
+	next   int
+	count  int
+	sum    float64
+}
+
+func newRollingWindow(size int) *rollingWindow {
+	return &rollingWindow{values: make([]float64, size)}
+}
+
+func (r *rollingWindow) add(value float64) {
+	if r.count == len(r.values) {
+		r.sum -= r.values[r.next]
+	} else {
+		r.count++
+	}
+
+	r.values[r.next] = value
+	r.sum += value
+	r.next = (r.next + 1) % len(r.values)
+}
+
+func (r *rollingWindow) mean() float64 {
+	if r.count == 0 {
+		return 0
+	}
+
+	return r.sum / float64(r.count)
+}
+
+// AdaptiveConcurrency adjusts the collections allowed in flight, within the configured bounds.
+//
+// The concurrency is increased by one for each concurrency count of collections completed while the BMCs respond,
+// and multiplied by the decrease factor when the error rate over the rolling window of collections,
+// or the mean BMC query time of a query kind over its rolling window exceeds the threshold.
+type AdaptiveConcurrency struct {
+	opts   app.AdaptiveConcurrencyOptions
+	stage  string
+	logger *logrus.Logger
+	limit  int
+	max    int
+	// errors holds 1 for each collection failed with a BMC error, 0 otherwise.
+	errors *rollingWindow
+	// latencies holds the BMC query times by query kind, for the kinds with a threshold configured.
+	latencies map[string]*rollingWindow
+	// sinceIncrease counts the collections completed since the concurrency was last adjusted,
+	// the concurrency is increased once a concurrency count of collections completed.
+	sinceIncrease int
+	// sinceDecrease counts the collections completed since the concurrency was decreased,
+	// the concurrency is decreased again only once a window of collections completed at the decreased concurrency.
+	sinceDecrease int
+	// removeObserver removes the BMC query time observer registered for the latencies.
+	removeObserver func()
+	mu             sync.Mutex
+}
+
+// NewAdaptiveConcurrency returns an AdaptiveConcurrency starting from the given concurrency,
+// the upper bound defaults to the given concurrency when not configured.
+//
+// The stage labels the effective concurrency metric - collector or worker.
+// Close is to be invoked once the collections are done, to stop measuring the BMC query times.
+func NewAdaptiveConcurrency(opts app.AdaptiveConcurrencyOptions, concurrency int, stage string, logger *logrus.Logger) *AdaptiveConcurrency {
+	upper := opts.Max
+	if upper == 0 {
+		upper = concurrency
+	}
+
+	a := &AdaptiveConcurrency{
+		opts:          opts,
+		stage:         stage,
+		logger:        logger,
+		max:           max(upper, opts.Min),
+		errors:        newRollingWindow(opts.Window),
+		latencies:     make(map[string]*rollingWindow, len(opts.LatencyThresholds)),
+		sinceDecrease: opts.Window,
+	}
+
+	for kind := range opts.LatencyThresholds {
+		a.latencies[kind] = newRollingWindow(opts.Window)
+	}
+
+	a.limit = min(max(concurrency, opts.Min), a.max)
+	a.setMetric()
+
+	a.removeObserver = metrics.AddBMCQueryObserver(a.observeLatency)
+
+	return a
+}
+
+// Close removes the BMC query time observer, the latencies are no longer measured.
+func (a *AdaptiveConcurrency) Close() {
+	if a == nil {
+		return
+	}
+
+	a.removeObserver()
+}
+
+// Limit returns the collections allowed in flight.
+func (a *AdaptiveConcurrency) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.limit
+}
+
+// Done records the outcome of the collection for the asset, and adjusts the concurrency.
+//
+// Collections skipped with an open BMC circuit are not recorded, login and inventory errors are recorded as failed.
+func (a *AdaptiveConcurrency) Done(asset *model.Asset) {
+	if a == nil || asset.HasError(outofband.CircuitOpenError) {
+		return
+	}
+
+	failed := asset.HasError(outofband.LoginError) || asset.HasError(outofband.InventoryError)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if failed {
+		a.errors.add(1)
+	} else {
+		a.errors.add(0)
+	}
+
+	a.sinceDecrease++
+	a.sinceIncrease++
+
+	previous := a.limit
+
+	if reason := a.congested(); reason != "" {
+		if a.sinceDecrease < a.opts.Window {
+			return
+		}
+
+		a.limit = max(int(float64(a.limit)*a.opts.DecreaseFactor), a.opts.Min)
+		a.sinceDecrease = 0
+		a.sinceIncrease = 0
+
+		a.setMetric()
+
+		a.logger.WithFields(logrus.Fields{
+			"stage":       a.stage,
+			"concurrency": a.limit,
+			"previous":    previous,
+			"reason":      reason,
+		}).Info("concurrency decreased")
+
+		return
+	}
+
+	if a.sinceIncrease < a.limit || a.limit >= a.max {
+		return
+	}
+
+	a.limit++
+	a.sinceIncrease = 0
+
+	a.setMetric()
+
+	a.logger.WithFields(logrus.Fields{
+		"stage":       a.stage,
+		"concurrency": a.limit,
+		"previous":    previous,
+	}).Debug("concurrency increased")
+}
+
+// congested returns the reason the concurrency is to be decreased, an empty string when not.
+//
+// The caller is expected to hold the lock.
+func (a *AdaptiveConcurrency) congested() string {
+	// at least half a window of samples are required to measure the rate, latencies.
+	minSamples := max(a.opts.Window/2, 1) // nolint:gomnd // half a window
+
+	if a.errors.count >= minSamples && a.errors.mean() > a.opts.MaxErrorRate {
+		return "error rate exceeded"
+	}
+
+	for kind, latencies := range a.latencies {
+		threshold := a.opts.LatencyThresholds[kind]
+		if latencies.count >= minSamples && latencies.mean() > threshold.Seconds() {
+			return kind + " latency exceeded"
+		}
+	}
+
+	return ""
+}
+
+// observeLatency records the BMC query time, for query kinds with a threshold configured.
+func (a *AdaptiveConcurrency) observeLatency(queryKind string, elapsed time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if latencies, exists := a.latencies[queryKind]; exists {
+		latencies.add(elapsed.Seconds())
+	}
+}
+
+// setMetric sets the effective concurrency metric, the caller is expected to hold the lock.
+func (a *AdaptiveConcurrency) setMetric() {
+	metrics.EffectiveConcurrency.With(prometheus.Labels{"stage": a.stage}).Set(float64(a.limit))
+}
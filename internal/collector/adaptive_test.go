@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
+	"github.com/metal-toolbox/alloy/internal/metrics"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_AdaptiveConcurrency(t *testing.T) {
+	// collections steps complete the count of collections with the errors set on the asset,
+	// with the BMC query time of the query kind observed for each collection when set.
+	type step struct {
+		count     int
+		errs      []model.CollectorError
+		queryKind string
+		elapsed   time.Duration
+		expected  int
+	}
+
+	testcases := []struct {
+		name        string
+		concurrency int
+		closed      bool
+		steps       []step
+	}{
+		{
+			"increased by one for each concurrency count of collections, up to the upper bound",
+			10,
+			false,
+			[]step{
+				{count: 10, expected: 11},
+				{count: 500, expected: 20},
+			},
+		},
+		{
+			"decreased on errors, down to the lower bound",
+			16,
+			false,
+			[]step{
+				// the error rate is measured once half a window of collections completed
+				{count: 4, errs: []model.CollectorError{outofband.LoginError}, expected: 16},
+				{count: 1, errs: []model.CollectorError{outofband.InventoryError}, expected: 8},
+				// not decreased again, until a window of collections completed at the decreased concurrency.
+				{count: 9, errs: []model.CollectorError{outofband.LoginError}, expected: 8},
+				{count: 1, errs: []model.CollectorError{outofband.LoginError}, expected: 4},
+				{count: 50, errs: []model.CollectorError{outofband.LoginError}, expected: 2},
+				// collections skipped with an open circuit are not recorded
+				{count: 50, errs: []model.CollectorError{outofband.CircuitOpenError}, expected: 2},
+			},
+		},
+		{
+			"decreased on latency",
+			16,
+			false,
+			[]step{
+				// query kinds without a threshold are not measured
+				{count: 5, queryKind: "inventory", elapsed: time.Minute, expected: 16},
+				{count: 4, queryKind: "conn_open", elapsed: 20 * time.Second, expected: 16},
+				{count: 1, queryKind: "conn_open", elapsed: 20 * time.Second, expected: 8},
+			},
+		},
+		{
+			"latency not measured once closed",
+			16,
+			true,
+			[]step{
+				{count: 10, queryKind: "conn_open", elapsed: 20 * time.Second, expected: 16},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			adaptive := NewAdaptiveConcurrency(
+				app.AdaptiveConcurrencyOptions{
+					Enabled:           true,
+					Min:               2,
+					Max:               20,
+					Window:            10,
+					MaxErrorRate:      0.3,
+					LatencyThresholds: map[string]time.Duration{"conn_open": 10 * time.Second},
+					DecreaseFactor:    0.5,
+				},
+				tc.concurrency,
+				"collector",
+				logrus.New(),
+			)
+			defer adaptive.Close()
+
+			assert.Equal(t, tc.concurrency, adaptive.Limit())
+
+			if tc.closed {
+				adaptive.Close()
+			}
+
+			for _, step := range tc.steps {
+				for idx := 0; idx < step.count; idx++ {
+					if step.queryKind != "" {
+						metrics.ObserveBMCQueryTimeSummary("dell", "r6515", step.queryKind, time.Now().Add(-step.elapsed))
+					}
+
+					asset := &model.Asset{ID: "1", Errors: map[string]string{}}
+					for _, err := range step.errs {
+						asset.Errors[string(err)] = "error"
+					}
+
+					adaptive.Done(asset)
+				}
+
+				assert.Equal(t, step.expected, adaptive.Limit())
+			}
+		})
+	}
+}
@@ -24,7 +24,12 @@ var (
 
 // AssetIterator holds methods to recurse over assets in a store and return them over the asset channel.
 type AssetIterator struct {
-	store   store.Repository
+	store    store.Repository
+	selector *model.AssetSelector
+	// checkpoint when set records the progress of IterInBatches, to resume from when interrupted.
+	checkpoint *Checkpointer
+	// shard when set selects the assets owned by this replica, when sharded across replicas.
+	shard   *Shard
 	assetCh chan *model.Asset
 	logger  *logrus.Logger
 }
@@ -34,7 +39,13 @@ type AssetIterator struct {
 // The returned AssetIterator will recurse over all assets in the store and send them over the asset channel,
 // The caller of this method should invoke AssetChannel() to retrieve the channel to read assets from.
 func NewAssetIterator(repository store.Repository, logger *logrus.Logger) *AssetIterator {
-	return &AssetIterator{store: repository, logger: logger, assetCh: make(chan *model.Asset, 1)}
+	return NewAssetIteratorWithSelector(repository, nil, logger)
+}
+
+// NewAssetIteratorWithSelector is a constructor method that returns an AssetIterator
+// which recurses over the assets in the store selected by the selector, all assets are selected when the selector is nil.
+func NewAssetIteratorWithSelector(repository store.Repository, selector *model.AssetSelector, logger *logrus.Logger) *AssetIterator {
+	return &AssetIterator{store: repository, selector: selector, logger: logger, assetCh: make(chan *model.Asset, 1)}
 }
 
 // Channel returns the channel to read assets from when the fetcher is invoked through its Iter* method.
@@ -44,6 +55,12 @@ func (s *AssetIterator) Channel() <-chan *model.Asset {
 
 // IterInBatches queries the store for assets in batches, returning them over the assetCh
 //
+// The assets are listed without their BMC credentials, the collector looks up the credentials of each asset it collects.
+// With a shard set, the shard is pinned for the sweep and the replicas joining or leaving apply from the following sweep.
+//
+// With a checkpointer set, the progress is recorded in a checkpoint and an interrupted sweep
+// is resumed from the first page with assets not yet collected.
+//
 // nolint:gocyclo // for now it makes sense to have the iter method logic in one method
 func (s *AssetIterator) IterInBatches(ctx context.Context, batchSize int, pauser *Pauser) {
 	defer close(s.assetCh)
@@ -53,7 +70,18 @@ func (s *AssetIterator) IterInBatches(ctx context.Context, batchSize int, pauser
 
 	defer span.End()
 
-	assets, total, err := s.store.AssetsByOffsetLimit(ctx, 1, batchSize)
+	s.shard = s.shard.Pinned()
+
+	// idle when pause flag is set and context isn't canceled.
+	for pauser.Value() && ctx.Err() == nil {
+		time.Sleep(1 * time.Second)
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	assets, total, err := store.ListAssets(ctx, s.store, s.selector, 1, batchSize)
 	if err != nil {
 		// count serverService query errors
 		if errors.Is(err, ErrFetcherQuery) {
@@ -68,20 +96,21 @@ func (s *AssetIterator) IterInBatches(ctx context.Context, batchSize int, pauser
 	// count assets retrieved
 	metrics.FleetDBAPIAssetsRetrieved.With(stageLabelFetcher).Add(float64(len(assets)))
 
-	// submit the assets collected in the first request
-	for _, asset := range assets {
-		s.assetCh <- asset
+	startPage := s.checkpoint.Start(ctx, total, batchSize, s.selector, s.shard)
 
-		// count assets sent to the collector
-		metrics.AssetsSent.With(stageLabelFetcher).Inc()
+	// submit the assets collected in the first request, unless resuming from a later page
+	if startPage == 1 {
+		s.send(ctx, s.checkpoint.Listed(1, s.shard.Filter(assets)), pauser)
 	}
 
 	// all assets fetched in first query
 	if len(assets) == total || total <= batchSize {
+		s.checkpoint.Swept()
+
 		return
 	}
 
-	iterations := total / batchSize
+	iterations := (total + batchSize - 1) / batchSize
 	limit := batchSize
 
 	s.logger.WithFields(logrus.Fields{
@@ -90,8 +119,8 @@ func (s *AssetIterator) IterInBatches(ctx context.Context, batchSize int, pauser
 		"limit":      limit,
 	}).Trace()
 
-	// continue from offset 2
-	for offset := 2; offset < iterations+1; offset++ {
+	// continue from offset 2, or the checkpoint page
+	for offset := max(2, startPage); offset < iterations+1; offset++ {
 		// idle when pause flag is set and context isn't canceled.
 		for pauser.Value() && ctx.Err() == nil {
 			time.Sleep(1 * time.Second)
@@ -101,16 +130,18 @@ func (s *AssetIterator) IterInBatches(ctx context.Context, batchSize int, pauser
 		if ctx.Err() != nil {
 			s.logger.WithError(err).Error("aborting collection")
 
-			break
+			return
 		}
 
-		assets, _, err := s.store.AssetsByOffsetLimit(ctx, offset, limit)
+		assets, _, err := store.ListAssets(ctx, s.store, s.selector, offset, limit)
 		if err != nil {
 			if errors.Is(err, ErrFetcherQuery) {
 				metrics.FleetDBAPIQueryErrorCount.With(stageLabelFetcher).Inc()
 			}
 
 			s.logger.WithError(err).Warn(ErrFetcherQuery)
+
+			return
 		}
 
 		s.logger.WithFields(logrus.Fields{
@@ -120,18 +151,50 @@ func (s *AssetIterator) IterInBatches(ctx context.Context, batchSize int, pauser
 			"got":    len(assets),
 		}).Trace()
 
+		// the selector applied to the assets listed may select none of the assets in a page.
 		if len(assets) == 0 {
-			break
+			s.checkpoint.Listed(offset, nil)
+
+			continue
 		}
 
 		// count assets retrieved
 		metrics.FleetDBAPIAssetsRetrieved.With(stageLabelFetcher).Add(float64(len(assets)))
 
-		for _, asset := range assets {
-			s.assetCh <- asset
+		s.send(ctx, s.checkpoint.Listed(offset, s.shard.Filter(assets)), pauser)
+	}
+
+	s.checkpoint.Swept()
+}
+
+// IterAssets returns the given assets over the assetCh,
+// the assets are expected to have their ID set, they are looked up in the store by the collector.
+//
+// The pauser is checked before each asset is sent, to throttle the assets sent to the collection rate.
+func (s *AssetIterator) IterAssets(ctx context.Context, assets []*model.Asset, pauser *Pauser) {
+	defer close(s.assetCh)
+
+	s.send(ctx, assets, pauser)
+}
 
-			// count assets sent to collector
-			metrics.AssetsSent.With(stageLabelFetcher).Inc()
+// send returns the given assets over the assetCh, checking the pauser before each asset is sent.
+func (s *AssetIterator) send(ctx context.Context, assets []*model.Asset, pauser *Pauser) {
+	for _, asset := range assets {
+		// idle when pause flag is set and context isn't canceled.
+		for pauser.Value() && ctx.Err() == nil {
+			time.Sleep(100 * time.Millisecond) // nolint:gomnd // the interval is clear as is
 		}
+
+		// context canceled
+		if ctx.Err() != nil {
+			s.logger.WithError(ctx.Err()).Error("aborting collection")
+
+			return
+		}
+
+		s.assetCh <- asset
+
+		// count assets sent to the collector
+		metrics.AssetsSent.With(stageLabelFetcher).Inc()
 	}
 }
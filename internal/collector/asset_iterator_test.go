@@ -2,9 +2,11 @@ package collector
 
 import (
 	"context"
+	"strconv"
 	"sync"
 	"testing"
 
+	"github.com/metal-toolbox/alloy/internal/model"
 	"github.com/metal-toolbox/alloy/internal/store"
 	"github.com/metal-toolbox/alloy/internal/store/mock"
 	"github.com/sirupsen/logrus"
@@ -12,6 +14,52 @@ import (
 	"go.uber.org/goleak"
 )
 
+// assetsStore is a store that lists the given assets.
+type assetsStore struct {
+	assets []*model.Asset
+}
+
+func (s *assetsStore) Kind() model.StoreKind {
+	return model.StoreKindMock
+}
+
+func (s *assetsStore) AssetByID(_ context.Context, assetID string, _ bool) (*model.Asset, error) {
+	for _, asset := range s.assets {
+		if asset.ID == assetID {
+			return asset, nil
+		}
+	}
+
+	return &model.Asset{ID: assetID}, nil
+}
+
+func (s *assetsStore) AssetsByOffsetLimit(_ context.Context, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	start := (offset - 1) * limit
+	if start >= len(s.assets) {
+		return nil, len(s.assets), nil
+	}
+
+	end := start + limit
+	if end > len(s.assets) {
+		end = len(s.assets)
+	}
+
+	return s.assets[start:end], len(s.assets), nil
+}
+
+func (s *assetsStore) AssetUpdate(_ context.Context, _ *model.Asset) error {
+	return nil
+}
+
+func assetIDs(assets []*model.Asset) []string {
+	ids := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		ids = append(ids, asset.ID)
+	}
+
+	return ids
+}
+
 func newTestAssetIterator(repository store.Repository) *AssetIterator {
 	logger := logrus.New()
 	// nolint: gocritic // comment kept for reference
@@ -92,3 +140,41 @@ func Test_IterInBatches(t *testing.T) {
 		})
 	}
 }
+
+func Test_IterInBatches_Selector(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	// the selected assets are listed after a page with none of the assets selected.
+	assets := make([]*model.Asset, 0, 9)
+	for idx := 0; idx < 9; idx++ {
+		vendor := "dell"
+		if idx == 1 || idx > 6 {
+			vendor = "supermicro"
+		}
+
+		assets = append(assets, &model.Asset{ID: strconv.Itoa(idx), Vendor: vendor})
+	}
+
+	selector := &model.AssetSelector{Vendor: "supermicro"}
+	assetIterator := NewAssetIteratorWithSelector(&assetsStore{assets: assets}, selector, logrus.New())
+
+	var got []*model.Asset
+
+	var syncWG sync.WaitGroup
+
+	syncWG.Add(1)
+
+	go func() {
+		defer syncWG.Done()
+
+		for asset := range assetIterator.Channel() {
+			got = append(got, asset)
+		}
+	}()
+
+	assetIterator.IterInBatches(context.TODO(), 3, NewPauser())
+	syncWG.Wait()
+
+	assert.Equal(t, []string{"1", "7", "8"}, assetIDs(got))
+}
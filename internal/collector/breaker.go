@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/metrics"
+)
+
+// CircuitState is the state of the circuit for a BMC.
+type CircuitState struct {
+	// Failures is the count of consecutive BMC login failures.
+	Failures int `json:"failures"`
+
+	// OpenUntil is set when the circuit is open, collection is skipped until this time.
+	OpenUntil time.Time `json:"open_until,omitempty"`
+}
+
+// CircuitStore persists the circuit state for each BMC.
+type CircuitStore interface {
+	// Get returns the circuit state for the key, nil is returned when the key does not exist.
+	Get(ctx context.Context, key string) (*CircuitState, error)
+
+	// Update applies the update to the circuit state for the key and returns the updated state,
+	// a zero state is updated when the key does not exist.
+	//
+	// The update is atomic, stores shared by Alloy instances retry the update on a concurrent update of the key.
+	Update(ctx context.Context, key string, update func(state *CircuitState)) (*CircuitState, error)
+}
+
+// CircuitBreaker skips collection for BMCs that failed consecutive collections until a cool-down period passes.
+//
+// The circuit state is keyed by the BMC address and kept in a CircuitStore,
+// which can be shared with other Alloy instances.
+type CircuitBreaker struct {
+	store     CircuitStore
+	logger    *logrus.Logger
+	open      map[string]time.Time
+	now       func() time.Time
+	threshold int
+	cooldown  time.Duration
+	mu        sync.Mutex
+}
+
+// NewCircuitBreaker returns a CircuitBreaker with its state kept in the given store.
+func NewCircuitBreaker(store CircuitStore, cfg app.CircuitBreakerOptions, logger *logrus.Logger) *CircuitBreaker {
+	return &CircuitBreaker{
+		store:     store,
+		logger:    logger,
+		open:      make(map[string]time.Time),
+		now:       time.Now,
+		threshold: cfg.FailureThreshold,
+		cooldown:  cfg.Cooldown,
+	}
+}
+
+// Allow returns false with the time until which the circuit is open, if collection is to be skipped for the BMC.
+//
+// Once the cool-down period passes, collection is allowed and the circuit is closed on a success,
+// or opened again on a failure.
+func (b *CircuitBreaker) Allow(ctx context.Context, key string) (allow bool, openUntil time.Time) {
+	state, err := b.store.Get(ctx, key)
+	if err != nil {
+		b.logger.WithError(err).WithField("bmc", key).Warn("circuit state query error")
+
+		// collection is not skipped when the circuit state is unknown.
+		return true, time.Time{}
+	}
+
+	if state == nil || !b.now().Before(state.OpenUntil) {
+		b.setOpen(key, time.Time{})
+
+		return true, time.Time{}
+	}
+
+	b.setOpen(key, state.OpenUntil)
+
+	return false, state.OpenUntil
+}
+
+// Success closes the circuit for the BMC.
+func (b *CircuitBreaker) Success(ctx context.Context, key string) {
+	state, err := b.store.Get(ctx, key)
+	if err != nil {
+		b.logger.WithError(err).WithField("bmc", key).Warn("circuit state query error")
+	}
+
+	b.setOpen(key, time.Time{})
+
+	// nothing to reset
+	if err == nil && (state == nil || state.Failures == 0) {
+		return
+	}
+
+	if _, err := b.store.Update(ctx, key, func(state *CircuitState) { *state = CircuitState{} }); err != nil {
+		b.logger.WithError(err).WithField("bmc", key).Warn("circuit state update error")
+	}
+}
+
+// Failure counts a failure for the BMC and opens its circuit once the failure threshold is reached.
+func (b *CircuitBreaker) Failure(ctx context.Context, key string) {
+	state, err := b.store.Update(ctx, key, func(state *CircuitState) {
+		state.Failures++
+
+		if state.Failures >= b.threshold {
+			state.OpenUntil = b.now().Add(b.cooldown)
+		}
+	})
+	if err != nil {
+		b.logger.WithError(err).WithField("bmc", key).Warn("circuit state update error")
+
+		return
+	}
+
+	if state.Failures >= b.threshold {
+		b.logger.WithFields(logrus.Fields{
+			"bmc":       key,
+			"failures":  state.Failures,
+			"openUntil": state.OpenUntil.Format(time.RFC3339),
+		}).Warn("BMC circuit opened, collection skipped until the cool-down period passes")
+	}
+
+	b.setOpen(key, state.OpenUntil)
+}
+
+// setOpen tracks the circuits this instance found open, and updates the open circuits metric.
+func (b *CircuitBreaker) setOpen(key string, openUntil time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.now().Before(openUntil) {
+		b.open[key] = openUntil
+	} else {
+		delete(b.open, key)
+	}
+
+	// purge circuits that were not queried once their cool-down passed.
+	for k, until := range b.open {
+		if !b.now().Before(until) {
+			delete(b.open, k)
+		}
+	}
+
+	metrics.BMCCircuitsOpen.Set(float64(len(b.open)))
+}
+
+// memoryCircuitStore is a CircuitStore that keeps the circuit state in memory.
+type memoryCircuitStore struct {
+	states map[string]CircuitState
+	mu     sync.Mutex
+}
+
+// NewMemoryCircuitStore returns a CircuitStore that keeps the circuit state in memory.
+func NewMemoryCircuitStore() CircuitStore {
+	return &memoryCircuitStore{states: make(map[string]CircuitState)}
+}
+
+func (m *memoryCircuitStore) Get(_ context.Context, key string) (*CircuitState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, exists := m.states[key]
+	if !exists {
+		return nil, nil // nolint:nilnil // a key that does not exist is not an error
+	}
+
+	return &state, nil
+}
+
+func (m *memoryCircuitStore) Update(_ context.Context, key string, update func(state *CircuitState)) (*CircuitState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := m.states[key]
+	update(&state)
+	m.states[key] = state
+
+	return &state, nil
+}
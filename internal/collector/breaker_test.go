@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_CircuitBreaker(t *testing.T) {
+	now := time.Now()
+
+	breaker := NewCircuitBreaker(
+		NewMemoryCircuitStore(),
+		app.CircuitBreakerOptions{FailureThreshold: 2, Cooldown: time.Hour},
+		logrus.New(),
+	)
+	breaker.now = func() time.Time { return now }
+
+	ctx := context.TODO()
+	key := "10.0.0.1"
+
+	allow, _ := breaker.Allow(ctx, key)
+	assert.True(t, allow)
+
+	// circuit remains closed below the threshold
+	breaker.Failure(ctx, key)
+	allow, _ = breaker.Allow(ctx, key)
+	assert.True(t, allow)
+
+	// circuit opens on reaching the threshold
+	breaker.Failure(ctx, key)
+	allow, openUntil := breaker.Allow(ctx, key)
+	assert.False(t, allow)
+	assert.Equal(t, now.Add(time.Hour), openUntil)
+	assert.Len(t, breaker.open, 1)
+
+	// other BMCs are not affected
+	allow, _ = breaker.Allow(ctx, "10.0.0.2")
+	assert.True(t, allow)
+
+	// collection is attempted once the cool-down passes
+	now = now.Add(time.Hour)
+	allow, _ = breaker.Allow(ctx, key)
+	assert.True(t, allow)
+	assert.Len(t, breaker.open, 0)
+
+	// circuit re-opens on a failure
+	breaker.Failure(ctx, key)
+	allow, _ = breaker.Allow(ctx, key)
+	assert.False(t, allow)
+
+	// circuit is closed on a success
+	breaker.Success(ctx, key)
+	allow, _ = breaker.Allow(ctx, key)
+	assert.True(t, allow)
+
+	state, err := breaker.store.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, state.Failures)
+}
+
+// loginFailQueryor is a device.Queryor that fails with a login error.
+type loginFailQueryor struct {
+	queries int
+}
+
+func (q *loginFailQueryor) Inventory(_ context.Context, asset *model.Asset) error {
+	q.queries++
+	asset.AppendError(outofband.LoginError, "unauthorized")
+
+	return outofband.ErrConnect
+}
+
+func (q *loginFailQueryor) BiosConfiguration(_ context.Context, _ *model.Asset) error {
+	return nil
+}
+
+func Test_QueryOutofbandWithBreaker(t *testing.T) {
+	queryor := &loginFailQueryor{}
+
+	c := &DeviceCollector{
+		queryor: queryor,
+		breaker: NewCircuitBreaker(
+			NewMemoryCircuitStore(),
+			app.CircuitBreakerOptions{FailureThreshold: 2, Cooldown: time.Hour},
+			logrus.New(),
+		),
+		log: logrus.New(),
+	}
+
+	for i := 0; i < 3; i++ {
+		asset := &model.Asset{BMCAddress: &model.BMCAddress{Host: "10.0.0.1"}, Errors: map[string]string{}}
+
+		err := c.queryOutofbandWithBreaker(context.TODO(), asset, &model.Asset{})
+		assert.NotNil(t, err)
+
+		// the third collection is skipped
+		if i == 2 {
+			assert.ErrorIs(t, err, ErrCircuitOpen)
+			assert.True(t, asset.HasError(outofband.CircuitOpenError))
+		}
+	}
+
+	assert.Equal(t, 2, queryor.queries)
+}
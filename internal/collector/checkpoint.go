@@ -0,0 +1,358 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/alloy/internal/helpers"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+var (
+	ErrCheckpoint = errors.New("checkpoint error")
+)
+
+// Checkpoint records the progress of a sweep over the assets in the store by the AssetIterator.
+type Checkpoint struct {
+	// Page is the first page (offset) of the sweep with assets not yet collected.
+	Page int `json:"page"`
+
+	// BatchSize is the page size the sweep lists assets with.
+	BatchSize int `json:"batch_size"`
+
+	// Total is the count of assets in the store when the sweep started.
+	Total int `json:"total"`
+
+	// Selector is the asset selector the sweep lists assets with, in its canonical form.
+	Selector string `json:"selector,omitempty"`
+
+	// Shard is the fingerprint of the shard the sweep collects assets for, when sharded across replicas.
+	Shard string `json:"shard,omitempty"`
+
+	// Completed lists the assets collected in the Page and the pages following it.
+	Completed []string `json:"completed,omitempty"`
+
+	// UpdatedAt is the time the checkpoint was last updated.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CheckpointStore persists the sweep checkpoint.
+type CheckpointStore interface {
+	// Get returns the checkpoint, nil is returned when none exists.
+	Get(ctx context.Context) (*Checkpoint, error)
+
+	// Put sets the checkpoint.
+	Put(ctx context.Context, checkpoint *Checkpoint) error
+
+	// Delete removes the checkpoint.
+	Delete(ctx context.Context) error
+}
+
+// Checkpointer tracks the progress of a sweep over the assets in the store and persists it in a CheckpointStore,
+// for a sweep that was interrupted to be resumed from the checkpoint.
+//
+// The checkpoint is removed once the sweep completes, a checkpoint is not resumed from
+// when the count of assets in the store changed by more than the maxTotalChange fraction.
+type Checkpointer struct {
+	store          CheckpointStore
+	logger         *logrus.Logger
+	maxTotalChange float64
+	resume         bool
+
+	// state is the checkpoint persisted.
+	state Checkpoint
+	// completed are the assets collected, the page of the asset is set in pages once its listed.
+	completed map[string]bool
+	// pending are the assets listed and not yet collected, by page.
+	pending map[int]map[string]bool
+	pages   map[string]int
+	// listed is the last page listed.
+	listed int
+	// swept is set once all pages were listed.
+	swept bool
+	mu    sync.Mutex
+}
+
+// NewCheckpointer returns a Checkpointer that persists the checkpoint in the store,
+// when resume is set, the first sweep resumes from the checkpoint in the store.
+func NewCheckpointer(store CheckpointStore, maxTotalChange float64, resume bool, logger *logrus.Logger) *Checkpointer {
+	return &Checkpointer{store: store, maxTotalChange: maxTotalChange, resume: resume, logger: logger}
+}
+
+// Start begins a sweep over the given total of assets listed in pages of the batch size,
+// selected by the selector and owned by the shard, and returns the page the sweep is to start from.
+//
+// The first sweep resumes from the checkpoint in the store when the checkpointer was initialized to resume,
+// the following sweeps start from the first page.
+func (c *Checkpointer) Start(ctx context.Context, total, batchSize int, selector *model.AssetSelector, shard *Shard) int {
+	if c == nil {
+		return 1
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = Checkpoint{
+		Page:      1,
+		BatchSize: batchSize,
+		Total:     total,
+		Selector:  selector.String(),
+		Shard:     shard.Fingerprint(),
+	}
+	c.completed = make(map[string]bool)
+	c.pending = make(map[int]map[string]bool)
+	c.pages = make(map[string]int)
+	c.listed = 0
+	c.swept = false
+
+	if !c.resume {
+		return 1
+	}
+
+	// the checkpoint is resumed from on the first sweep only.
+	c.resume = false
+
+	checkpoint, err := c.store.Get(ctx)
+	if err != nil {
+		c.logger.WithError(err).Warn("checkpoint query error, sweep starts from the first page")
+
+		return 1
+	}
+
+	if checkpoint == nil {
+		c.logger.Info("no checkpoint to resume from, sweep starts from the first page")
+
+		return 1
+	}
+
+	if reason := c.invalid(checkpoint); reason != "" {
+		c.logger.WithFields(logrus.Fields{
+			"page":  checkpoint.Page,
+			"total": checkpoint.Total,
+		}).Info("checkpoint invalidated, sweep starts from the first page: " + reason)
+
+		if err := c.store.Delete(ctx); err != nil {
+			c.logger.WithError(err).Warn("checkpoint delete error")
+		}
+
+		return 1
+	}
+
+	c.state.Page = checkpoint.Page
+	for _, assetID := range checkpoint.Completed {
+		c.completed[assetID] = true
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"page":      checkpoint.Page,
+		"completed": len(checkpoint.Completed),
+		"updatedAt": checkpoint.UpdatedAt.Format(time.RFC3339),
+	}).Info("sweep resumed from checkpoint")
+
+	return c.state.Page
+}
+
+// invalid returns the reason the checkpoint cannot be resumed from in the sweep started,
+// an empty string when it can be - the caller is expected to hold the lock.
+func (c *Checkpointer) invalid(checkpoint *Checkpoint) string {
+	total, batchSize := c.state.Total, c.state.BatchSize
+
+	if checkpoint.BatchSize != batchSize {
+		return "batch size changed"
+	}
+
+	if checkpoint.Selector != c.state.Selector {
+		return "asset selector changed"
+	}
+
+	// the pages listed before the checkpoint page may include assets now owned by this replica.
+	if checkpoint.Shard != c.state.Shard {
+		return "shard changed"
+	}
+
+	if checkpoint.Page < 1 || (checkpoint.Page-1)*batchSize >= total {
+		return "page out of range"
+	}
+
+	if checkpoint.Total == 0 ||
+		math.Abs(float64(total-checkpoint.Total))/float64(checkpoint.Total) > c.maxTotalChange {
+		return "store asset count changed"
+	}
+
+	return ""
+}
+
+// Listed records the assets listed in the page, and returns the assets to be collected,
+// the assets collected in the sweep resumed from are not returned.
+func (c *Checkpointer) Listed(page int, assets []*model.Asset) []*model.Asset {
+	if c == nil {
+		return assets
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	collect := make([]*model.Asset, 0, len(assets))
+	pending := make(map[string]bool, len(assets))
+
+	for _, asset := range assets {
+		c.pages[asset.ID] = page
+
+		if !c.completed[asset.ID] {
+			pending[asset.ID] = true
+			collect = append(collect, asset)
+		}
+	}
+
+	c.pending[page] = pending
+	c.listed = page
+
+	c.advance()
+
+	return collect
+}
+
+// Swept records all the pages were listed.
+func (c *Checkpointer) Swept() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.swept = true
+}
+
+// Done records the asset was collected, or deferred to a following sweep, and updates the checkpoint in the store.
+func (c *Checkpointer) Done(ctx context.Context, assetID string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.completed[assetID] = true
+
+	if page, exists := c.pages[assetID]; exists {
+		delete(c.pending[page], assetID)
+	}
+
+	c.advance()
+	c.put(ctx)
+}
+
+// Finish removes the checkpoint from the store when the sweep completed.
+func (c *Checkpointer) Finish(ctx context.Context) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the sweep was interrupted, the checkpoint is retained.
+	if !c.swept || c.state.Page <= c.listed {
+		return
+	}
+
+	if err := c.store.Delete(ctx); err != nil {
+		c.logger.WithError(err).Warn("checkpoint delete error")
+
+		return
+	}
+
+	c.logger.WithField("total", c.state.Total).Debug("sweep completed, checkpoint removed")
+}
+
+// advance moves the checkpoint page past the pages with all assets collected,
+// the completed assets in those pages are no longer included in the checkpoint.
+//
+// The caller is expected to hold the lock.
+func (c *Checkpointer) advance() {
+	for c.state.Page <= c.listed && len(c.pending[c.state.Page]) == 0 {
+		delete(c.pending, c.state.Page)
+		c.state.Page++
+	}
+
+	for assetID, page := range c.pages {
+		if page < c.state.Page {
+			delete(c.completed, assetID)
+			delete(c.pages, assetID)
+		}
+	}
+}
+
+// put writes the checkpoint to the store, the caller is expected to hold the lock.
+func (c *Checkpointer) put(ctx context.Context) {
+	c.state.Completed = make([]string, 0, len(c.completed))
+	for assetID := range c.completed {
+		c.state.Completed = append(c.state.Completed, assetID)
+	}
+
+	sort.Strings(c.state.Completed)
+
+	c.state.UpdatedAt = time.Now().UTC()
+
+	if err := c.store.Put(ctx, &c.state); err != nil {
+		c.logger.WithError(err).Warn("checkpoint update error")
+	}
+}
+
+// fileCheckpointStore is a CheckpointStore that keeps the checkpoint in a local file.
+type fileCheckpointStore struct {
+	filename string
+}
+
+// NewFileCheckpointStore returns a CheckpointStore that keeps the checkpoint in the given file.
+func NewFileCheckpointStore(filename string) CheckpointStore {
+	return &fileCheckpointStore{filename: filename}
+}
+
+func (f *fileCheckpointStore) Get(_ context.Context) (*Checkpoint, error) {
+	b, err := os.ReadFile(f.filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil // nolint:nilnil // a checkpoint that does not exist is not an error
+		}
+
+		return nil, errors.Wrap(ErrCheckpoint, err.Error())
+	}
+
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(b, checkpoint); err != nil {
+		return nil, errors.Wrap(ErrCheckpoint, f.filename+": "+err.Error())
+	}
+
+	return checkpoint, nil
+}
+
+func (f *fileCheckpointStore) Put(_ context.Context, checkpoint *Checkpoint) error {
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errors.Wrap(ErrCheckpoint, err.Error())
+	}
+
+	if err := helpers.WriteFileAtomic(f.filename, b); err != nil {
+		return errors.Wrap(ErrCheckpoint, err.Error())
+	}
+
+	return nil
+}
+
+func (f *fileCheckpointStore) Delete(_ context.Context) error {
+	if err := os.Remove(f.filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(ErrCheckpoint, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_Checkpointer(t *testing.T) {
+	ctx := context.TODO()
+	filename := filepath.Join(t.TempDir(), "checkpoint.json")
+	store := NewFileCheckpointStore(filename)
+	assets := []*model.Asset{{ID: "0"}, {ID: "1"}, {ID: "2"}, {ID: "3"}}
+
+	// a sweep interrupted on the second page
+	checkpointer := NewCheckpointer(store, 0.1, false, logrus.New())
+	assert.Equal(t, 1, checkpointer.Start(ctx, 4, 2, nil, nil))
+	assert.Len(t, checkpointer.Listed(1, assets[:2]), 2)
+	assert.Len(t, checkpointer.Listed(2, assets[2:]), 2)
+
+	checkpointer.Done(ctx, "1")
+
+	checkpoint, err := store.Get(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 1, checkpoint.Page)
+	assert.Equal(t, []string{"1"}, checkpoint.Completed)
+
+	// the completed assets of pages collected are not retained
+	checkpointer.Done(ctx, "0")
+	checkpointer.Done(ctx, "3")
+
+	checkpoint, err = store.Get(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 2, checkpoint.Page)
+	assert.Equal(t, 2, checkpoint.BatchSize)
+	assert.Equal(t, 4, checkpoint.Total)
+	assert.Equal(t, []string{"3"}, checkpoint.Completed)
+
+	// the checkpoint is retained when the sweep was interrupted
+	checkpointer.Finish(ctx)
+	assert.FileExists(t, filename)
+
+	// the sweep is resumed from the second page, the assets completed are skipped
+	checkpointer = NewCheckpointer(store, 0.1, true, logrus.New())
+	assert.Equal(t, 2, checkpointer.Start(ctx, 4, 2, nil, nil))
+	assert.Equal(t, assets[2:3], checkpointer.Listed(2, assets[2:]))
+
+	checkpointer.Done(ctx, "2")
+	checkpointer.Swept()
+	checkpointer.Finish(ctx)
+	assert.NoFileExists(t, filename)
+
+	// following sweeps start from the first page
+	assert.Equal(t, 1, checkpointer.Start(ctx, 4, 2, nil, nil))
+}
+
+func Test_Checkpointer_Invalidated(t *testing.T) {
+	ctx := context.TODO()
+
+	dell := &model.AssetSelector{Vendor: "dell"}
+
+	sharded := NewShard("alloy/1")
+	sharded.SetReplicas([]string{"alloy/2"})
+
+	testcases := []struct {
+		name      string
+		total     int
+		batchSize int
+		selector  *model.AssetSelector
+		shard     *Shard
+		expected  int
+	}{
+		{"resumed", 105, 10, dell, nil, 5},
+		{"resumed by the only replica", 105, 10, dell, NewShard("alloy/1"), 5},
+		{"total changed", 120, 10, dell, nil, 1},
+		{"batch size changed", 100, 20, dell, nil, 1},
+		{"page out of range", 40, 10, dell, nil, 1},
+		{"selector changed", 100, 10, &model.AssetSelector{Vendor: "supermicro"}, nil, 1},
+		{"selector removed", 100, 10, nil, nil, 1},
+		{"shard changed", 100, 10, dell, sharded, 1},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "checkpoint.json")
+			store := NewFileCheckpointStore(filename)
+
+			require.Nil(t, store.Put(ctx, &Checkpoint{Page: 5, BatchSize: 10, Total: 100, Selector: "vendor=dell", Completed: []string{"foo"}}))
+
+			checkpointer := NewCheckpointer(store, 0.1, true, logrus.New())
+			assert.Equal(t, tc.expected, checkpointer.Start(ctx, tc.total, tc.batchSize, tc.selector, tc.shard))
+
+			// invalid checkpoints are removed
+			_, err := os.Stat(filename)
+			assert.Equal(t, tc.expected == 1, os.IsNotExist(err))
+		})
+	}
+}
+
+func Test_IterInBatches_Resume(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	ctx := context.TODO()
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoint.json"))
+
+	require.Nil(t, store.Put(ctx, &Checkpoint{Page: 2, BatchSize: 3, Total: 10, Completed: []string{"4"}}))
+
+	assets := make([]*model.Asset, 0, 10)
+	for idx := 0; idx < 10; idx++ {
+		assets = append(assets, &model.Asset{ID: strconv.Itoa(idx)})
+	}
+
+	assetIterator := NewAssetIterator(&assetsStore{assets: assets}, logrus.New())
+	assetIterator.checkpoint = NewCheckpointer(store, 0.1, true, logrus.New())
+
+	var got []*model.Asset
+
+	var syncWG sync.WaitGroup
+
+	syncWG.Add(1)
+
+	go func() {
+		defer syncWG.Done()
+
+		for asset := range assetIterator.Channel() {
+			got = append(got, asset)
+		}
+	}()
+
+	assetIterator.IterInBatches(ctx, 3, NewPauser())
+	syncWG.Wait()
+
+	// the sweep resumes from the second page, and includes the last partial page.
+	assert.Equal(t, []string{"3", "5", "6", "7", "8", "9"}, assetIDs(got))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -12,10 +13,12 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/metal-toolbox/alloy/internal/app"
 	"github.com/metal-toolbox/alloy/internal/device"
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
 	"github.com/metal-toolbox/alloy/internal/metrics"
 	"github.com/metal-toolbox/alloy/internal/model"
 	"github.com/metal-toolbox/alloy/internal/store"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -23,12 +26,14 @@ import (
 var (
 	ErrBiosCfgCollect   = errors.New("error collecting BIOS configuration")
 	ErrInventoryCollect = errors.New("error collecting inventory data")
+	ErrCircuitOpen      = errors.New("BMC circuit open, collection skipped")
 )
 
 // DeviceCollector holds attributes to collect inventory, bios configuration data from a single device.
 type DeviceCollector struct {
 	queryor    device.Queryor
 	repository store.Repository
+	breaker    *CircuitBreaker
 	kind       model.AppKind
 	log        *logrus.Logger
 }
@@ -40,7 +45,7 @@ func NewDeviceCollector(ctx context.Context, storeKind model.StoreKind, appKind
 		return nil, err
 	}
 
-	queryor, err := device.NewQueryor(appKind, logger)
+	queryor, err := device.NewQueryor(appKind, cfg, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -54,8 +59,16 @@ func NewDeviceCollector(ctx context.Context, storeKind model.StoreKind, appKind
 }
 
 // NewDeviceCollectorWithStore is a constructor method that accepts an initialized store repository - to return a inventory, bios configuration data collector.
-func NewDeviceCollectorWithStore(repository store.Repository, appKind model.AppKind, logger *logrus.Logger) (*DeviceCollector, error) {
-	queryor, err := device.NewQueryor(appKind, logger)
+//
+// The circuit breaker is optional, when set out of band collection is skipped for BMCs with an open circuit.
+func NewDeviceCollectorWithStore(
+	repository store.Repository,
+	appKind model.AppKind,
+	cfg *app.Configuration,
+	breaker *CircuitBreaker,
+	logger *logrus.Logger,
+) (*DeviceCollector, error) {
+	queryor, err := device.NewQueryor(appKind, cfg, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -64,6 +77,7 @@ func NewDeviceCollectorWithStore(repository store.Repository, appKind model.AppK
 		kind:       appKind,
 		queryor:    queryor,
 		repository: repository,
+		breaker:    breaker,
 		log:        logger,
 	}, nil
 }
@@ -73,32 +87,123 @@ func (c *DeviceCollector) CollectOutofband(ctx context.Context, asset *model.Ass
 	var errs error
 
 	// fetch existing asset information from inventory
-	existing, err := c.repository.AssetByID(ctx, asset.ID, true)
+	existing, err := c.assetWithBMCCredentials(ctx, asset)
 	if err != nil {
 		errs = multierror.Append(errs, err)
 
 		return errs
 	}
 
+	if errQuery := c.queryOutofbandWithBreaker(ctx, asset, existing); errQuery != nil {
+		errs = multierror.Append(errs, errQuery)
+	}
+
+	if outputStdout {
+		if err != nil {
+			return err
+		}
+
+		return c.prettyPrintJSON(asset)
+	}
+
+	if err := c.repository.AssetUpdate(ctx, asset); err != nil {
+		errs = multierror.Append(errs, err)
+
+		return errs
+	}
+
+	return nil
+}
+
+// QueryOutofband querys inventory and bios configuration data for a device through its BMC,
+// the collected data is set on the asset and not published to the store.
+func (c *DeviceCollector) QueryOutofband(ctx context.Context, asset *model.Asset) error {
+	existing, err := c.assetWithBMCCredentials(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return c.queryOutofband(ctx, asset, existing)
+}
+
+// assetWithBMCCredentials looks up the asset in the store and sets the attributes required for outofband collection.
+func (c *DeviceCollector) assetWithBMCCredentials(ctx context.Context, asset *model.Asset) (*model.Asset, error) {
+	existing, err := c.repository.AssetByID(ctx, asset.ID, true)
+	if err != nil {
+		return nil, err
+	}
+
 	if existing == nil {
-		return errors.Wrap(ErrInventoryCollect, "asset not found in store with required attributes")
+		return nil, errors.Wrap(ErrInventoryCollect, "asset not found in store with required attributes")
+	}
+
+	// copy over attributes required for outofband collection,
+	// the vendor, model select the BMC client options and are left as is when not known to the store.
+	if existing.Vendor != "" {
+		asset.Vendor = existing.Vendor
+	}
+
+	if existing.Model != "" {
+		asset.Model = existing.Model
 	}
 
-	// copy over attributes required for outofband collection
 	asset.BMCAddress = existing.BMCAddress
 	asset.BMCPassword = existing.BMCPassword
 	asset.BMCUsername = existing.BMCUsername
+	asset.BMCCredentials = existing.BMCCredentials
 	asset.Facility = existing.Facility
 	asset.Errors = make(map[string]string)
 
-	// collect inventory
-	if errInventory := c.queryor.Inventory(ctx, asset); errInventory != nil {
-		errs = multierror.Append(errs, errInventory)
+	return existing, nil
+}
+
+// queryOutofband with the circuit breaker set, skips the collection when the BMC circuit is open
+// and records the BMC login failure or success.
+func (c *DeviceCollector) queryOutofbandWithBreaker(ctx context.Context, asset, existing *model.Asset) error {
+	if c.breaker == nil || asset.BMCAddress == nil {
+		return c.queryOutofband(ctx, asset, existing)
 	}
 
-	// collect BIOS configurations
-	if errBiosCfg := c.queryor.BiosConfiguration(ctx, asset); errBiosCfg != nil {
-		errs = multierror.Append(errs, errBiosCfg)
+	key := asset.BMCAddress.String()
+
+	if allow, openUntil := c.breaker.Allow(ctx, key); !allow {
+		asset.AppendError(outofband.CircuitOpenError, "collection skipped, BMC circuit open until "+openUntil.Format(time.RFC3339))
+		metrics.IncrementBMCQueryErrorCount(existing.Vendor, existing.Model, "circuit_open", string(outofband.ErrorClassTransient))
+
+		return errors.Wrap(ErrCircuitOpen, key)
+	}
+
+	err := c.queryOutofband(ctx, asset, existing)
+
+	// the circuit counts BMCs that could not be logged into, query errors are not counted.
+	if asset.HasError(outofband.LoginError) {
+		c.breaker.Failure(ctx, key)
+	} else {
+		c.breaker.Success(ctx, key)
+	}
+
+	return err
+}
+
+func (c *DeviceCollector) queryOutofband(ctx context.Context, asset, existing *model.Asset) error {
+	var errs error
+
+	switch queryor := c.queryor.(type) {
+	case device.SessionQueryor:
+		// collect inventory, BIOS configurations in a single BMC session
+		if errCollect := queryor.Collect(ctx, asset); errCollect != nil {
+			errs = multierror.Append(errs, errCollect)
+		}
+	default:
+		// collect inventory
+		if errInventory := c.queryor.Inventory(ctx, asset); errInventory != nil {
+			errs = multierror.Append(errs, errInventory)
+		}
+
+		// collect BIOS configurations
+		if errBiosCfg := c.queryor.BiosConfiguration(ctx, asset); errBiosCfg != nil {
+			errs = multierror.Append(errs, errBiosCfg)
+		}
 	}
 
 	// set collected inventory attributes based on inventory data
@@ -115,21 +220,7 @@ func (c *DeviceCollector) CollectOutofband(ctx context.Context, asset *model.Ass
 		asset.Serial = existing.Serial
 	}
 
-	if outputStdout {
-		if err != nil {
-			return err
-		}
-
-		return c.prettyPrintJSON(asset)
-	}
-
-	if err := c.repository.AssetUpdate(ctx, asset); err != nil {
-		errs = multierror.Append(errs, err)
-
-		return errs
-	}
-
-	return nil
+	return errs
 }
 
 // CollectInband querys inventory and bios configuration data for a device through the host OS
@@ -221,9 +312,44 @@ type AssetIterCollector struct {
 	assetIterator AssetIterator
 	queryor       device.Queryor
 	repository    store.Repository
+	selector      *model.AssetSelector
+	schedule      app.ScheduleOptions
+	breaker       *CircuitBreaker
+	adaptive      *AdaptiveConcurrency
+	limiter       *BMCLimiter
+	checkpoint    *Checkpointer
+	shard         *Shard
+	windows       *MaintenanceWindows
+	facility      string
 	syncWG        *sync.WaitGroup
 	logger        *logrus.Logger
 	concurrency   int32
+	// newTimer returns the channel the next periodic collection is scheduled on and a func to stop it,
+	// a time.Timer is used when not set.
+	newTimer func(time.Duration) (<-chan time.Time, func() bool)
+}
+
+// NewAssetIterCollector is a constructor method that initializes the store repository to return an AssetIterCollector.
+func NewAssetIterCollector(
+	ctx context.Context,
+	storeKind model.StoreKind,
+	appKind model.AppKind,
+	cfg *app.Configuration,
+	syncWG *sync.WaitGroup,
+	logger *logrus.Logger,
+) (*AssetIterCollector, error) {
+	repository, err := store.NewRepository(ctx, storeKind, appKind, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := model.ConcurrencyDefault
+	if cfg.Concurrency > 0 {
+		concurrency = cfg.Concurrency
+	}
+
+	// nolint:gosec // concurrency is a small configured value, int32 overflow is not a concern here.
+	return NewAssetIterCollectorWithStore(appKind, repository, int32(concurrency), cfg, syncWG, logger)
 }
 
 // NewAssetIterCollectorWithStore is a constructor method that accepts an initialized store to return an AssetIterCollector.
@@ -231,28 +357,255 @@ func NewAssetIterCollectorWithStore(
 	appKind model.AppKind,
 	repository store.Repository,
 	concurrency int32,
+	cfg *app.Configuration,
 	syncWG *sync.WaitGroup,
 	logger *logrus.Logger,
 ) (*AssetIterCollector, error) {
-	queryor, err := device.NewQueryor(appKind, logger)
+	queryor, err := device.NewQueryor(appKind, cfg, logger)
 	if err != nil {
 		return nil, err
 	}
 
-	assetIterator := NewAssetIterator(repository, logger)
+	var selector *model.AssetSelector
+	if cfg != nil {
+		selector = cfg.AssetSelector
+	}
+
+	assetIterator := NewAssetIteratorWithSelector(repository, selector, logger)
+
+	// the circuit state is kept in memory across the periodic collection runs.
+	var breaker *CircuitBreaker
+	if cfg != nil && cfg.OutofbandOptions != nil && cfg.OutofbandOptions.CircuitBreaker.Enabled {
+		breaker = NewCircuitBreaker(NewMemoryCircuitStore(), cfg.OutofbandOptions.CircuitBreaker, logger)
+	}
+
+	var schedule app.ScheduleOptions
+	if cfg != nil && cfg.OutofbandOptions != nil {
+		schedule = cfg.OutofbandOptions.Schedule
+	}
+
+	// the collections in flight are adjusted from the configured concurrency.
+	var adaptive *AdaptiveConcurrency
+	if cfg != nil && cfg.OutofbandOptions != nil && cfg.OutofbandOptions.AdaptiveConcurrency.Enabled {
+		adaptive = NewAdaptiveConcurrency(cfg.OutofbandOptions.AdaptiveConcurrency, int(concurrency), "collector", logger)
+	}
+
+	var limiter *BMCLimiter
+	if cfg != nil && cfg.OutofbandOptions != nil {
+		if limiter, err = NewBMCLimiter(cfg.OutofbandOptions.BMCLimits); err != nil {
+			return nil, err
+		}
+	}
 
 	return &AssetIterCollector{
 		concurrency:   concurrency,
 		queryor:       queryor,
+		breaker:       breaker,
+		adaptive:      adaptive,
+		limiter:       limiter,
 		assetIterator: *assetIterator,
 		repository:    repository,
+		selector:      selector,
+		schedule:      schedule,
 		syncWG:        syncWG,
 		logger:        logger,
 	}, nil
 }
 
+// Close releases the adaptive concurrency of the collector, once the collections are done.
+func (d *AssetIterCollector) Close() {
+	d.adaptive.Close()
+}
+
+// SetCheckpointer sets the checkpointer to record the progress of the collection from,
+// the checkpoint is used to resume an interrupted collection in the store order.
+func (d *AssetIterCollector) SetCheckpointer(checkpoint *Checkpointer) {
+	d.checkpoint = checkpoint
+	d.assetIterator.checkpoint = checkpoint
+}
+
+// SetShard sets the shard of the assets this replica collects data for, when sharded across replicas.
+func (d *AssetIterCollector) SetShard(shard *Shard) {
+	d.shard = shard
+	d.assetIterator.shard = shard
+}
+
+// SetMaintenanceWindows sets the maintenance windows the periodic collections are deferred to,
+// the collection is paused while the windows for the facility are closed,
+// and assets are skipped while the windows for their vendor, model are closed.
+func (d *AssetIterCollector) SetMaintenanceWindows(windows *MaintenanceWindows, facility string) {
+	d.windows = windows
+	d.facility = facility
+}
+
+// CollectAtIntervals runs Collect over all assets in the store and then schedules the next run
+// at the given interval, with a random duration between zero and the splay value added to it.
+//
+// The method returns when the context is canceled.
+func (d *AssetIterCollector) CollectAtIntervals(ctx context.Context, interval, splay time.Duration) {
+	for {
+		d.collectAll(ctx)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		next := interval + jitter(splay)
+
+		// set next collection schedule metric
+		metrics.OOBCollectScheduleTimestamp.With(
+			prometheus.Labels{"timestamp": "next"},
+		).Set(float64(time.Now().Add(next).Unix()))
+
+		d.logger.WithFields(logrus.Fields{
+			"interval": interval.String(),
+			"splay":    splay.String(),
+			"next":     time.Now().Add(next).Format(time.RFC3339),
+		}).Info("next collection scheduled")
+
+		timerC, stopTimer := d.timer(next)
+
+		select {
+		case <-timerC:
+		case <-ctx.Done():
+			stopTimer()
+
+			d.logger.Info("collection scheduler stopping on done context")
+
+			return
+		}
+	}
+}
+
+// collectAll runs Collect with a new asset iterator and records the collection metrics.
+func (d *AssetIterCollector) collectAll(ctx context.Context) {
+	// the asset iterator channel is closed once the iterator returns,
+	// and so a new iterator is required for each run.
+	d.assetIterator = *NewAssetIteratorWithSelector(d.repository, d.selector, d.logger)
+	d.assetIterator.checkpoint = d.checkpoint
+	d.assetIterator.shard = d.shard
+
+	startTS := time.Now()
+
+	metrics.OOBCollectionActive.Set(1)
+	defer metrics.OOBCollectionActive.Set(0)
+
+	d.logger.Info("collection started")
+
+	d.Collect(ctx)
+
+	metrics.CollectTotalTimeSummary.With(
+		prometheus.Labels{"collect_kind": string(model.AppKindOutOfBand)},
+	).Observe(time.Since(startTS).Seconds())
+
+	d.logger.WithField("elapsed", time.Since(startTS).String()).Info("collection completed")
+}
+
+// timer returns the channel the next periodic collection is scheduled on after the given duration,
+// and a func to stop it.
+func (d *AssetIterCollector) timer(next time.Duration) (<-chan time.Time, func() bool) {
+	if d.newTimer != nil {
+		return d.newTimer(next)
+	}
+
+	timer := time.NewTimer(next)
+
+	return timer.C, timer.Stop
+}
+
+// jitter returns a random duration between zero and the given splay value.
+func jitter(splay time.Duration) time.Duration {
+	if splay <= 0 {
+		return 0
+	}
+
+	// nolint:gosec // jitter does not require a cryptographically secure random value.
+	return time.Duration(rand.Int63n(int64(splay)))
+}
+
 // Collect iterates over assets returned by the AssetIterator and collects their inventory, bios configuration data.
+//
+// The assets are collected in the order listed by the store, or by staleness when configured in the schedule options.
 func (d *AssetIterCollector) Collect(ctx context.Context) {
+	d.collectIter(
+		ctx,
+		func(pauser *Pauser) {
+			stop := d.holdOutsideWindows(ctx, pauser)
+			defer stop()
+
+			if d.schedule.Order == app.ScheduleOrderStaleness {
+				d.assetIterator.IterByStaleness(ctx, int(d.concurrency), d.schedule.MaxAge, pauser)
+				return
+			}
+
+			d.assetIterator.IterInBatches(ctx, int(d.concurrency), pauser)
+		},
+		d.collect,
+	)
+
+	// the checkpoint is retained when the collection was interrupted.
+	if ctx.Err() == nil {
+		d.checkpoint.Finish(ctx)
+	}
+}
+
+// CollectAssets collects inventory, bios configuration data for the given assets with the configured concurrency,
+// and returns the collection result for each asset, in the order of the given assets.
+//
+// The assets are looked up in the store by their ID, a vendor set on the given asset is used
+// as a hint when the store does not include the asset vendor.
+//
+// When outputStdout is set, the collected data is printed to stdout instead of being written to the store.
+func (d *AssetIterCollector) CollectAssets(ctx context.Context, assets []*model.Asset, outputStdout bool) []*AssetResult {
+	// collect each asset once
+	unique := make([]*model.Asset, 0, len(assets))
+	seen := make(map[string]bool, len(assets))
+
+	for _, asset := range assets {
+		if !seen[asset.ID] {
+			seen[asset.ID] = true
+			unique = append(unique, asset)
+		}
+	}
+
+	var mu sync.Mutex
+
+	results := make(map[string]*AssetResult, len(unique))
+
+	d.assetIterator = *NewAssetIterator(d.repository, d.logger)
+
+	d.collectIter(
+		ctx,
+		func(pauser *Pauser) {
+			d.assetIterator.IterAssets(ctx, unique, pauser)
+		},
+		func(ctx context.Context, asset *model.Asset) {
+			result := d.collectWithResult(ctx, asset, outputStdout)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[asset.ID] = result
+		},
+	)
+
+	ordered := make([]*AssetResult, 0, len(unique))
+
+	for _, asset := range unique {
+		result, exists := results[asset.ID]
+		if !exists {
+			result = notCollectedResult(asset.ID)
+		}
+
+		ordered = append(ordered, result)
+	}
+
+	return ordered
+}
+
+// collectIter runs the iterate func to send assets over the asset iterator channel,
+// and runs the collect func for each asset received, the routines running the collect func are limited to the concurrency value.
+func (d *AssetIterCollector) collectIter(ctx context.Context, iterate func(*Pauser), collect func(context.Context, *model.Asset)) {
 	// pauser helps throttle asset retrieval to match the data collection rate.
 	pauser := NewPauser()
 
@@ -264,7 +617,7 @@ func (d *AssetIterCollector) Collect(ctx context.Context) {
 	// asset fetcher routine
 	go func() {
 		defer d.syncWG.Done()
-		d.assetIterator.IterInBatches(ctx, int(d.concurrency), pauser)
+		iterate(pauser)
 	}()
 
 	// bool set when asset iterator closes its channel.
@@ -279,13 +632,39 @@ func (d *AssetIterCollector) Collect(ctx context.Context) {
 	// routines spawned by the loop below indicate on doneCh when complete.
 	doneCh := make(chan struct{})
 
+	// assets waiting for the collections in flight for their BMC subnet or rack to drop below the limit,
+	// these are not counted as dispatched, for assets in other subnets, racks to be collected meanwhile.
+	var waiting []*model.Asset
+
+	dispatch := func(asset *model.Asset, token *BMCLimitToken) {
+		// increment wait group
+		d.syncWG.Add(1)
+
+		// increment spawned count
+		atomic.AddInt32(&dispatched, 1)
+
+		// run collection in routine
+		go func(ctx context.Context, asset *model.Asset) {
+			defer d.syncWG.Done()
+			defer func() {
+				doneCh <- struct{}{}
+			}()
+			defer d.limiter.Release(token)
+
+			// count dispatched worker task
+			metrics.TasksDispatched.With(metrics.StageLabelCollector).Add(1)
+
+			collect(ctx, asset)
+		}(ctx, asset)
+	}
+
 Loop:
 	for {
 		select {
 		case <-tickerCheckComplete.C:
 
 			// tasks dispatched were completed and the asset getter is completed.
-			if dispatched == 0 && done {
+			if dispatched == 0 && len(waiting) == 0 && done {
 				break Loop
 			}
 
@@ -295,6 +674,12 @@ Loop:
 
 			atomic.AddInt32(&dispatched, ^int32(0))
 
+			// dispatch the waiting assets with their BMC subnet, rack now below the limit.
+			waiting = d.dispatchWaiting(ctx, waiting, dispatch)
+
+			// resume the asset iterator once the tasks in flight are within the concurrency limit.
+			d.throttle(nil, pauser, dispatched, len(waiting))
+
 		// spawn routines to collect inventory for assets
 		case asset, ok := <-d.assetIterator.Channel():
 			// assetCh closed - iterator returned.
@@ -311,37 +696,84 @@ Loop:
 			// count assets received on the asset channel
 			metrics.AssetsReceived.With(metrics.StageLabelCollector).Inc()
 
-			// increment wait group
-			d.syncWG.Add(1)
+			// assets given by ID are looked up for their BMC address, to be limited by their BMC subnet, rack.
+			d.resolveBMCAddress(ctx, asset)
+
+			if token, limited := d.limiter.TryAcquire(asset); token == nil {
+				waiting = append(waiting, asset)
 
-			// increment spawned count
-			atomic.AddInt32(&dispatched, 1)
+				metrics.CollectionsDeferred.With(
+					prometheus.Labels{"stage": "collector", "reason": DeferReasonBMCLimit},
+				).Inc()
+
+				d.logger.WithFields(logrus.Fields{
+					"assetID": asset.ID,
+					"limited": limited,
+					"waiting": len(waiting),
+				}).Debug("collection waiting on BMC limit")
+			} else {
+				dispatch(asset, token)
+			}
 
 			// throttle asset iterator based on dispatched vs concurrency limit
-			d.throttle(nil, pauser, dispatched)
+			d.throttle(nil, pauser, dispatched, len(waiting))
+		}
+	}
+}
 
-			// run collection in routine
-			go func(ctx context.Context, asset *model.Asset) {
-				defer d.syncWG.Done()
-				defer func() {
-					doneCh <- struct{}{}
-				}()
+// collectWithResult collects the asset data and returns the collection result.
+func (d *AssetIterCollector) collectWithResult(ctx context.Context, asset *model.Asset, outputStdout bool) *AssetResult {
+	startTS := time.Now()
 
-				// count dispatched worker task
-				metrics.TasksDispatched.With(metrics.StageLabelCollector).Add(1)
+	err := d.deviceCollector().CollectOutofband(ctx, asset, outputStdout)
+	if err != nil {
+		d.logger.WithFields(logrus.Fields{
+			"assetID": asset.ID,
+			"err":     err.Error(),
+		}).Warn("data collector error")
+	}
 
-				d.collect(ctx, asset)
-			}(ctx, asset)
-		}
+	if ctx.Err() == nil {
+		d.adaptive.Done(asset)
 	}
+
+	return newAssetResult(asset, err, time.Since(startTS))
 }
 
-func (d *AssetIterCollector) collect(ctx context.Context, asset *model.Asset) {
-	collector := &DeviceCollector{
+// deviceCollector returns a DeviceCollector sharing the queryor, repository and circuit breaker of this collector.
+func (d *AssetIterCollector) deviceCollector() *DeviceCollector {
+	return &DeviceCollector{
+		kind:       model.AppKindOutOfBand,
 		queryor:    d.queryor,
 		repository: d.repository,
+		breaker:    d.breaker,
+		log:        d.logger,
+	}
+}
+
+func (d *AssetIterCollector) collect(ctx context.Context, asset *model.Asset) {
+	// the asset is collected in a following collection, when its maintenance windows are open.
+	if open, next, entry := d.windows.AssetOpen(asset, time.Now()); !open {
+		metrics.CollectionsDeferred.With(
+			prometheus.Labels{"stage": "collector", "reason": DeferReasonAssetWindow},
+		).Inc()
+
+		d.logger.WithFields(logrus.Fields{
+			"assetID": asset.ID,
+			"window":  entry,
+			"next":    FormatWindowTime(next),
+		}).Info("collection deferred, asset outside its maintenance windows")
+
+		// the deferred asset is done for this sweep, for the checkpoint to advance past its page.
+		if ctx.Err() == nil {
+			d.checkpoint.Done(ctx, asset.ID)
+		}
+
+		return
 	}
 
+	collector := d.deviceCollector()
+
 	d.logger.WithFields(
 		logrus.Fields{
 			"assetID": asset.ID,
@@ -356,6 +788,12 @@ func (d *AssetIterCollector) collect(ctx context.Context, asset *model.Asset) {
 		}).Warn("data collector error")
 	}
 
+	// assets collected are recorded in the checkpoint, unless the collection was interrupted.
+	if ctx.Err() == nil {
+		d.checkpoint.Done(ctx, asset.ID)
+		d.adaptive.Done(asset)
+	}
+
 	d.logger.WithFields(
 		logrus.Fields{
 			"assetID": asset.ID,
@@ -364,14 +802,136 @@ func (d *AssetIterCollector) collect(ctx context.Context, asset *model.Asset) {
 	).Debug("collection complete.")
 }
 
+// holdOutsideWindows holds the asset iterator while the maintenance windows for the facility are closed,
+// the windows are checked at the windowCheckInterval until the returned stop func is invoked.
+func (d *AssetIterCollector) holdOutsideWindows(ctx context.Context, pauser *Pauser) (stop func()) {
+	if d.windows == nil {
+		return func() {}
+	}
+
+	check := func() {
+		open, next, entry := d.windows.FacilityOpen(d.facility, time.Now())
+
+		switch {
+		case !open && !pauser.Held(DeferReasonFacilityWindow):
+			pauser.Hold(DeferReasonFacilityWindow)
+			metrics.MaintenanceWindowClosed.Set(1)
+			metrics.CollectionsDeferred.With(
+				prometheus.Labels{"stage": "collector", "reason": DeferReasonFacilityWindow},
+			).Inc()
+
+			d.logger.WithFields(logrus.Fields{
+				"facility": d.facility,
+				"window":   entry,
+				"next":     FormatWindowTime(next),
+			}).Info("collection paused, facility outside its maintenance windows")
+
+		case open && pauser.Held(DeferReasonFacilityWindow):
+			pauser.Release(DeferReasonFacilityWindow)
+			metrics.MaintenanceWindowClosed.Set(0)
+
+			d.logger.WithField("facility", d.facility).Info("collection resumed, facility maintenance window open")
+		}
+	}
+
+	// the iterator is held before the first asset is sent.
+	check()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(windowCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				check()
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+
+		pauser.Release(DeferReasonFacilityWindow)
+		metrics.MaintenanceWindowClosed.Set(0)
+	}
+}
+
+// concurrencyLimit returns the collections allowed in flight, as adjusted by the adaptive concurrency when enabled.
+func (d *AssetIterCollector) concurrencyLimit() int32 {
+	if d.adaptive == nil {
+		return d.concurrency
+	}
+
+	// nolint:gosec // the adaptive concurrency is bounded by small configured values.
+	return int32(d.adaptive.Limit())
+}
+
+// dispatchWaiting dispatches the waiting assets with their BMC subnet, rack below the limit, in the order received,
+// and returns the assets still waiting. The waiting assets are dropped when the context is canceled.
+func (d *AssetIterCollector) dispatchWaiting(
+	ctx context.Context,
+	waiting []*model.Asset,
+	dispatch func(*model.Asset, *BMCLimitToken),
+) []*model.Asset {
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	remaining := waiting[:0]
+
+	for _, asset := range waiting {
+		if token, _ := d.limiter.TryAcquire(asset); token != nil {
+			dispatch(asset, token)
+			continue
+		}
+
+		remaining = append(remaining, asset)
+	}
+
+	return remaining
+}
+
+// resolveBMCAddress sets the BMC address, attributes of an asset without a BMC address from the store,
+// when the collections are limited by BMC subnet or rack. A lookup error is left to be handled by the collection.
+func (d *AssetIterCollector) resolveBMCAddress(ctx context.Context, asset *model.Asset) {
+	if d.limiter == nil || asset.BMCAddress != nil {
+		return
+	}
+
+	existing, err := d.repository.AssetByID(ctx, asset.ID, false)
+	if err != nil || existing == nil {
+		return
+	}
+
+	asset.BMCAddress = existing.BMCAddress
+
+	if asset.Attributes == nil {
+		asset.Attributes = existing.Attributes
+	}
+}
+
 // throttle allows this collector to to 'push back' on the asset iterator
-// to throttle assets being sent based on the routines dispatched and the configured concurrency value.
-func (d *AssetIterCollector) throttle(_ trace.Span, pauser *Pauser, dispatched int32) {
+// to throttle assets being sent based on the routines dispatched and the concurrency limit,
+// or the assets waiting on their BMC subnet, rack limit.
+func (d *AssetIterCollector) throttle(_ trace.Span, pauser *Pauser, dispatched int32, waiting int) {
 	// measure tasks waiting queue size
 	metrics.TaskQueueSize.With(metrics.StageLabelCollector).Set(float64(dispatched))
 
-	if dispatched > d.concurrency {
-		if pauser.Value() {
+	concurrency := d.concurrencyLimit()
+
+	if dispatched > concurrency || waiting >= bmcLimiterMaxWaiting {
+		if pauser.throttled() {
 			// fetcher was previously paused
 			return
 		}
@@ -381,19 +941,20 @@ func (d *AssetIterCollector) throttle(_ trace.Span, pauser *Pauser, dispatched i
 		d.logger.WithFields(logrus.Fields{
 			"component":   "oob collector",
 			"active":      dispatched,
-			"concurrency": d.concurrency,
+			"waiting":     waiting,
+			"concurrency": concurrency,
 		}).Trace("paused asset iterator.")
 
 		return
 	}
 
-	if pauser.Value() {
+	if pauser.throttled() {
 		pauser.UnPause()
 
 		d.logger.WithFields(logrus.Fields{
 			"component":   "oob collector",
 			"active":      dispatched,
-			"concurrency": d.concurrency,
+			"concurrency": concurrency,
 		}).Trace("resumed asset iterator.")
 	}
 }
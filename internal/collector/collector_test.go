@@ -2,8 +2,11 @@ package collector
 
 import (
 	"context"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/metal-toolbox/alloy/internal/device"
 	"github.com/metal-toolbox/alloy/internal/model"
@@ -71,3 +74,113 @@ func Test_Collect(t *testing.T) {
 
 	assert.Equal(t, 3, mockstore.UpdatedAssets)
 }
+
+func Test_CollectAtIntervals(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	logger := logrus.New()
+	mockstore, _ := mock.New(3)
+	mockDeviceQueryor := device.NewMockDeviceQueryor(model.AppKindOutOfBand)
+
+	syncWG := &sync.WaitGroup{}
+
+	assetIterCollector := &AssetIterCollector{
+		concurrency: 1,
+		queryor:     mockDeviceQueryor,
+		repository:  mockstore,
+		syncWG:      syncWG,
+		logger:      logger,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the first run is scheduled right away, the context is canceled when the third run is scheduled.
+	var scheduled []time.Duration
+
+	assetIterCollector.newTimer = func(next time.Duration) (<-chan time.Time, func() bool) {
+		scheduled = append(scheduled, next)
+
+		fired := make(chan time.Time, 1)
+		if len(scheduled) == 1 {
+			fired <- time.Now()
+		} else {
+			cancel()
+		}
+
+		return fired, func() bool { return true }
+	}
+
+	assetIterCollector.CollectAtIntervals(ctx, 1*time.Second, 0)
+	syncWG.Wait()
+
+	assert.Equal(t, 6, mockstore.UpdatedAssets)
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, scheduled)
+}
+
+func Test_CollectAssets(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	logger := logrus.New()
+	mockstore, _ := mock.New(0)
+	mockDeviceQueryor := device.NewMockDeviceQueryor(model.AppKindOutOfBand)
+
+	assetIterCollector := &AssetIterCollector{
+		concurrency: 1,
+		queryor:     mockDeviceQueryor,
+		repository:  mockstore,
+		syncWG:      &sync.WaitGroup{},
+		logger:      logger,
+	}
+
+	assets := []*model.Asset{{ID: "c"}, {ID: "a"}, {ID: "b"}, {ID: "a"}}
+
+	results := assetIterCollector.CollectAssets(context.TODO(), assets, false)
+
+	// assets are collected once, the results are in the order of the given IDs.
+	assert.Len(t, results, 3)
+	assert.Equal(t, 3, mockstore.UpdatedAssets)
+
+	for idx, assetID := range []string{"c", "a", "b"} {
+		assert.Equal(t, assetID, results[idx].AssetID)
+		assert.True(t, results[idx].Success())
+	}
+}
+
+func Test_collectIter_Throttled(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	assets := make([]*model.Asset, 0, 12)
+	for idx := 0; idx < 12; idx++ {
+		assets = append(assets, &model.Asset{ID: strconv.Itoa(idx)})
+	}
+
+	logger := logrus.New()
+	assetIterator := NewAssetIterator(&assetsStore{assets: assets}, logger)
+
+	assetIterCollector := &AssetIterCollector{
+		concurrency:   1,
+		assetIterator: *assetIterator,
+		syncWG:        &sync.WaitGroup{},
+		logger:        logger,
+	}
+
+	var collected int32
+
+	// the collections outlast the iteration over the pages, the iterator is paused and resumed as they complete.
+	assetIterCollector.collectIter(
+		context.TODO(),
+		func(pauser *Pauser) {
+			assetIterCollector.assetIterator.IterInBatches(context.TODO(), 2, pauser)
+		},
+		func(_ context.Context, _ *model.Asset) {
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt32(&collected, 1)
+		},
+	)
+
+	assert.Equal(t, int32(12), atomic.LoadInt32(&collected))
+}
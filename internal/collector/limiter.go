@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"net/netip"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+var (
+	ErrBMCLimits = errors.New("BMC limits configuration error")
+)
+
+const (
+	// DeferReasonBMCLimit is the reason work is deferred when the collections in flight for the BMC subnet or rack are at the limit.
+	DeferReasonBMCLimit = "bmc_limit"
+
+	// bmcLimiterMaxWaiting is the count of assets waiting on a BMC subnet or rack limit above which the asset iterator is paused.
+	bmcLimiterMaxWaiting = 100
+)
+
+// subnetLimit is the collections allowed in flight for the BMCs in the subnet.
+type subnetLimit struct {
+	prefix netip.Prefix
+	limit  int
+}
+
+// limitKey identifies the subnet or rack the BMC collections in flight are counted by.
+type limitKey struct {
+	key   string
+	limit int
+}
+
+// BMCLimiter limits the collections in flight for the BMCs sharing a subnet or rack,
+// so the management network switch of a rack is not saturated while assets in other subnets, racks are collected.
+type BMCLimiter struct {
+	subnets   []subnetLimit
+	rack      *model.AttributeSelector
+	rackLimit int
+	inflight  map[string]int
+	mu        sync.Mutex
+}
+
+// NewBMCLimiter returns the BMCLimiter for the configured limits, nil is returned when none are configured.
+func NewBMCLimiter(opts app.BMCLimitsOptions) (*BMCLimiter, error) {
+	if len(opts.Subnets) == 0 && opts.RackAttribute == "" {
+		return nil, nil // nolint:nilnil // collections are not limited without limits configured
+	}
+
+	l := &BMCLimiter{inflight: make(map[string]int)}
+
+	for _, subnet := range opts.Subnets {
+		prefix, err := netip.ParsePrefix(subnet.CIDR)
+		if err != nil {
+			return nil, errors.Wrap(ErrBMCLimits, err.Error())
+		}
+
+		if subnet.Limit < 1 {
+			return nil, errors.Wrap(ErrBMCLimits, "subnet limit expected to be at least 1: "+subnet.CIDR)
+		}
+
+		l.subnets = append(l.subnets, subnetLimit{prefix: prefix.Masked(), limit: subnet.Limit})
+	}
+
+	if opts.RackAttribute != "" {
+		rack, err := model.ParseAttributeSelector(opts.RackAttribute)
+		if err != nil || rack.Key == "" || rack.Value != "" {
+			return nil, errors.Wrap(ErrBMCLimits, "rack attribute expected in the namespace~key form: "+opts.RackAttribute)
+		}
+
+		if opts.RackLimit < 1 {
+			return nil, errors.Wrap(ErrBMCLimits, "rack limit expected to be at least 1: "+strconv.Itoa(opts.RackLimit))
+		}
+
+		l.rack = &rack
+		l.rackLimit = opts.RackLimit
+	}
+
+	return l, nil
+}
+
+// BMCLimitToken is the subnet, rack counts acquired for a collection, to be released once the collection completed.
+type BMCLimitToken struct {
+	keys []string
+}
+
+// TryAcquire counts the collection for the asset in flight when its subnet and rack are below their limits,
+// and returns the token to release the counts with. A nil token is returned with the subnet or rack at the limit otherwise.
+//
+// Assets with a BMC hostname are not limited by subnet, assets without the rack attribute are not limited by rack.
+func (l *BMCLimiter) TryAcquire(asset *model.Asset) (token *BMCLimitToken, limited string) {
+	if l == nil {
+		return &BMCLimitToken{}, ""
+	}
+
+	keys := l.keys(asset)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if l.inflight[key.key] >= key.limit {
+			return nil, key.key
+		}
+	}
+
+	token = &BMCLimitToken{keys: make([]string, 0, len(keys))}
+
+	for _, key := range keys {
+		l.inflight[key.key]++
+		token.keys = append(token.keys, key.key)
+	}
+
+	return token, ""
+}
+
+// Release removes the collection counted by the token from the collections in flight, once the collection completed.
+func (l *BMCLimiter) Release(token *BMCLimitToken) {
+	if l == nil || token == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range token.keys {
+		if l.inflight[key]--; l.inflight[key] <= 0 {
+			delete(l.inflight, key)
+		}
+	}
+
+	// the counts are released once.
+	token.keys = nil
+}
+
+// keys returns the subnet, rack the asset collections in flight are counted by.
+func (l *BMCLimiter) keys(asset *model.Asset) []limitKey {
+	keys := make([]limitKey, 0, 2) // nolint:gomnd // subnet, rack
+
+	if asset == nil {
+		return keys
+	}
+
+	if asset.BMCAddress != nil {
+		if addr, err := netip.ParseAddr(asset.BMCAddress.Host); err == nil {
+			for _, subnet := range l.subnets {
+				if subnet.prefix.Contains(addr.Unmap()) {
+					keys = append(keys, limitKey{key: "subnet " + subnet.prefix.String(), limit: subnet.limit})
+					break
+				}
+			}
+		}
+	}
+
+	if l.rack != nil {
+		if rack, exists := l.rack.Lookup(asset.Attributes); exists && rack != "" {
+			keys = append(keys, limitKey{key: "rack " + rack, limit: l.rackLimit})
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,264 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_BMCLimiter_TryAcquire(t *testing.T) {
+	testcases := []struct {
+		name     string
+		inflight []*model.Asset
+		asset    *model.Asset
+		limited  string
+	}{
+		{
+			"below the subnet limit",
+			[]*model.Asset{{ID: "1", BMCAddress: &model.BMCAddress{Host: "10.1.2.10"}}},
+			&model.Asset{ID: "2", BMCAddress: &model.BMCAddress{Host: "10.1.2.11"}},
+			"",
+		},
+		{
+			"the first subnet including the address applies",
+			[]*model.Asset{
+				{ID: "1", BMCAddress: &model.BMCAddress{Host: "10.1.2.10"}},
+				{ID: "2", BMCAddress: &model.BMCAddress{Host: "10.1.2.11"}},
+			},
+			&model.Asset{ID: "3", BMCAddress: &model.BMCAddress{Host: "10.1.2.12"}},
+			"subnet 10.1.2.0/24",
+		},
+		{
+			"other subnets not limited by the subnet at the limit",
+			[]*model.Asset{
+				{ID: "1", BMCAddress: &model.BMCAddress{Host: "10.1.2.10"}},
+				{ID: "2", BMCAddress: &model.BMCAddress{Host: "10.1.2.11"}},
+			},
+			&model.Asset{ID: "3", BMCAddress: &model.BMCAddress{Host: "10.1.3.10"}},
+			"",
+		},
+		{
+			"ipv6 subnet",
+			[]*model.Asset{{ID: "1", BMCAddress: &model.BMCAddress{Host: "2001:db8::10"}}},
+			&model.Asset{ID: "2", BMCAddress: &model.BMCAddress{Host: "2001:db8::11"}},
+			"subnet 2001:db8::/64",
+		},
+		{
+			"rack limit across subnets",
+			[]*model.Asset{{
+				ID:         "1",
+				BMCAddress: &model.BMCAddress{Host: "192.168.1.10"},
+				Attributes: map[string]json.RawMessage{"sh.hollow.location": json.RawMessage(`{"rack": "r1"}`)},
+			}},
+			&model.Asset{
+				ID:         "2",
+				BMCAddress: &model.BMCAddress{Host: "10.2.0.10"},
+				Attributes: map[string]json.RawMessage{"sh.hollow.location": json.RawMessage(`{"rack": "r1"}`)},
+			},
+			"rack r1",
+		},
+		{
+			"hostnames not limited by subnet",
+			[]*model.Asset{
+				{ID: "1", BMCAddress: &model.BMCAddress{Host: "bmc1.example.com"}},
+				{ID: "2", BMCAddress: &model.BMCAddress{Host: "bmc2.example.com"}},
+			},
+			&model.Asset{ID: "3", BMCAddress: &model.BMCAddress{Host: "bmc3.example.com"}},
+			"",
+		},
+		{
+			"asset without a BMC address not limited",
+			[]*model.Asset{{ID: "1", BMCAddress: &model.BMCAddress{Host: "2001:db8::10"}}},
+			&model.Asset{ID: "2"},
+			"",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter, err := NewBMCLimiter(app.BMCLimitsOptions{
+				Subnets: []app.SubnetLimitOptions{
+					{CIDR: "10.1.2.0/24", Limit: 2},
+					{CIDR: "10.1.0.0/16", Limit: 3},
+					{CIDR: "2001:db8::/64", Limit: 1},
+				},
+				RackAttribute: "sh.hollow.location~rack",
+				RackLimit:     1,
+			})
+			require.Nil(t, err)
+
+			for _, asset := range tc.inflight {
+				token, _ := limiter.TryAcquire(asset)
+				require.NotNil(t, token)
+			}
+
+			token, limited := limiter.TryAcquire(tc.asset)
+			assert.Equal(t, tc.limited, limited)
+			assert.Equal(t, tc.limited == "", token != nil)
+		})
+	}
+}
+
+func Test_BMCLimiter_Release(t *testing.T) {
+	limiter, err := NewBMCLimiter(app.BMCLimitsOptions{
+		Subnets: []app.SubnetLimitOptions{{CIDR: "10.1.2.0/24", Limit: 1}},
+	})
+	require.Nil(t, err)
+
+	// the asset given by ID is not limited, its BMC address is set once collected.
+	byID := &model.Asset{ID: "1"}
+
+	unlimited, _ := limiter.TryAcquire(byID)
+	require.NotNil(t, unlimited)
+
+	byID.BMCAddress = &model.BMCAddress{Host: "10.1.2.10"}
+
+	acquired, _ := limiter.TryAcquire(&model.Asset{ID: "2", BMCAddress: &model.BMCAddress{Host: "10.1.2.11"}})
+	require.NotNil(t, acquired)
+
+	// the counts acquired by the token are released, and once.
+	limiter.Release(unlimited)
+
+	token, limited := limiter.TryAcquire(&model.Asset{ID: "3", BMCAddress: &model.BMCAddress{Host: "10.1.2.12"}})
+	assert.Nil(t, token)
+	assert.Equal(t, "subnet 10.1.2.0/24", limited)
+
+	limiter.Release(acquired)
+	limiter.Release(acquired)
+
+	token, _ = limiter.TryAcquire(&model.Asset{ID: "3", BMCAddress: &model.BMCAddress{Host: "10.1.2.12"}})
+	assert.NotNil(t, token)
+
+	token, _ = limiter.TryAcquire(&model.Asset{ID: "4", BMCAddress: &model.BMCAddress{Host: "10.1.2.13"}})
+	assert.Nil(t, token)
+
+	// no limits configured
+	none, err := NewBMCLimiter(app.BMCLimitsOptions{})
+	require.Nil(t, err)
+	assert.Nil(t, none)
+
+	token, _ = none.TryAcquire(&model.Asset{ID: "1", BMCAddress: &model.BMCAddress{Host: "10.1.2.10"}})
+	assert.NotNil(t, token)
+
+	none.Release(token)
+}
+
+func Test_NewBMCLimiter_Invalid(t *testing.T) {
+	testcases := []struct {
+		name    string
+		options app.BMCLimitsOptions
+	}{
+		{"cidr", app.BMCLimitsOptions{Subnets: []app.SubnetLimitOptions{{CIDR: "10.1.2.0", Limit: 1}}}},
+		{"subnet limit", app.BMCLimitsOptions{Subnets: []app.SubnetLimitOptions{{CIDR: "10.1.2.0/24"}}}},
+		{"rack attribute", app.BMCLimitsOptions{RackAttribute: "sh.hollow.location", RackLimit: 1}},
+		{"rack limit", app.BMCLimitsOptions{RackAttribute: "sh.hollow.location~rack"}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewBMCLimiter(tc.options)
+			assert.ErrorIs(t, err, ErrBMCLimits)
+		})
+	}
+}
+
+func Test_collectIter_BMCLimits(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	// the assets in the limited subnet are listed first.
+	listed := []*model.Asset{}
+	for idx := 0; idx < 4; idx++ {
+		listed = append(listed, &model.Asset{ID: "limited-" + strconv.Itoa(idx), BMCAddress: &model.BMCAddress{Host: "10.1.2." + strconv.Itoa(idx+10)}})
+	}
+
+	for idx := 0; idx < 4; idx++ {
+		listed = append(listed, &model.Asset{ID: "other-" + strconv.Itoa(idx), BMCAddress: &model.BMCAddress{Host: "10.1.3." + strconv.Itoa(idx+10)}})
+	}
+
+	byID := []*model.Asset{}
+	for _, asset := range listed {
+		byID = append(byID, &model.Asset{ID: asset.ID})
+	}
+
+	testcases := []struct {
+		name   string
+		stored []*model.Asset
+		assets []*model.Asset
+	}{
+		{"listed assets", nil, listed},
+		// assets given by ID are looked up in the store for their BMC address.
+		{"assets by ID", listed, byID},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter, err := NewBMCLimiter(app.BMCLimitsOptions{
+				Subnets: []app.SubnetLimitOptions{{CIDR: "10.1.2.0/24", Limit: 1}},
+			})
+			require.Nil(t, err)
+
+			logger := logrus.New()
+			repository := &assetsStore{assets: tc.stored}
+
+			assetIterCollector := &AssetIterCollector{
+				concurrency:   4,
+				limiter:       limiter,
+				repository:    repository,
+				assetIterator: *NewAssetIterator(repository, logger),
+				syncWG:        &sync.WaitGroup{},
+				logger:        logger,
+			}
+
+			var mu sync.Mutex
+
+			var order []string
+
+			var inflight, maxInflight int
+
+			assetIterCollector.collectIter(
+				context.TODO(),
+				func(pauser *Pauser) {
+					assetIterCollector.assetIterator.IterAssets(context.TODO(), tc.assets, pauser)
+				},
+				func(_ context.Context, asset *model.Asset) {
+					limited := strings.HasPrefix(asset.BMCAddress.Host, "10.1.2.")
+
+					mu.Lock()
+					order = append(order, asset.ID)
+					if limited {
+						inflight++
+						maxInflight = max(maxInflight, inflight)
+					}
+					mu.Unlock()
+
+					time.Sleep(20 * time.Millisecond)
+
+					mu.Lock()
+					if limited {
+						inflight--
+					}
+					mu.Unlock()
+				},
+			)
+
+			assert.Len(t, order, 8)
+			assert.Equal(t, 1, maxInflight)
+
+			// the assets in the other subnet were collected without waiting on the limited assets.
+			assert.ElementsMatch(t, []string{"limited-0", "other-0", "other-1", "other-2", "other-3"}, order[:5])
+		})
+	}
+}
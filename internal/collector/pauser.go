@@ -2,8 +2,10 @@ package collector
 
 import "sync"
 
+// Pauser pauses the asset iterator, it is paused while the throttle pause is set or any hold is placed.
 type Pauser struct {
 	value bool
+	holds map[string]bool
 	mu    sync.RWMutex
 }
 
@@ -21,13 +23,49 @@ func (p *Pauser) UnPause() {
 	p.value = false
 }
 
-func (p *Pauser) Value() bool {
+// Hold pauses the iterator for the reason until released, independent of the Pause, UnPause calls.
+func (p *Pauser) Hold(reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.holds == nil {
+		p.holds = make(map[string]bool)
+	}
+
+	p.holds[reason] = true
+}
+
+// Release removes the hold placed for the reason.
+func (p *Pauser) Release(reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.holds, reason)
+}
+
+// Held returns true when a hold is placed for the reason.
+func (p *Pauser) Held(reason string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.holds[reason]
+}
+
+// throttled returns true when the pause is set, disregarding the holds placed.
+func (p *Pauser) throttled() bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	return p.value
 }
 
+func (p *Pauser) Value() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.value || len(p.holds) > 0
+}
+
 func NewPauser() *Pauser {
 	return new(Pauser)
 }
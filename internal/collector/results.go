@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+const (
+	resultOK      = "ok"
+	resultError   = "error"
+	resultSkipped = "skipped"
+	resultNone    = "-"
+
+	// maxResultErrLength is the length the error is truncated to in the results table.
+	maxResultErrLength = 80
+)
+
+// AssetResult is the result of the out of band collection for an asset.
+type AssetResult struct {
+	AssetID  string
+	Duration time.Duration
+
+	// Login, Inventory, BiosConfig are the results of each stage of the collection - ok, error, skipped or - when not attempted.
+	Login      string
+	Inventory  string
+	BiosConfig string
+
+	// Err is the first error in the collection, if any.
+	Err string
+}
+
+// newAssetResult returns the collection result from the asset errors, and the error returned by the collection.
+func newAssetResult(asset *model.Asset, err error, duration time.Duration) *AssetResult {
+	result := &AssetResult{
+		AssetID:    asset.ID,
+		Duration:   duration,
+		Login:      resultOK,
+		Inventory:  resultOK,
+		BiosConfig: resultOK,
+	}
+
+	switch {
+	case asset.HasError(outofband.CircuitOpenError):
+		result.Login, result.Inventory, result.BiosConfig = resultSkipped, resultNone, resultNone
+		result.Err = asset.Errors[string(outofband.CircuitOpenError)]
+
+		return result
+
+	case asset.HasError(outofband.LoginError):
+		result.Login, result.Inventory, result.BiosConfig = resultError, resultNone, resultNone
+		result.Err = asset.Errors[string(outofband.LoginError)]
+
+		return result
+	}
+
+	if asset.HasError(outofband.InventoryError) {
+		result.Inventory = resultError
+		result.Err = asset.Errors[string(outofband.InventoryError)]
+	}
+
+	if asset.HasError(outofband.GetBiosConfigError) {
+		result.BiosConfig = resultError
+
+		if result.Err == "" {
+			result.Err = asset.Errors[string(outofband.GetBiosConfigError)]
+		}
+	}
+
+	// errors returned without the collection stage errors are from the store, before or after the collection.
+	if err != nil && result.Err == "" {
+		result.Err = err.Error()
+
+		if asset.Inventory == nil && asset.BiosConfig == nil {
+			result.Login, result.Inventory, result.BiosConfig = resultNone, resultNone, resultNone
+		}
+	}
+
+	return result
+}
+
+// notCollectedResult is the result for an asset the collection was not run for, when the context is canceled.
+func notCollectedResult(assetID string) *AssetResult {
+	return &AssetResult{
+		AssetID:    assetID,
+		Login:      resultNone,
+		Inventory:  resultNone,
+		BiosConfig: resultNone,
+		Err:        "not collected",
+	}
+}
+
+// Success returns true when the collection completed without errors.
+func (r *AssetResult) Success() bool {
+	return r.Err == ""
+}
+
+// WriteResults writes the results as a table, followed by the count of assets collected and failed.
+func WriteResults(w io.Writer, results []*AssetResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) // nolint:gomnd // padding is clear as is
+
+	fmt.Fprintln(tw, "ASSET ID\tRESULT\tLOGIN\tINVENTORY\tBIOS CONFIG\tDURATION\tERROR")
+
+	var failed int
+
+	for _, r := range results {
+		status := "success"
+		if !r.Success() {
+			status = "failed"
+			failed++
+		}
+
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.AssetID,
+			status,
+			r.Login,
+			r.Inventory,
+			r.BiosConfig,
+			r.Duration.Round(time.Millisecond).String(),
+			truncate(r.Err, maxResultErrLength),
+		)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d assets, %d succeeded, %d failed\n", len(results), len(results)-failed, failed)
+
+	return err
+}
+
+// truncate returns the first line of the string, truncated to the given length.
+func truncate(s string, length int) string {
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		s = s[:idx]
+	}
+
+	if len(s) <= length {
+		return s
+	}
+
+	return s[:length-3] + "..."
+}
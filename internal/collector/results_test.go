@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_newAssetResult(t *testing.T) {
+	cases := []struct {
+		name     string
+		errors   map[string]string
+		err      error
+		expected *AssetResult
+	}{
+		{
+			"success",
+			nil,
+			nil,
+			&AssetResult{AssetID: "foo", Login: resultOK, Inventory: resultOK, BiosConfig: resultOK},
+		},
+		{
+			"login error",
+			map[string]string{string(outofband.LoginError): "401: Unauthorized"},
+			nil,
+			&AssetResult{AssetID: "foo", Login: resultError, Inventory: resultNone, BiosConfig: resultNone, Err: "401: Unauthorized"},
+		},
+		{
+			"circuit open",
+			map[string]string{string(outofband.CircuitOpenError): "circuit open"},
+			nil,
+			&AssetResult{AssetID: "foo", Login: resultSkipped, Inventory: resultNone, BiosConfig: resultNone, Err: "circuit open"},
+		},
+		{
+			"inventory, bios config error",
+			map[string]string{
+				string(outofband.InventoryError):     "inventory timeout",
+				string(outofband.GetBiosConfigError): "bios config timeout",
+			},
+			nil,
+			&AssetResult{AssetID: "foo", Login: resultOK, Inventory: resultError, BiosConfig: resultError, Err: "inventory timeout"},
+		},
+		{
+			"bios config error",
+			map[string]string{string(outofband.GetBiosConfigError): "bios config timeout"},
+			nil,
+			&AssetResult{AssetID: "foo", Login: resultOK, Inventory: resultOK, BiosConfig: resultError, Err: "bios config timeout"},
+		},
+		{
+			"store error",
+			nil,
+			errors.New("asset not found"),
+			&AssetResult{AssetID: "foo", Login: resultNone, Inventory: resultNone, BiosConfig: resultNone, Err: "asset not found"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset := &model.Asset{ID: "foo", Errors: tc.errors}
+
+			assert.Equal(t, tc.expected, newAssetResult(asset, tc.err, 0))
+		})
+	}
+}
+
+func Test_WriteResults(t *testing.T) {
+	results := []*AssetResult{
+		{AssetID: "foo", Login: resultOK, Inventory: resultOK, BiosConfig: resultOK, Duration: 1500 * time.Millisecond},
+		{AssetID: "bar", Login: resultError, Inventory: resultNone, BiosConfig: resultNone, Err: strings.Repeat("x", 100) + "\nfoo"},
+		notCollectedResult("baz"),
+	}
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, WriteResults(buf, results))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 6)
+	assert.Contains(t, lines[1], "foo")
+	assert.Contains(t, lines[1], "success")
+	assert.Contains(t, lines[1], "1.5s")
+	assert.Contains(t, lines[2], "failed")
+	assert.Contains(t, lines[2], strings.Repeat("x", maxResultErrLength-3)+"...")
+	assert.NotContains(t, lines[2], "\nfoo")
+	assert.Contains(t, lines[3], "not collected")
+	assert.Equal(t, "3 assets, 1 succeeded, 2 failed", lines[5])
+}
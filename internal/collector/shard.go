@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"hash/fnv"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/metal-toolbox/alloy/internal/metrics"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+const (
+	// shardVirtualNodes is the number of points each replica has on the hash ring,
+	// more points spread the assets more evenly across the replicas.
+	shardVirtualNodes = 128
+)
+
+// ringPoint is a point on the hash ring, the assets hashed up to the point are owned by its replica.
+type ringPoint struct {
+	hash    uint64
+	replica string
+}
+
+// Shard selects the assets a replica collects data for, when multiple replicas collect data from the same store.
+//
+// The asset IDs are sharded across the replicas by consistent hashing,
+// so when a replica joins or leaves, only the assets of its share are moved to or from the other replicas.
+type Shard struct {
+	id       string
+	replicas []string
+	ring     []ringPoint
+	mu       sync.RWMutex
+}
+
+// NewShard returns the Shard for the replica with the given ID, the replica owns all assets until the replicas are set.
+func NewShard(id string) *Shard {
+	s := &Shard{id: id}
+	s.SetReplicas(nil)
+
+	return s
+}
+
+// ID returns the replica ID.
+func (s *Shard) ID() string {
+	return s.id
+}
+
+// SetReplicas sets the live replicas the assets are sharded across, the replica itself is always included.
+//
+// Returns true when the replicas changed, and the shards were rebalanced.
+func (s *Shard) SetReplicas(replicas []string) bool {
+	unique := map[string]bool{s.id: true}
+	for _, replica := range replicas {
+		unique[replica] = true
+	}
+
+	sorted := make([]string, 0, len(unique))
+	for replica := range unique {
+		sorted = append(sorted, replica)
+	}
+
+	sort.Strings(sorted)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ring != nil && slices.Equal(s.replicas, sorted) {
+		return false
+	}
+
+	ring := make([]ringPoint, 0, len(sorted)*shardVirtualNodes)
+
+	for _, replica := range sorted {
+		for idx := 0; idx < shardVirtualNodes; idx++ {
+			ring = append(ring, ringPoint{hash: hashKey(replica + "#" + strconv.Itoa(idx)), replica: replica})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	s.replicas = sorted
+	s.ring = ring
+
+	metrics.ShardReplicas.Set(float64(len(sorted)))
+	metrics.ShardShare.With(prometheus.Labels{"replica": s.id}).Set(s.share())
+
+	return true
+}
+
+// Pinned returns a copy of the shard with the current replicas, which is not rebalanced when the replicas change,
+// for a sweep over the assets to select the assets by the same shard throughout - nil is returned when the shard is nil.
+func (s *Shard) Pinned() *Shard {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// the ring is replaced, and not modified, on a rebalance and so is shared with the copy.
+	return &Shard{id: s.id, replicas: s.replicas, ring: s.ring}
+}
+
+// Replicas returns the replicas the assets are sharded across.
+func (s *Shard) Replicas() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]string(nil), s.replicas...)
+}
+
+// Share returns the fraction (0 - 1) of the asset ID hash space owned by the replica.
+func (s *Shard) Share() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.share()
+}
+
+// share returns the fraction of the hash space owned by the replica, the caller is expected to hold the lock.
+func (s *Shard) share() float64 {
+	// a single replica owns the full ring
+	if len(s.replicas) == 1 {
+		return 1
+	}
+
+	var owned float64
+
+	for idx, point := range s.ring {
+		if point.replica != s.id {
+			continue
+		}
+
+		// the arc up to the point, from the previous point on the ring.
+		// the arc of the first point wraps around from the last point, in uint64 arithmetic.
+		previous := s.ring[(idx+len(s.ring)-1)%len(s.ring)].hash
+		owned += float64(point.hash - previous)
+	}
+
+	return owned / math.MaxUint64
+}
+
+// Fingerprint identifies the assets owned by the replica, shards with the same fingerprint own the same assets,
+// an empty string is returned when the shard is nil or the replica is the only one and owns all assets.
+func (s *Shard) Fingerprint() string {
+	if s == nil {
+		return ""
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.replicas) == 1 {
+		return ""
+	}
+
+	return s.id + "@" + strconv.FormatUint(hashKey(strings.Join(s.replicas, ",")), 16)
+}
+
+// Owns returns true when the asset is in the replica shard.
+func (s *Shard) Owns(assetID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.owner(hashKey(assetID)) == s.id
+}
+
+// owner returns the replica owning the hash, the caller is expected to hold the lock.
+func (s *Shard) owner(hash uint64) string {
+	idx := sort.Search(len(s.ring), func(i int) bool { return s.ring[i].hash >= hash })
+	if idx == len(s.ring) {
+		idx = 0
+	}
+
+	return s.ring[idx].replica
+}
+
+// Filter returns the assets in the replica shard, all assets are returned when the shard is nil.
+func (s *Shard) Filter(assets []*model.Asset) []*model.Asset {
+	if s == nil {
+		return assets
+	}
+
+	owned := make([]*model.Asset, 0, len(assets))
+
+	for _, asset := range assets {
+		if s.Owns(asset.ID) {
+			owned = append(owned, asset)
+		}
+	}
+
+	return owned
+}
+
+// hashKey returns the position of the key on the hash ring,
+// the FNV hash is finalized with the splitmix64 mix for keys differing in their last bytes to spread across the ring.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	// nolint:gomnd // splitmix64 constants
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_Shard_Owns(t *testing.T) {
+	replicas := []string{"alloy/a", "alloy/b", "alloy/c"}
+
+	shards := make([]*Shard, 0, len(replicas))
+	for _, replica := range replicas {
+		shard := NewShard(replica)
+		shard.SetReplicas(replicas)
+
+		shards = append(shards, shard)
+	}
+	assets := make([]*model.Asset, 0, 3000)
+	for idx := 0; idx < 3000; idx++ {
+		assets = append(assets, &model.Asset{ID: strconv.Itoa(idx)})
+	}
+
+	var total float64
+
+	for _, shard := range shards {
+		// each replica owns roughly an equal share of the assets
+		assert.InDelta(t, 1.0/3, shard.Share(), 0.1)
+
+		total += shard.Share()
+	}
+
+	assert.InDelta(t, 1, total, 0.0001)
+
+	// each asset is owned by exactly one replica
+	for _, asset := range assets {
+		var owners int
+
+		for _, shard := range shards {
+			if shard.Owns(asset.ID) {
+				owners++
+			}
+		}
+
+		assert.Equal(t, 1, owners, asset.ID)
+	}
+}
+
+func Test_Shard_Rebalance(t *testing.T) {
+	shard := NewShard("alloy/a")
+	assets := make([]*model.Asset, 0, 3000)
+	for idx := 0; idx < 3000; idx++ {
+		assets = append(assets, &model.Asset{ID: strconv.Itoa(idx)})
+	}
+
+	// a replica owns all assets until the replicas are set
+	assert.Equal(t, float64(1), shard.Share())
+	assert.Len(t, shard.Filter(assets), len(assets))
+
+	assert.True(t, shard.SetReplicas([]string{"alloy/a", "alloy/b"}))
+	assert.False(t, shard.SetReplicas([]string{"alloy/b", "alloy/a"}))
+
+	before := shard.Filter(assets)
+
+	// a replica joining takes over assets from the other replicas, the replica does not gain any.
+	assert.True(t, shard.SetReplicas([]string{"alloy/a", "alloy/b", "alloy/c"}))
+
+	after := shard.Filter(assets)
+	assert.Less(t, len(after), len(before))
+
+	owned := map[string]bool{}
+	for _, asset := range before {
+		owned[asset.ID] = true
+	}
+
+	for _, asset := range after {
+		assert.True(t, owned[asset.ID], asset.ID)
+	}
+
+	// the replica itself is always included
+	assert.True(t, shard.SetReplicas(nil))
+	assert.Equal(t, []string{"alloy/a"}, shard.Replicas())
+	assert.Len(t, shard.Filter(assets), len(assets))
+}
+
+func Test_Shard_FilterNil(t *testing.T) {
+	var shard *Shard
+
+	assets := []*model.Asset{{ID: strconv.Itoa(1)}, {ID: strconv.Itoa(2)}}
+	assert.Equal(t, assets, shard.Filter(assets))
+}
+
+func Test_Shard_Pinned(t *testing.T) {
+	assets := make([]*model.Asset, 0, 3000)
+	for idx := 0; idx < 3000; idx++ {
+		assets = append(assets, &model.Asset{ID: strconv.Itoa(idx)})
+	}
+
+	shard := NewShard("alloy/a")
+	shard.SetReplicas([]string{"alloy/b"})
+
+	pinned := shard.Pinned()
+	owned := shard.Filter(assets)
+
+	// the pinned shard is not rebalanced when a replica joins
+	assert.True(t, shard.SetReplicas([]string{"alloy/b", "alloy/c"}))
+	assert.Less(t, len(shard.Filter(assets)), len(owned))
+	assert.Equal(t, owned, pinned.Filter(assets))
+	assert.Equal(t, []string{"alloy/a", "alloy/b"}, pinned.Replicas())
+	assert.NotEqual(t, shard.Fingerprint(), pinned.Fingerprint())
+
+	var none *Shard
+	assert.Nil(t, none.Pinned())
+}
+
+func Test_IterInBatches_Sharded(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	assets := make([]*model.Asset, 0, 20)
+	for idx := 0; idx < 20; idx++ {
+		assets = append(assets, &model.Asset{ID: strconv.Itoa(idx)})
+	}
+
+	shard := NewShard("alloy/a")
+	shard.SetReplicas([]string{"alloy/b"})
+
+	owned := shard.Filter(assets)
+
+	// the assets are listed without BMC credentials
+	assetIterator := NewAssetIterator(&listingStore{assetsStore{assets: assets}}, logrus.New())
+	assetIterator.shard = shard
+
+	var got []*model.Asset
+
+	var syncWG sync.WaitGroup
+
+	syncWG.Add(1)
+
+	go func() {
+		defer syncWG.Done()
+
+		for asset := range assetIterator.Channel() {
+			// a replica joins during the sweep, the shard of the sweep is unchanged.
+			if len(got) == 0 {
+				shard.SetReplicas([]string{"alloy/b", "alloy/c"})
+			}
+
+			got = append(got, asset)
+		}
+	}()
+
+	assetIterator.IterInBatches(context.TODO(), 2, NewPauser())
+	syncWG.Wait()
+
+	assert.Equal(t, assetIDs(owned), assetIDs(got))
+}
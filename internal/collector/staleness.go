@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+
+	"github.com/metal-toolbox/alloy/internal/metrics"
+	"github.com/metal-toolbox/alloy/internal/model"
+	"github.com/metal-toolbox/alloy/internal/store"
+)
+
+// staleness priorities, assets are collected in the order of the priority value.
+const (
+	priorityNeverCollected = iota
+	priorityFailed
+	priorityMaxAge
+	priorityNone
+)
+
+var priorityLabels = map[int]string{
+	priorityNeverCollected: "never_collected",
+	priorityFailed:         "failed",
+	priorityMaxAge:         "max_age",
+	priorityNone:           "none",
+}
+
+// stalenessPriority returns the collection priority of the asset,
+// assets never collected and assets that failed the last collection are collected first,
+// followed by assets last collected before the maxAge, when set.
+func stalenessPriority(asset *model.Asset, maxAge time.Duration, now time.Time) int {
+	switch {
+	case asset.LastCollectedAt.IsZero():
+		return priorityNeverCollected
+	case asset.LastCollectFailed:
+		return priorityFailed
+	case maxAge > 0 && asset.LastCollectedAt.Before(now.Add(-maxAge)):
+		return priorityMaxAge
+	default:
+		return priorityNone
+	}
+}
+
+// sortByStaleness orders the assets by their staleness priority,
+// assets with the same priority are ordered by the time they were last collected, the least recent first.
+//
+// The count of assets by priority is returned.
+func sortByStaleness(assets []*model.Asset, maxAge time.Duration, now time.Time) map[int]int {
+	priorities := make(map[*model.Asset]int, len(assets))
+	counts := make(map[int]int, len(priorityLabels))
+
+	for _, asset := range assets {
+		priorities[asset] = stalenessPriority(asset, maxAge, now)
+		counts[priorities[asset]]++
+	}
+
+	sort.SliceStable(assets, func(i, j int) bool {
+		if priorities[assets[i]] != priorities[assets[j]] {
+			return priorities[assets[i]] < priorities[assets[j]]
+		}
+
+		return assets[i].LastCollectedAt.Before(assets[j].LastCollectedAt)
+	})
+
+	return counts
+}
+
+// IterByStaleness queries the store for all assets in batches, and returns them over the assetCh ordered by staleness,
+// assets never collected and assets that failed the last collection are returned first,
+// followed by the assets last collected more than maxAge ago and then the rest of the assets,
+// each in the order they were last collected.
+//
+// The assets are listed without their BMC credentials, the collector looks up the credentials of each asset it collects.
+// With a shard set, the shard is pinned for the sweep and the replicas joining or leaving apply from the following sweep.
+func (s *AssetIterator) IterByStaleness(ctx context.Context, batchSize int, maxAge time.Duration, pauser *Pauser) {
+	defer close(s.assetCh)
+
+	tracer := otel.Tracer("collector.AssetIterator")
+	ctx, span := tracer.Start(ctx, "IterByStaleness()")
+
+	defer span.End()
+
+	s.shard = s.shard.Pinned()
+
+	assets, err := s.listAll(ctx, batchSize)
+	if err != nil {
+		if errors.Is(err, ErrFetcherQuery) {
+			metrics.FleetDBAPIQueryErrorCount.With(stageLabelFetcher).Inc()
+		}
+
+		s.logger.WithError(err).Error(ErrFetcherQuery)
+
+		return
+	}
+
+	counts := sortByStaleness(assets, maxAge, time.Now())
+
+	fields := logrus.Fields{"total": len(assets)}
+
+	for priority, label := range priorityLabels {
+		metrics.AssetsScheduledByStaleness.With(prometheus.Labels{"priority": label}).Set(float64(counts[priority]))
+		fields[label] = counts[priority]
+	}
+
+	s.logger.WithFields(fields).Info("assets ordered by staleness")
+
+	s.send(ctx, assets, pauser)
+}
+
+// listAll returns all the assets selected in the store and owned by this replica, listed in batches without BMC credentials.
+func (s *AssetIterator) listAll(ctx context.Context, batchSize int) ([]*model.Asset, error) {
+	var all []*model.Asset
+
+	for offset := 1; ; offset++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		assets, total, err := store.ListAssets(ctx, s.store, s.selector, offset, batchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		// count assets retrieved
+		metrics.FleetDBAPIAssetsRetrieved.With(stageLabelFetcher).Add(float64(len(assets)))
+
+		all = append(all, s.shard.Filter(assets)...)
+
+		// the selector may be applied after the store query and return fewer assets than the batch size,
+		// and so the pages queried are compared with the total count instead.
+		if offset*batchSize >= total {
+			return all, nil
+		}
+	}
+}
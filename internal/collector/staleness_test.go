@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+// listingStore is an assetsStore that lists the assets only without their BMC credentials.
+type listingStore struct {
+	assetsStore
+}
+
+func (s *listingStore) AssetsByOffsetLimit(_ context.Context, _, _ int) (assets []*model.Asset, totalAssets int, err error) {
+	return nil, 0, errors.New("assets listed with BMC credentials")
+}
+
+func (s *listingStore) ListAssets(ctx context.Context, _ *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	return s.assetsStore.AssetsByOffsetLimit(ctx, offset, limit)
+}
+
+func Test_sortByStaleness(t *testing.T) {
+	now := time.Now()
+
+	listed := []*model.Asset{
+		{ID: "current-1h", LastCollectedAt: now.Add(-time.Hour)},
+		{ID: "stale-48h", LastCollectedAt: now.Add(-48 * time.Hour)},
+		{ID: "failed-2h", LastCollectedAt: now.Add(-2 * time.Hour), LastCollectFailed: true},
+		{ID: "current-3h", LastCollectedAt: now.Add(-3 * time.Hour)},
+		{ID: "never-1"},
+		{ID: "stale-72h", LastCollectedAt: now.Add(-72 * time.Hour)},
+		{ID: "failed-30h", LastCollectedAt: now.Add(-30 * time.Hour), LastCollectFailed: true},
+		{ID: "never-2", LastCollectFailed: true},
+	}
+
+	testcases := []struct {
+		name           string
+		maxAge         time.Duration
+		expectedIDs    []string
+		expectedCounts map[int]int
+	}{
+		{
+			"with max age",
+			24 * time.Hour,
+			[]string{"never-1", "never-2", "failed-30h", "failed-2h", "stale-72h", "stale-48h", "current-3h", "current-1h"},
+			map[int]int{priorityNeverCollected: 2, priorityFailed: 2, priorityMaxAge: 2, priorityNone: 2},
+		},
+		{
+			"without max age",
+			0,
+			[]string{"never-1", "never-2", "failed-30h", "failed-2h", "stale-72h", "stale-48h", "current-3h", "current-1h"},
+			map[int]int{priorityNeverCollected: 2, priorityFailed: 2, priorityNone: 4},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assets := append([]*model.Asset{}, listed...)
+
+			counts := sortByStaleness(assets, tc.maxAge, now)
+
+			assert.Equal(t, tc.expectedIDs, assetIDs(assets))
+			assert.Equal(t, tc.expectedCounts, counts)
+		})
+	}
+}
+
+func Test_IterByStaleness(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	now := time.Now()
+
+	repository := &listingStore{
+		assetsStore{
+			assets: []*model.Asset{
+				{ID: "current-1h", LastCollectedAt: now.Add(-time.Hour)},
+				{ID: "stale-48h", LastCollectedAt: now.Add(-48 * time.Hour)},
+				{ID: "failed-2h", LastCollectedAt: now.Add(-2 * time.Hour), LastCollectFailed: true},
+				{ID: "never-1"},
+				{ID: "stale-72h", LastCollectedAt: now.Add(-72 * time.Hour)},
+			},
+		},
+	}
+
+	// the batch size is less than the total, and not a divisor of it, to have the assets listed across pages,
+	// the assets are listed without BMC credentials.
+	assetIterator := NewAssetIterator(repository, logrus.New())
+
+	var got []*model.Asset
+
+	var syncWG sync.WaitGroup
+
+	syncWG.Add(1)
+
+	go func() {
+		defer syncWG.Done()
+
+		for asset := range assetIterator.Channel() {
+			got = append(got, asset)
+		}
+	}()
+
+	assetIterator.IterByStaleness(context.TODO(), 2, 24*time.Hour, NewPauser())
+	syncWG.Wait()
+
+	assert.Equal(t, []string{"never-1", "failed-2h", "stale-72h", "stale-48h", "current-1h"}, assetIDs(got))
+}
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+var (
+	ErrMaintenanceWindow = errors.New("maintenance window configuration error")
+
+	// windowCheckInterval is the interval at which the facility maintenance windows are checked while collecting.
+	windowCheckInterval = time.Minute
+)
+
+// windowCronParser parses the window start cron expressions - minute hour day-of-month month day-of-week,
+// descriptors such as @every are not accepted since they are not set at fixed times.
+var windowCronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+const (
+	// DeferReasonFacilityWindow is the reason work is deferred when the facility maintenance windows are closed.
+	DeferReasonFacilityWindow = "facility_window_closed"
+
+	// DeferReasonAssetWindow is the reason work is deferred when the asset vendor, model maintenance windows are closed.
+	DeferReasonAssetWindow = "asset_window_closed"
+)
+
+// window is a recurring time window, opened at the times set by the cron schedule for the duration.
+type window struct {
+	start    cron.Schedule
+	duration time.Duration
+}
+
+// windowEntry holds the windows for the assets matching the facility, vendor, model patterns.
+type windowEntry struct {
+	facility string
+	vendor   string
+	model    string
+	location *time.Location
+	windows  []window
+}
+
+// MaintenanceWindows defers collections to the time windows configured by the asset facility, vendor and model.
+//
+// The first entry matching an asset applies, assets not matching an entry are collected at any time.
+type MaintenanceWindows struct {
+	entries []windowEntry
+}
+
+// NewMaintenanceWindows returns the MaintenanceWindows for the configured entries, nil is returned when none are configured.
+func NewMaintenanceWindows(configured []app.MaintenanceWindowOptions) (*MaintenanceWindows, error) {
+	if len(configured) == 0 {
+		return nil, nil // nolint:nilnil // collections are not deferred without windows configured
+	}
+
+	m := &MaintenanceWindows{}
+
+	for _, opts := range configured {
+		entry := windowEntry{facility: opts.Facility, vendor: opts.Vendor, model: opts.Model, location: time.UTC}
+
+		if opts.Timezone != "" {
+			location, err := time.LoadLocation(opts.Timezone)
+			if err != nil {
+				return nil, errors.Wrap(ErrMaintenanceWindow, err.Error())
+			}
+
+			entry.location = location
+		}
+
+		if len(opts.Windows) == 0 {
+			return nil, errors.Wrap(ErrMaintenanceWindow, "no windows set for entry: "+entry.String())
+		}
+
+		for _, w := range opts.Windows {
+			start, err := windowCronParser.Parse(w.Start)
+			if err != nil {
+				return nil, errors.Wrap(ErrMaintenanceWindow, w.Start+": "+err.Error())
+			}
+
+			if w.Duration < time.Minute {
+				return nil, errors.Wrap(ErrMaintenanceWindow, "window duration expected to be at least a minute: "+w.Start)
+			}
+
+			entry.windows = append(entry.windows, window{start: start, duration: w.Duration})
+		}
+
+		m.entries = append(m.entries, entry)
+	}
+
+	return m, nil
+}
+
+// AssetOpen returns true when the windows of the first entry matching the asset are open,
+// or no entry matches the asset. When closed, the time the next window opens is returned,
+// the time is zero when no window opens in the following years.
+func (m *MaintenanceWindows) AssetOpen(asset *model.Asset, now time.Time) (open bool, next time.Time, entry string) {
+	if m == nil {
+		return true, time.Time{}, ""
+	}
+
+	for idx := range m.entries {
+		if m.entries[idx].matches(asset.Facility, asset.Vendor, asset.Model) {
+			open, next := m.entries[idx].open(now)
+			return open, next, m.entries[idx].String()
+		}
+	}
+
+	return true, time.Time{}, ""
+}
+
+// FacilityOpen returns true when the windows of the first entry matching the facility alone - with no vendor,
+// model patterns, are open, or no such entry matches the facility. When closed, the time the next window opens is returned.
+func (m *MaintenanceWindows) FacilityOpen(facility string, now time.Time) (open bool, next time.Time, entry string) {
+	if m == nil {
+		return true, time.Time{}, ""
+	}
+
+	for idx := range m.entries {
+		if m.entries[idx].vendor != "" || m.entries[idx].model != "" {
+			continue
+		}
+
+		if m.entries[idx].matches(facility, "", "") {
+			open, next := m.entries[idx].open(now)
+			return open, next, m.entries[idx].String()
+		}
+	}
+
+	return true, time.Time{}, ""
+}
+
+// String returns the entry patterns, for logging.
+func (e *windowEntry) String() string {
+	return "facility=" + e.facility + " vendor=" + e.vendor + " model=" + e.model
+}
+
+func (e *windowEntry) matches(facility, vendor, assetModel string) bool {
+	return model.MatchPattern(e.facility, facility) &&
+		model.MatchPattern(e.vendor, vendor) &&
+		model.MatchPattern(e.model, assetModel)
+}
+
+// open returns true when any of the entry windows is open at the time,
+// when closed the time the next window opens is returned.
+func (e *windowEntry) open(now time.Time) (bool, time.Time) {
+	local := now.In(e.location)
+
+	var next time.Time
+
+	for _, w := range e.windows {
+		// the window is open when it started within its duration before now,
+		// the schedules are set in minutes and so a window started at now - duration is not open.
+		if start := w.start.Next(local.Add(-w.duration)); !start.IsZero() && !start.After(local) {
+			return true, time.Time{}
+		}
+
+		if start := w.start.Next(local); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+
+	return false, next
+}
+
+// DeferDelay returns the delay until the next window opens, at least the given minimum,
+// zero is returned when the time the next window opens is not known.
+func DeferDelay(next, now time.Time, minimum time.Duration) time.Duration {
+	if next.IsZero() {
+		return 0
+	}
+
+	return max(next.Sub(now), minimum)
+}
+
+// FormatWindowTime returns the time the next window opens for logging, unknown when none opens.
+func FormatWindowTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_windowEntry_open(t *testing.T) {
+	// Monday 2024-06-03 22:30 UTC
+	monday := time.Date(2024, 6, 3, 22, 30, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name         string
+		expression   string
+		duration     time.Duration
+		at           time.Time
+		expectedOpen bool
+		expectedNext time.Time
+	}{
+		{"any", "* * * * *", time.Minute, monday, true, time.Time{}},
+		{"hour range, weekdays", "0 22-23 * * 1-5", time.Hour, monday, true, time.Time{}},
+		{"weekend", "0 22 * * sat,sun", time.Hour, monday, false, time.Date(2024, 6, 8, 22, 0, 0, 0, time.UTC)},
+		{"opened within the duration", "0 20 * * *", 3 * time.Hour, monday, true, time.Time{}},
+		{"closed at the end of the duration", "30 20 * * *", 2 * time.Hour, monday, false, time.Date(2024, 6, 4, 20, 30, 0, 0, time.UTC)},
+		{"opens at the time", "30 22 * * *", time.Minute, monday, true, time.Time{}},
+		{"step", "*/15 */2 * * *", time.Minute, monday, true, time.Time{}},
+		{"day of month or day of week", "30 22 15 * 1", time.Minute, monday, true, time.Time{}},
+		{"day of month and month", "30 22 3 7 *", time.Minute, monday, false, time.Date(2024, 7, 3, 22, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, err := windowCronParser.Parse(tc.expression)
+			require.Nil(t, err)
+
+			entry := &windowEntry{location: time.UTC, windows: []window{{start: start, duration: tc.duration}}}
+
+			open, next := entry.open(tc.at)
+			assert.Equal(t, tc.expectedOpen, open)
+			assert.True(t, tc.expectedNext.Equal(next), next)
+		})
+	}
+}
+
+func Test_MaintenanceWindows(t *testing.T) {
+	windows, err := NewMaintenanceWindows([]app.MaintenanceWindowOptions{
+		{
+			Vendor:   "supermicro",
+			Model:    "x11*",
+			Timezone: "America/New_York",
+			Windows:  []app.WindowOptions{{Start: "0 22 * * 1-5", Duration: 8 * time.Hour}},
+		},
+		{
+			Facility: "dc13",
+			Windows:  []app.WindowOptions{{Start: "0 0 * * 6", Duration: 48 * time.Hour}},
+		},
+	})
+	require.Nil(t, err)
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.Nil(t, err)
+
+	supermicro := &model.Asset{ID: "1", Facility: "dc13", Vendor: "Supermicro", Model: "X11DPH-T"}
+	dell := &model.Asset{ID: "2", Facility: "dc13", Vendor: "dell", Model: "r6515"}
+	other := &model.Asset{ID: "3", Facility: "dc14", Vendor: "dell", Model: "r6515"}
+
+	// Tuesday 23:30 in New York
+	tuesdayNight := time.Date(2024, 6, 4, 23, 30, 0, 0, newYork)
+
+	open, _, _ := windows.AssetOpen(supermicro, tuesdayNight)
+	assert.True(t, open)
+
+	// Wednesday 05:59, the window opened on Tuesday 22:00 is still open
+	open, _, _ = windows.AssetOpen(supermicro, time.Date(2024, 6, 5, 5, 59, 0, 0, newYork))
+	assert.True(t, open)
+
+	// Wednesday 06:00, the window closed, and opens again at 22:00
+	open, next, entry := windows.AssetOpen(supermicro, time.Date(2024, 6, 5, 6, 0, 0, 0, newYork))
+	assert.False(t, open)
+	assert.Equal(t, time.Date(2024, 6, 5, 22, 0, 0, 0, newYork), next)
+	assert.Contains(t, entry, "vendor=supermicro")
+
+	// the facility entry applies to the other assets in the facility
+	open, next, _ = windows.AssetOpen(dell, tuesdayNight)
+	assert.False(t, open)
+	assert.Equal(t, time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC), next)
+
+	open, _, _ = windows.FacilityOpen("dc13", time.Date(2024, 6, 9, 12, 0, 0, 0, time.UTC))
+	assert.True(t, open)
+
+	// assets not matching an entry are not deferred
+	open, _, _ = windows.AssetOpen(other, tuesdayNight)
+	assert.True(t, open)
+
+	open, _, _ = windows.FacilityOpen("dc14", tuesdayNight)
+	assert.True(t, open)
+
+	// no windows configured
+	var none *MaintenanceWindows
+
+	open, _, _ = none.AssetOpen(supermicro, tuesdayNight)
+	assert.True(t, open)
+}
+
+func Test_NewMaintenanceWindows_Invalid(t *testing.T) {
+	testcases := []struct {
+		name    string
+		options app.MaintenanceWindowOptions
+	}{
+		{"timezone", app.MaintenanceWindowOptions{Timezone: "Mars/Olympus", Windows: []app.WindowOptions{{Start: "* * * * *", Duration: time.Hour}}}},
+		{"no windows", app.MaintenanceWindowOptions{Facility: "dc13"}},
+		{"cron fields", app.MaintenanceWindowOptions{Windows: []app.WindowOptions{{Start: "* * *", Duration: time.Hour}}}},
+		{"cron range", app.MaintenanceWindowOptions{Windows: []app.WindowOptions{{Start: "60 * * * *", Duration: time.Hour}}}},
+		{"cron descriptor", app.MaintenanceWindowOptions{Windows: []app.WindowOptions{{Start: "@every 1h", Duration: time.Hour}}}},
+		{"duration", app.MaintenanceWindowOptions{Windows: []app.WindowOptions{{Start: "* * * * *"}}}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewMaintenanceWindows([]app.MaintenanceWindowOptions{tc.options})
+			assert.ErrorIs(t, err, ErrMaintenanceWindow)
+		})
+	}
+}
+
+func Test_DeferDelay(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, time.Minute, DeferDelay(now.Add(time.Second), now, time.Minute))
+	assert.Equal(t, 10*time.Minute, DeferDelay(now.Add(10*time.Minute), now, time.Minute))
+	assert.Equal(t, 50*time.Hour, DeferDelay(now.Add(50*time.Hour), now, time.Minute))
+	assert.Equal(t, time.Duration(0), DeferDelay(time.Time{}, now, time.Minute))
+}
+
+func Test_holdOutsideWindows(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	// a window that opens once a year on a day that is not today.
+	start := "0 0 1 1 *"
+	if now := time.Now().UTC(); now.Month() == time.January && now.Day() == 1 {
+		start = "0 0 1 7 *"
+	}
+
+	windows, err := NewMaintenanceWindows([]app.MaintenanceWindowOptions{
+		{Facility: "dc13", Windows: []app.WindowOptions{{Start: start, Duration: time.Hour}}},
+	})
+	require.Nil(t, err)
+
+	d := &AssetIterCollector{logger: logrus.New()}
+	d.SetMaintenanceWindows(windows, "dc13")
+
+	pauser := NewPauser()
+
+	stop := d.holdOutsideWindows(context.TODO(), pauser)
+	assert.True(t, pauser.Held(DeferReasonFacilityWindow))
+
+	// the throttle does not release the hold
+	pauser.UnPause()
+	assert.True(t, pauser.Value())
+
+	stop()
+	assert.False(t, pauser.Value())
+
+	// facilities without windows are not held
+	d.SetMaintenanceWindows(windows, "dc14")
+
+	stop = d.holdOutsideWindows(context.TODO(), pauser)
+	assert.False(t, pauser.Value())
+	stop()
+}
+
+func Test_Collect_DeferredCheckpointed(t *testing.T) {
+	ignorefunc := "go.opencensus.io/stats/view.(*worker).start"
+	defer goleak.VerifyNone(t, goleak.IgnoreTopFunction(ignorefunc))
+
+	// a window that opens once a year on a day that is not today.
+	start := "0 0 1 1 *"
+	if now := time.Now().UTC(); now.Month() == time.January && now.Day() == 1 {
+		start = "0 0 1 7 *"
+	}
+
+	windows, err := NewMaintenanceWindows([]app.MaintenanceWindowOptions{
+		{Vendor: "dell", Windows: []app.WindowOptions{{Start: start, Duration: time.Hour}}},
+	})
+	require.Nil(t, err)
+
+	ctx := context.TODO()
+	filename := filepath.Join(t.TempDir(), "checkpoint.json")
+	logger := logrus.New()
+
+	// all assets are deferred, across pages of the store listing.
+	assets := make([]*model.Asset, 0, 5)
+	for idx := 0; idx < 5; idx++ {
+		assets = append(assets, &model.Asset{ID: strconv.Itoa(idx), Vendor: "dell"})
+	}
+
+	d := &AssetIterCollector{
+		concurrency:   2,
+		assetIterator: *NewAssetIterator(&assetsStore{assets: assets}, logger),
+		syncWG:        &sync.WaitGroup{},
+		logger:        logger,
+	}
+
+	// the checkpoint recorded by an earlier sweep
+	store := NewFileCheckpointStore(filename)
+	require.Nil(t, store.Put(ctx, &Checkpoint{Page: 1, BatchSize: 2, Total: 5}))
+
+	d.SetMaintenanceWindows(windows, "dc13")
+	d.SetCheckpointer(NewCheckpointer(store, 0.1, false, logger))
+
+	d.Collect(ctx)
+
+	// the deferred assets are done for the sweep, the checkpoint is removed once the sweep completes.
+	assert.NoFileExists(t, filename)
+}
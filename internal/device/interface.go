@@ -3,6 +3,7 @@ package device
 import (
 	"context"
 
+	"github.com/metal-toolbox/alloy/internal/app"
 	"github.com/metal-toolbox/alloy/internal/device/inband"
 	"github.com/metal-toolbox/alloy/internal/device/outofband"
 	"github.com/metal-toolbox/alloy/internal/model"
@@ -25,12 +26,30 @@ type Queryor interface {
 	BiosConfiguration(ctx context.Context, asset *model.Asset) error
 }
 
-func NewQueryor(kind model.AppKind, logger *logrus.Logger) (Queryor, error) {
+// SessionQueryor is implemented by a Queryor that is able to run all of its queries in a single device session.
+type SessionQueryor interface {
+	// Collect retrieves the device inventory and bios configuration in a single session
+	// and updates the given asset object with the data collected.
+	Collect(ctx context.Context, asset *model.Asset) error
+}
+
+// NewQueryor returns a Queryor for the app kind, the configuration is optional and the defaults apply when its nil.
+func NewQueryor(kind model.AppKind, cfg *app.Configuration, logger *logrus.Logger) (Queryor, error) {
 	switch kind {
 	case model.AppKindInband:
 		return inband.NewQueryor(logger), nil
 	case model.AppKindOutOfBand:
-		return outofband.NewQueryor(logger), nil
+		var outofbandOptions *app.OutofbandOptions
+		if cfg != nil {
+			outofbandOptions = cfg.OutofbandOptions
+		}
+
+		queryor, err := outofband.NewQueryor(outofbandOptions, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return queryor, nil
 	default:
 		return nil, errors.Wrap(ErrQueryor, "unsupported device queryor: "+string(kind))
 	}
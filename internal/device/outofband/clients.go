@@ -0,0 +1,107 @@
+package outofband
+
+import (
+	"fmt"
+
+	"github.com/jacobweinstock/registrar"
+	"github.com/metal-toolbox/bmclib"
+	"github.com/metal-toolbox/bmclib/providers/ipmitool"
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+// bmcClientOptions returns the bmclib providers and timeouts for the asset.
+//
+// The first configured entry matching the asset vendor, model applies, with the parameters it leaves unset
+// taken from the first matching default entry.
+func bmcClientOptions(configured []app.BMCClientOptions, asset *model.Asset) app.BMCClientOptions {
+	defaults := app.DefaultBMCClients()
+
+	// the last default entry matches any asset.
+	opts := defaults[len(defaults)-1]
+	if match := matchBMCClientOptions(defaults, asset); match != nil {
+		opts = *match
+	}
+
+	match := matchBMCClientOptions(configured, asset)
+	if match == nil {
+		return opts
+	}
+
+	if len(match.Providers) > 0 {
+		opts.Providers = match.Providers
+	}
+
+	if match.ProviderTimeout > 0 {
+		opts.ProviderTimeout = match.ProviderTimeout
+	}
+
+	opts.Vendor = match.Vendor
+	opts.Model = match.Model
+	opts.Deadline = match.Deadline
+
+	return opts
+}
+
+// matchBMCClientOptions returns the first entry with vendor, model patterns matching the asset.
+func matchBMCClientOptions(entries []app.BMCClientOptions, asset *model.Asset) *app.BMCClientOptions {
+	for idx := range entries {
+		if model.MatchPattern(entries[idx].Vendor, asset.Vendor) && model.MatchPattern(entries[idx].Model, asset.Model) {
+			return &entries[idx]
+		}
+	}
+
+	return nil
+}
+
+// validateBMCClients returns an error when a configured provider is none of the bmclib provider names or protocols,
+// for a misspelled provider not to leave the asset without providers to collect with.
+func validateBMCClients(configured []app.BMCClientOptions) error {
+	// the ipmitool provider is registered only when the ipmitool binary is present on the host.
+	known := map[string]bool{ipmitool.ProviderName: true, ipmitool.ProviderProtocol: true}
+
+	for _, driver := range bmclib.NewClient("", "", "").Registry.Drivers {
+		known[driver.Name] = true
+		known[driver.Protocol] = true
+	}
+
+	for _, entry := range configured {
+		for _, provider := range entry.Providers {
+			if !known[provider] {
+				return errors.Wrap(
+					ErrBMCClientOptions,
+					fmt.Sprintf("provider %q for vendor %q, model %q is no bmclib provider or protocol", provider, entry.Vendor, entry.Model),
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// selectDrivers returns the registered drivers in the order of the given providers,
+// each provider is either a driver name or a protocol.
+func selectDrivers(registry *registrar.Registry, providers []string) registrar.Drivers {
+	selected := registrar.Drivers{}
+	seen := map[string]bool{}
+
+	for _, provider := range providers {
+		drivers := registry.For(provider)
+		if len(drivers) == 0 {
+			drivers = registry.Using(provider)
+		}
+
+		for _, driver := range drivers {
+			if seen[driver.Name] {
+				continue
+			}
+
+			seen[driver.Name] = true
+			selected = append(selected, driver)
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,102 @@
+package outofband
+
+import (
+	"testing"
+	"time"
+
+	"github.com/metal-toolbox/bmclib"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_BMCClientOptions(t *testing.T) {
+	configured := []app.BMCClientOptions{
+		{Vendor: "supermicro", Model: "x11*", Providers: []string{"supermicro", "gofish"}, Deadline: 5 * time.Minute},
+		{Vendor: "dell", ProviderTimeout: 60 * time.Second},
+	}
+
+	cases := []struct {
+		name     string
+		asset    *model.Asset
+		expected app.BMCClientOptions
+	}{
+		{
+			"configured vendor, model pattern",
+			&model.Asset{Vendor: "supermicro", Model: "X11DPH-T"},
+			app.BMCClientOptions{
+				Vendor:          "supermicro",
+				Model:           "x11*",
+				Providers:       []string{"supermicro", "gofish"},
+				ProviderTimeout: 180 * time.Second,
+				Deadline:        5 * time.Minute,
+			},
+		},
+		{
+			"configured timeout, default providers",
+			&model.Asset{Vendor: "dell", Model: "r6515"},
+			app.BMCClientOptions{Vendor: "dell", Providers: []string{"redfish"}, ProviderTimeout: 60 * time.Second},
+		},
+		{
+			"model not matched, default",
+			&model.Asset{Vendor: "supermicro", Model: "X12"},
+			app.BMCClientOptions{Providers: []string{"redfish", "vendorapi"}, ProviderTimeout: 180 * time.Second},
+		},
+		{
+			"default vendor",
+			&model.Asset{Vendor: "asrockrack"},
+			app.BMCClientOptions{Vendor: "asrockrack", Providers: []string{"vendorapi"}, ProviderTimeout: 180 * time.Second},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, bmcClientOptions(configured, tc.asset))
+		})
+	}
+}
+
+func Test_SelectDrivers(t *testing.T) {
+	client := bmclib.NewClient("127.0.0.1", "foo", "bar")
+
+	drivers := selectDrivers(client.Registry, []string{"supermicro", "redfish", "gofish"})
+
+	names := []string{}
+	for _, driver := range drivers {
+		names = append(names, driver.Name)
+	}
+
+	// the named provider is first, followed by the redfish providers without duplicates
+	assert.Equal(t, "supermicro", names[0])
+	assert.Contains(t, names, "gofish")
+	assert.Contains(t, names, "dell")
+	assert.NotContains(t, names, "asrockrack")
+	assert.Equal(t, len(names), len(drivers))
+
+	assert.Empty(t, selectDrivers(client.Registry, []string{"foo"}))
+}
+
+func Test_validateBMCClients(t *testing.T) {
+	testcases := []struct {
+		name       string
+		configured []app.BMCClientOptions
+		err        bool
+	}{
+		{"defaults", app.DefaultBMCClients(), false},
+		{"providers, protocols", []app.BMCClientOptions{{Vendor: "supermicro", Providers: []string{"supermicro", "redfish", "ipmi"}}}, false},
+		{"unknown provider", []app.BMCClientOptions{{Vendor: "dell", Providers: []string{"redfish", "idrac"}}}, true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateBMCClients(tc.configured)
+			if tc.err {
+				assert.ErrorIs(t, err, ErrBMCClientOptions)
+				return
+			}
+
+			assert.Nil(t, err)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package outofband
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorClass classifies a BMC error as transient or permanent.
+type ErrorClass string
+
+const (
+	// ErrorClassTransient errors are expected to clear and the operation is retried.
+	ErrorClassTransient ErrorClass = "transient"
+
+	// ErrorClassPermanent errors are not expected to clear with a retry.
+	ErrorClassPermanent ErrorClass = "permanent"
+)
+
+const (
+	// reasonUnauthorized is the reason for errors on credentials rejected by the BMC.
+	reasonUnauthorized = "unauthorized"
+
+	// reasonConnTimeout is the reason for errors on BMC connections and requests timing out.
+	reasonConnTimeout = "conn_timeout"
+)
+
+// errorClassifier classifies errors which contain any of the substrings, or any of the HTTP status codes.
+type errorClassifier struct {
+	class       ErrorClass
+	reason      string
+	substrings  []string
+	statusCodes []int
+}
+
+// statusCodePatterns match the HTTP status codes in the errors returned for BMC responses,
+//
+// gofish errors are the status code followed by the response body - "500: {...}",
+// the bmclib vendor API errors include the status code as - "non 200 response: 500".
+var statusCodePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?:^|[\s*])([1-5][0-9]{2}): `),
+	regexp.MustCompile(`non 200 response: ([1-5][0-9]{2})\b`),
+}
+
+// errorClassifiers are matched in order, the first match classifies the error.
+var errorClassifiers = []errorClassifier{
+	{
+		ErrorClassTransient,
+		reasonConnTimeout,
+		[]string{"operation timed out", "i/o timeout", "context deadline exceeded", "Client.Timeout exceeded", "TLS handshake timeout"},
+		nil,
+	},
+	{
+		ErrorClassTransient,
+		"conn_error",
+		[]string{"connection refused", "connection reset", "broken pipe", "no route to host", "unexpected EOF"},
+		nil,
+	},
+	{
+		ErrorClassTransient,
+		"session_exhausted",
+		[]string{"maximum number of sessions", "session limit", "no more sessions", "SessionLimitExceeded", "too many sessions"},
+		nil,
+	},
+	{
+		ErrorClassTransient,
+		"server_error",
+		[]string{"Internal Server Error", "Service Unavailable", "Bad Gateway", "Gateway Timeout"},
+		[]int{500, 502, 503, 504},
+	},
+	{
+		ErrorClassPermanent,
+		reasonUnauthorized,
+		[]string{"failed to login", "Unauthorized"},
+		[]int{401, 403},
+	},
+	{
+		ErrorClassPermanent,
+		"redfish_incompatible",
+		[]string{"no compatible System Odata IDs identified"},
+		nil,
+	},
+	{
+		ErrorClassPermanent,
+		"unsupported",
+		[]string{"implementations found", "not supported", "not implemented"},
+		[]int{404},
+	},
+}
+
+// classifyError returns the class of the error and the reason it was classified so.
+//
+// Errors that are not identified are classified as permanent with the reason other.
+func classifyError(err error) (class ErrorClass, reason string) {
+	if err == nil {
+		return "", ""
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTransient, reasonConnTimeout
+	}
+
+	msg := err.Error()
+	codes := statusCodes(msg)
+
+	for _, classifier := range errorClassifiers {
+		for _, substring := range classifier.substrings {
+			if strings.Contains(msg, substring) {
+				return classifier.class, classifier.reason
+			}
+		}
+
+		for _, code := range classifier.statusCodes {
+			if codes[code] {
+				return classifier.class, classifier.reason
+			}
+		}
+	}
+
+	return ErrorClassPermanent, "other"
+}
+
+// statusCodes returns the HTTP status codes included in the error message in the forms bmclib, gofish return them.
+func statusCodes(msg string) map[int]bool {
+	codes := map[int]bool{}
+
+	for _, pattern := range statusCodePatterns {
+		for _, match := range pattern.FindAllStringSubmatch(msg, -1) {
+			if code, err := strconv.Atoi(match[1]); err == nil {
+				codes[code] = true
+			}
+		}
+	}
+
+	return codes
+}
+
+// classifiedErrorValue returns the asset error value for the error.
+func classifiedErrorValue(class ErrorClass, reason string, err error) string {
+	return string(class) + ": " + reason + ": " + err.Error()
+}
@@ -0,0 +1,145 @@
+package outofband
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_ClassifyError(t *testing.T) {
+	cases := []struct {
+		err    error
+		class  ErrorClass
+		reason string
+	}{
+		{errors.New("dial tcp 10.0.0.1:443: i/o timeout"), ErrorClassTransient, "conn_timeout"},
+		{errors.Wrap(context.DeadlineExceeded, "redfish"), ErrorClassTransient, "conn_timeout"},
+		{errors.New("dial tcp 10.0.0.1:443: connect: connection refused"), ErrorClassTransient, "conn_error"},
+		{errors.New("503: maximum number of sessions reached"), ErrorClassTransient, "session_exhausted"},
+		{errors.New("500: Internal Server Error"), ErrorClassTransient, "server_error"},
+		{errors.New("401: Unauthorized"), ErrorClassPermanent, "unauthorized"},
+		{errors.New("no compatible System Odata IDs identified"), ErrorClassPermanent, "redfish_incompatible"},
+		{errors.New("no BiosConfigurationGetter implementations found"), ErrorClassPermanent, "unsupported"},
+		{errors.New("redfish: 404: {\"error\": \"not found\"}"), ErrorClassPermanent, "unsupported"},
+		{errors.New("1 error occurred:\n\t* 502: Bad Gateway"), ErrorClassTransient, "server_error"},
+		{errors.New("non 200 response: 503"), ErrorClassTransient, "server_error"},
+		{errors.New("non 200 response: 401"), ErrorClassPermanent, "unauthorized"},
+		{errors.New("sensor 1404: reading failed"), ErrorClassPermanent, "other"},
+		{errors.New("dial tcp 10.0.0.1:500: connect: no such host"), ErrorClassPermanent, "other"},
+		{errors.New("serial AB500: not registered"), ErrorClassPermanent, "other"},
+		{errors.New("foo"), ErrorClassPermanent, "other"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			class, reason := classifyError(tc.err)
+			assert.Equal(t, tc.class, class)
+			assert.Equal(t, tc.reason, reason)
+		})
+	}
+}
+
+func Test_LoginRetry(t *testing.T) {
+	policy := app.RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond, Multiplier: 2}
+
+	cases := []struct {
+		name       string
+		deadline   time.Duration
+		openErrs   []error
+		wantErr    bool
+		wantOpens  int
+		wantErrVal string
+	}{
+		{
+			"transient errors are retried",
+			time.Minute,
+			[]error{errors.New("i/o timeout"), errors.New("503: Service Unavailable")},
+			false,
+			3,
+			"",
+		},
+		{
+			"permanent errors are not retried",
+			time.Minute,
+			[]error{errors.New("401: Unauthorized")},
+			true,
+			1,
+			"permanent: unauthorized: 401: Unauthorized",
+		},
+		{
+			"attempts are limited",
+			time.Minute,
+			[]error{errors.New("i/o timeout"), errors.New("i/o timeout"), errors.New("i/o timeout")},
+			true,
+			3,
+			"transient: conn_timeout: i/o timeout",
+		},
+		{
+			"connection timeouts are not retried without a deadline",
+			0,
+			[]error{errors.New("i/o timeout"), errors.New("i/o timeout")},
+			true,
+			1,
+			"transient: conn_timeout: i/o timeout",
+		},
+		{
+			"transient errors are retried without a deadline",
+			0,
+			[]error{errors.New("503: Service Unavailable")},
+			false,
+			2,
+			"",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bmcQueryor := NewMockBmclibClient()
+			bmcQueryor.openErrs = tc.openErrs
+
+			queryor := &Queryor{
+				mockClient:    bmcQueryor,
+				logger:        logrus.NewEntry(logrus.New()),
+				retryPolicies: app.OutofbandRetryOptions{Login: policy},
+				bmcClients:    []app.BMCClientOptions{{Deadline: tc.deadline}},
+			}
+
+			asset := &model.Asset{}
+
+			_, err := queryor.bmcLogin(context.TODO(), asset)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrConnect)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			assert.Equal(t, tc.wantOpens, bmcQueryor.opens)
+
+			if tc.wantErrVal != "" {
+				assert.Equal(t, tc.wantErrVal, asset.Errors[string(LoginError)])
+			}
+		})
+	}
+}
+
+func Test_Backoff(t *testing.T) {
+	policy := app.RetryPolicy{InitialInterval: time.Second, MaxInterval: 3 * time.Second, Multiplier: 2}
+
+	assert.Equal(t, time.Second, backoff(policy, 1))
+	assert.Equal(t, 2*time.Second, backoff(policy, 2))
+	assert.Equal(t, 3*time.Second, backoff(policy, 3))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		wait := backoff(policy, 1)
+		assert.GreaterOrEqual(t, wait, 500*time.Millisecond)
+		assert.LessOrEqual(t, wait, 1500*time.Millisecond)
+	}
+}
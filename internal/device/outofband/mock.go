@@ -2,6 +2,7 @@ package outofband
 
 import (
 	"context"
+	"errors"
 
 	common "github.com/metal-toolbox/bmc-common"
 	"github.com/metal-toolbox/bmclib"
@@ -14,6 +15,13 @@ type MockBmclib struct {
 	device     *common.Device
 	connOpened bool
 	connClosed bool
+	// opens, closes counts the sessions opened and closed
+	opens  int
+	closes int
+	// sessionDropped when set fails GetPowerState until the next Open
+	sessionDropped bool
+	// openErrs are returned by Open in order, before it succeeds
+	openErrs []error
 }
 
 func NewMockBmclibClient() *MockBmclib {
@@ -21,15 +29,36 @@ func NewMockBmclibClient() *MockBmclib {
 }
 
 func (m *MockBmclib) Open(_ context.Context) error {
+	m.opens++
+
+	if len(m.openErrs) > 0 {
+		err := m.openErrs[0]
+		m.openErrs = m.openErrs[1:]
+
+		return err
+	}
+
 	m.connOpened = true
+	m.sessionDropped = false
+
 	return nil
 }
 
 func (m *MockBmclib) Close(_ context.Context) error {
 	m.connClosed = true
+	m.closes++
+
 	return nil
 }
 
+func (m *MockBmclib) GetPowerState(_ context.Context) (string, error) {
+	if m.sessionDropped {
+		return "", errors.New("401: session expired")
+	}
+
+	return "on", nil
+}
+
 func (m *MockBmclib) Inventory(_ context.Context) (*common.Device, error) {
 	return &common.Device{Common: common.Common{Vendor: "foo", Model: "bar"}}, nil
 }
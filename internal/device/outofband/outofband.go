@@ -3,11 +3,11 @@ package outofband
 import (
 	"context"
 	"os"
-	"strings"
+	"strconv"
 	"time"
 
 	logrusr "github.com/bombsimon/logrusr/v4"
-	"github.com/jacobweinstock/registrar"
+	"github.com/hashicorp/go-multierror"
 	common "github.com/metal-toolbox/bmc-common"
 	bmclib "github.com/metal-toolbox/bmclib"
 	"github.com/pkg/errors"
@@ -18,6 +18,8 @@ import (
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/helpers"
 	"github.com/metal-toolbox/alloy/internal/metrics"
 	"github.com/metal-toolbox/alloy/internal/model"
 )
@@ -27,27 +29,52 @@ var (
 	ErrBiosConfig = errors.New("BIOS configuration collection error")
 	ErrConnect    = errors.New("BMC connection error")
 	ErrBMCSession = errors.New("BMC session error")
+
+	ErrBMCClientOptions = errors.New("BMC client options error")
 )
 
 const (
 	// logoutTimeout is the timeout value for each bmc logout attempt.
 	logoutTimeout = "1m"
 
-	// bmclib will attempt multiple providers (drivers) - to perform an action,
-	// this is maximum amount of time bmclib will spend performing a query on a BMC.
-	bmclibProviderTimeout = 180 * time.Second
-
 	pkgName = "internal/outofband"
 
 	LoginError         model.CollectorError = "LoginError"
 	InventoryError     model.CollectorError = "InventoryError"
 	GetBiosConfigError model.CollectorError = "GetBiosConfigError"
+	// CircuitOpenError is set when the collection is skipped since the BMC circuit is open.
+	CircuitOpenError model.CollectorError = "CircuitOpenError"
+	// CredentialFallback is set when the BMC login succeeded with a fallback credential,
+	// its value identifies the credential.
+	CredentialFallback model.CollectorError = "CredentialFallback"
 )
 
+// InventoryFailedErrors are the collector errors set when the inventory was not collected.
+var InventoryFailedErrors = []model.CollectorError{LoginError, CircuitOpenError, InventoryError}
+
+// InventoryFailed returns true when the asset inventory was not collected.
+func InventoryFailed(asset *model.Asset) bool {
+	for _, kind := range InventoryFailedErrors {
+		if asset.HasError(kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CollectionSkipped returns true when the collection was skipped without querying the BMC,
+// skipped collections are not recorded as failed in the asset collection status.
+func CollectionSkipped(asset *model.Asset) bool {
+	return asset.HasError(CircuitOpenError)
+}
+
 // OutOfBand collector collects hardware, firmware inventory out of band
 type Queryor struct {
 	mockClient    BMCQueryor
 	logger        *logrus.Entry
+	retryPolicies app.OutofbandRetryOptions
+	bmcClients    []app.BMCClientOptions
 	logoutTimeout time.Duration
 }
 
@@ -62,18 +89,31 @@ type BMCQueryor interface {
 }
 
 // NewQueryor returns a instance of the Queryor inventory collector
-func NewQueryor(logger *logrus.Logger) *Queryor {
+//
+// The defaults are applied when the given configuration is nil,
+// an error is returned when a configured bmclib provider matches none of the bmclib drivers.
+func NewQueryor(cfg *app.OutofbandOptions, logger *logrus.Logger) (*Queryor, error) {
 	lt, err := time.ParseDuration(logoutTimeout)
 	if err != nil {
 		panic(err)
 	}
 
+	if cfg == nil {
+		cfg = app.DefaultOutofbandOptions()
+	}
+
+	if err := validateBMCClients(cfg.BMCClients); err != nil {
+		return nil, err
+	}
+
 	c := &Queryor{
 		logger:        logger.WithFields(logrus.Fields{"component": "collector.outofband"}),
+		retryPolicies: cfg.Retry,
+		bmcClients:    cfg.BMCClients,
 		logoutTimeout: lt,
 	}
 
-	return c
+	return c, nil
 }
 
 // Inventory retrieves device component and firmware information
@@ -85,6 +125,9 @@ func (o *Queryor) Inventory(ctx context.Context, asset *model.Asset) error {
 
 	setTraceSpanAssetAttributes(span, asset)
 
+	ctx, cancel := o.withDeadline(ctx, asset)
+	defer cancel()
+
 	o.logger.WithFields(
 		logrus.Fields{
 			"serverID": asset.ID,
@@ -120,6 +163,9 @@ func (o *Queryor) BiosConfiguration(ctx context.Context, asset *model.Asset) err
 
 	setTraceSpanAssetAttributes(span, asset)
 
+	ctx, cancel := o.withDeadline(ctx, asset)
+	defer cancel()
+
 	// login
 	bmc, err := o.bmcLogin(ctx, asset)
 	if err != nil {
@@ -143,6 +189,104 @@ func (o *Queryor) BiosConfiguration(ctx context.Context, asset *model.Asset) err
 	return o.biosConfiguration(ctx, bmc, asset)
 }
 
+// Collect retrieves the device inventory and bios configuration in a single BMC session
+// and updates the given asset object with the data collected.
+//
+// The session is checked to be active before each query following the first,
+// and is re-established if it was dropped by the BMC.
+func (o *Queryor) Collect(ctx context.Context, asset *model.Asset) error {
+	// attach child span
+	ctx, span := otel.Tracer(pkgName).Start(ctx, "Collect")
+	defer span.End()
+
+	setTraceSpanAssetAttributes(span, asset)
+
+	ctx, cancel := o.withDeadline(ctx, asset)
+	defer cancel()
+
+	// login
+	bmc, err := o.bmcLogin(ctx, asset)
+	if err != nil {
+		o.logger.WithFields(
+			logrus.Fields{
+				"serverID": asset.ID,
+				"IP":       asset.BMCAddress.String(),
+				"err":      err,
+			}).Warn("BMC login error")
+
+		return err
+	}
+
+	// defer logout
+	//
+	// ctx is not passed to bmcLogout to ensure that
+	// the bmc logout is carried out even if the context is canceled,
+	// the closure logs out of the session that is current when the queries complete.
+	defer func() {
+		if bmc != nil {
+			o.bmcLogout(bmc, asset)
+		}
+	}()
+
+	queries := []func(context.Context, BMCQueryor, *model.Asset) error{
+		o.bmcInventory,
+		o.biosConfiguration,
+	}
+
+	var errs error
+
+	for idx, query := range queries {
+		if idx > 0 {
+			bmc, err = o.ensureSession(ctx, bmc, asset)
+			if err != nil {
+				return multierror.Append(errs, err)
+			}
+		}
+
+		if err := query(ctx, bmc, asset); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// withDeadline returns a context with the deadline configured for the asset BMC, if any.
+func (o *Queryor) withDeadline(ctx context.Context, asset *model.Asset) (context.Context, context.CancelFunc) {
+	deadline := bmcClientOptions(o.bmcClients, asset).Deadline
+	if deadline <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, deadline)
+}
+
+// ensureSession returns the given BMC client if its session is active,
+// if not the session is closed and a new session is initiated.
+//
+// A nil client is returned when the new session could not be initiated.
+func (o *Queryor) ensureSession(ctx context.Context, bmc BMCQueryor, asset *model.Asset) (BMCQueryor, error) {
+	if o.SessionActive(ctx, bmc) {
+		return bmc, nil
+	}
+
+	o.logger.WithFields(
+		logrus.Fields{
+			"serverID": asset.ID,
+			"IP":       asset.BMCAddress.String(),
+		}).Info("BMC session dropped, logging in again")
+
+	// close the dropped session on the client, errors are expected here and are just logged.
+	o.bmcLogout(bmc, asset)
+
+	relogin, err := o.bmcLogin(ctx, asset)
+	if err != nil {
+		return nil, errors.Wrap(ErrBMCSession, err.Error())
+	}
+
+	return relogin, nil
+}
+
 // biosConfiguration collects bios configuration data from the BMC
 // it updates the asset.BiosConfig attribute with the data collected.
 //
@@ -151,31 +295,29 @@ func (o *Queryor) biosConfiguration(ctx context.Context, bmc BMCQueryor, asset *
 	// measure BMC biosConfiguration query
 	startTS := time.Now()
 
-	biosConfig, err := bmc.GetBiosConfiguration(ctx)
+	var biosConfig map[string]string
+
+	err := o.retry(ctx, o.retryPolicies.BiosConfig, asset, "GetBiosConfiguration", func() (err error) {
+		biosConfig, err = bmc.GetBiosConfiguration(ctx)
+		return err
+	})
 	if err != nil {
+		class, reason := classifyError(err)
+
 		o.logger.WithFields(
 			logrus.Fields{
 				"serverID": asset.ID,
 				"IP":       asset.BMCAddress.String(),
+				"class":    class,
+				"reason":   reason,
 				"err":      err,
 			}).Warn("error in bmc bios configuration collection")
 
 		trace.SpanFromContext(ctx).SetStatus(codes.Error, " BMC GetBiosConfiguration(): "+err.Error())
 
 		// increment get bios configuration query error count metric
-		switch {
-		case strings.Contains(err.Error(), "no compatible System Odata IDs identified"):
-			// device provides a redfish API, but BIOS configuration export isn't supported in the current redfish library
-			asset.AppendError(GetBiosConfigError, "redfish_incompatible: no compatible System Odata IDs identified")
-			metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "redfish_incompatible")
-		case strings.Contains(err.Error(), "no BiosConfigurationGetter implementations found"):
-			// no means to export BIOS configuration were found
-			asset.AppendError(GetBiosConfigError, "device not supported")
-			metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "NoBiosConfigurationGetter")
-		default:
-			asset.AppendError(GetBiosConfigError, err.Error())
-			metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "GetBiosConfigurationError")
-		}
+		asset.AppendError(GetBiosConfigError, classifiedErrorValue(class, reason, err))
+		metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, reason, string(class))
 
 		return errors.Wrap(ErrBiosConfig, err.Error())
 	}
@@ -196,26 +338,29 @@ func (o *Queryor) bmcInventory(ctx context.Context, bmc BMCQueryor, asset *model
 	// measure BMC inventory query
 	startTS := time.Now()
 
-	inventory, err := bmc.Inventory(ctx)
+	var inventory *common.Device
+
+	err := o.retry(ctx, o.retryPolicies.Inventory, asset, "Inventory", func() (err error) {
+		inventory, err = bmc.Inventory(ctx)
+		return err
+	})
 	if err != nil {
+		class, reason := classifyError(err)
+
 		o.logger.WithFields(
 			logrus.Fields{
 				"serverID": asset.ID,
 				"IP":       asset.BMCAddress.String(),
+				"class":    class,
+				"reason":   reason,
 				"err":      err,
 			}).Warn("error in bmc inventory collection")
 
 		trace.SpanFromContext(ctx).SetStatus(codes.Error, " BMC Inventory(): "+err.Error())
 
 		// increment inventory query error count metric
-		if strings.Contains(err.Error(), "no compatible System Odata IDs identified") {
-			// device provides a redfish API, but inventory export isn't supported in the current redfish library
-			asset.AppendError(InventoryError, "redfish_incompatible: no compatible System Odata IDs identified")
-			metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "redfish_incompatible")
-		} else {
-			asset.AppendError(InventoryError, err.Error())
-			metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "inventory")
-		}
+		asset.AppendError(InventoryError, classifiedErrorValue(class, reason, err))
+		metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, reason, string(class))
 
 		return errors.Wrap(ErrInventory, err.Error())
 	}
@@ -247,16 +392,68 @@ func (o *Queryor) bmcInventory(ctx context.Context, bmc BMCQueryor, asset *model
 //
 // when theres an error in the login process, asset.Errors is updated to include that information.
 func (o *Queryor) bmcLogin(ctx context.Context, asset *model.Asset) (BMCQueryor, error) {
-	// bmc is the bmc client instance
-	var bmc BMCQueryor
-
 	// attach child span
 	ctx, span := otel.Tracer(pkgName).Start(ctx, "bmcLogin")
 	defer span.End()
 
+	candidates := asset.BMCCredentialCandidates()
+	if len(candidates) == 0 {
+		// the login is attempted and fails with the empty credential.
+		candidates = append(candidates, model.BMCCredential{Source: model.BMCCredentialSourceStore})
+	}
+
+	var firstErr error
+
+	// the candidate credentials are tried in order while the BMC rejects the credential as unauthorized.
+	for idx, credential := range candidates {
+		bmc, err := o.bmcLoginWithCredential(ctx, asset, credential)
+		if err == nil {
+			if idx > 0 {
+				o.credentialFallback(asset, credential, firstErr)
+			}
+
+			asset.BMCCredentialSource = credential.Source
+
+			return bmc, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		class, reason := classifyError(err)
+		if reason != reasonUnauthorized || idx == len(candidates)-1 {
+			span.SetStatus(codes.Error, " BMC login: "+err.Error())
+
+			asset.AppendError(LoginError, classifiedErrorValue(class, reason, err))
+			metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, reason, string(class))
+
+			return nil, errors.Wrap(ErrConnect, err.Error())
+		}
+
+		o.logger.WithFields(
+			logrus.Fields{
+				"serverID": asset.ID,
+				"IP":       asset.BMCAddress.String(),
+				"source":   credential.Source,
+				"next":     candidates[idx+1].Source,
+			}).Info("BMC login unauthorized, trying the next candidate credential")
+	}
+
+	// not reached, the last candidate returns.
+	return nil, errors.Wrap(ErrConnect, "no BMC credentials")
+}
+
+// bmcLoginWithCredential initiates the BMC session with the given credential.
+func (o *Queryor) bmcLoginWithCredential(ctx context.Context, asset *model.Asset, credential model.BMCCredential) (BMCQueryor, error) {
+	// bmc is the bmc client instance
+	var bmc BMCQueryor
+
 	if o.mockClient == nil {
 		bmc = newBMCClient(
 			asset,
+			credential,
+			bmcClientOptions(o.bmcClients, asset),
 			o.logger.Logger,
 		)
 	} else {
@@ -268,22 +465,11 @@ func (o *Queryor) bmcLogin(ctx context.Context, asset *model.Asset) (BMCQueryor,
 	startTS := time.Now()
 
 	// initiate bmc login session
-	if err := bmc.Open(ctx); err != nil {
-		span.SetStatus(codes.Error, " BMC login: "+err.Error())
-
-		switch {
-		case strings.Contains(err.Error(), "operation timed out"):
-			asset.AppendError(LoginError, "operation timed out in "+time.Since(startTS).String())
-			metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "conn_timeout")
-		case strings.Contains(err.Error(), "401: "), strings.Contains(err.Error(), "failed to login"):
-			asset.AppendError(LoginError, "unauthorized")
-			metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "unauthorized")
-		default:
-			asset.AppendError(LoginError, err.Error())
-			metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "other")
-		}
-
-		return nil, errors.Wrap(ErrConnect, err.Error())
+	err := o.retry(ctx, o.retryPolicies.Login, asset, "Open", func() error {
+		return bmc.Open(ctx)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// measure BMC connection open query time
@@ -292,6 +478,21 @@ func (o *Queryor) bmcLogin(ctx context.Context, asset *model.Asset) (BMCQueryor,
 	return bmc, nil
 }
 
+// credentialFallback reports the BMC login succeeded with a fallback credential,
+// the stale credential in the inventory store can then be fixed.
+func (o *Queryor) credentialFallback(asset *model.Asset, credential model.BMCCredential, loginErr error) {
+	o.logger.WithFields(
+		logrus.Fields{
+			"serverID": asset.ID,
+			"IP":       asset.BMCAddress.String(),
+			"source":   credential.Source,
+			"err":      loginErr,
+		}).Warn("BMC login succeeded with a fallback credential, the inventory store credential is to be updated")
+
+	asset.AppendError(CredentialFallback, "BMC login succeeded with credential: "+credential.Source+", login error: "+loginErr.Error())
+	metrics.IncrementBMCCredentialFallbackCount(asset.Vendor, asset.Model, credential.Source)
+}
+
 func (o *Queryor) bmcLogout(bmc BMCQueryor, asset *model.Asset) {
 	// measure BMC connection close
 	startTS := time.Now()
@@ -319,18 +520,22 @@ func (o *Queryor) bmcLogout(bmc BMCQueryor, asset *model.Asset) {
 		span.SetStatus(codes.Error, " BMC connection close: "+err.Error())
 
 		// increment connection close error count metric
-		metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "conn_close")
+		class, _ := classifyError(err)
+		metrics.IncrementBMCQueryErrorCount(asset.Vendor, asset.Model, "conn_close", string(class))
 	}
 
 	// measure BMC connection open query time
 	metrics.ObserveBMCQueryTimeSummary(asset.Vendor, asset.Model, "conn_close", startTS)
 }
 
-// newBMCClient initializes a bmclib client with the given credentials
-func newBMCClient(asset *model.Asset, l *logrus.Logger) *bmclib.Client {
+// newBMCClient initializes a bmclib client with the given credential, providers and timeouts
+func newBMCClient(asset *model.Asset, credential model.BMCCredential, opts app.BMCClientOptions, l *logrus.Logger) *bmclib.Client {
 	logger := logrus.New()
 	logger.Formatter = l.Formatter
 
+	// bmclib trace logs include request, response dumps which could include the BMC credentials and session tokens.
+	logger.AddHook(helpers.NewRedactHook(credential.Password).WithBasicAuth(credential.Username, credential.Password))
+
 	// setup a logr logger for bmclib
 	// bmclib uses logr, for which the trace logs are logged with log.V(3),
 	// this is a hax so the logrusr lib will enable trace logging
@@ -345,39 +550,51 @@ func newBMCClient(asset *model.Asset, l *logrus.Logger) *bmclib.Client {
 
 	logruslogr := logrusr.New(logger)
 
-	bmcClient := bmclib.NewClient(
-		asset.BMCAddress.String(),
-		asset.BMCUsername,
-		asset.BMCPassword,
+	clientOpts := []bmclib.Option{
 		bmclib.WithLogger(logruslogr),
-		bmclib.WithPerProviderTimeout(bmclibProviderTimeout),
-	)
+		bmclib.WithPerProviderTimeout(opts.ProviderTimeout),
+	}
 
-	// set bmclib driver
-	//
-	// The bmclib drivers here are limited to the HTTPS means of connection,
-	// that is, drivers like ipmi are excluded.
-	switch asset.Vendor {
-	case common.VendorDell, common.VendorHPE:
-		// Set to the bmclib ProviderProtocol value
-		// https://github.com/metal-toolbox/bmclib/blob/providers/redfish/redfish.go#L26
-		bmcClient.Registry.Drivers = bmcClient.Registry.Using("redfish")
-	case common.VendorAsrockrack:
-		// https://github.com/metal-toolbox/bmclib/blob/providers/asrockrack/asrockrack.go#L20
-		bmcClient.Registry.Drivers = bmcClient.Registry.Using("vendorapi")
-	default:
-		// attempt both drivers when vendor is unknown
-		drivers := append(registrar.Drivers{},
-			bmcClient.Registry.Using("redfish")...,
-		)
+	// The BMC port applies to the providers that support a port option,
+	// the dell, supermicro and openbmc providers connect on the default HTTPS port.
+	if asset.BMCAddress != nil && asset.BMCAddress.Port != 0 {
+		port := strconv.Itoa(asset.BMCAddress.Port)
 
-		drivers = append(drivers,
-			bmcClient.Registry.Using("vendorapi")...,
+		clientOpts = append(
+			clientOpts,
+			bmclib.WithRedfishPort(port),
+			bmclib.WithAsrockrackPort(port),
+			bmclib.WithIpmitoolPort(port),
 		)
+	}
 
-		bmcClient.Registry.Drivers = drivers
+	bmcClient := bmclib.NewClient(
+		asset.BMCAddress.ClientHost(),
+		credential.Username,
+		credential.Password,
+		clientOpts...,
+	)
+
+	// set bmclib drivers in the configured order
+	//
+	// The default drivers are limited to the HTTPS means of connection,
+	// that is, drivers like ipmi are excluded unless configured.
+	drivers := selectDrivers(bmcClient.Registry, opts.Providers)
+	if len(drivers) == 0 {
+		// configured providers are validated by NewQueryor, the default providers for the asset apply otherwise.
+		defaults := bmcClientOptions(nil, asset).Providers
+
+		l.WithFields(logrus.Fields{
+			"serverID":  asset.ID,
+			"providers": opts.Providers,
+			"defaults":  defaults,
+		}).Warn("no bmclib providers match the configured providers, the default providers are attempted")
+
+		drivers = selectDrivers(bmcClient.Registry, defaults)
 	}
 
+	bmcClient.Registry.Drivers = drivers
+
 	return bmcClient
 }
 
@@ -410,7 +627,13 @@ func (o *Queryor) SessionActive(ctx context.Context, bmc BMCQueryor) bool {
 // setTraceSpanAssetAttributes includes the asset attributes as span attributes
 func setTraceSpanAssetAttributes(span trace.Span, asset *model.Asset) {
 	// set span attributes
-	span.SetAttributes(attribute.String("bmc.host", asset.BMCAddress.String()))
+	if asset.BMCAddress != nil {
+		span.SetAttributes(attribute.String("bmc.host", asset.BMCAddress.Host))
+
+		if asset.BMCAddress.Port != 0 {
+			span.SetAttributes(attribute.Int("bmc.port", asset.BMCAddress.Port))
+		}
+	}
 
 	if asset.Vendor == "" {
 		asset.Vendor = "unknown"
@@ -2,8 +2,10 @@ package outofband
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/metal-toolbox/alloy/internal/app"
 	"github.com/metal-toolbox/alloy/internal/model"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -52,3 +54,127 @@ func Test_BiosConfiguration(t *testing.T) {
 	assert.True(t, bmcQueryor.connOpened)
 	assert.True(t, bmcQueryor.connClosed)
 }
+
+func Test_Collect(t *testing.T) {
+	logger := logrus.New()
+	bmcQueryor := NewMockBmclibClient()
+	queryor := &Queryor{
+		mockClient: bmcQueryor,
+		logger:     logrus.NewEntry(logger),
+	}
+
+	asset := &model.Asset{}
+
+	err := queryor.Collect(context.TODO(), asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotNil(t, asset.Inventory)
+	assert.Equal(t, 1, len(asset.BiosConfig))
+
+	// a single session is used for all queries
+	assert.Equal(t, 1, bmcQueryor.opens)
+	assert.Equal(t, 1, bmcQueryor.closes)
+}
+
+func Test_EnsureSession(t *testing.T) {
+	logger := logrus.New()
+	bmcQueryor := NewMockBmclibClient()
+	queryor := &Queryor{
+		mockClient: bmcQueryor,
+		logger:     logrus.NewEntry(logger),
+	}
+
+	asset := &model.Asset{}
+
+	// active session is retained
+	bmc, err := queryor.ensureSession(context.TODO(), bmcQueryor, asset)
+	assert.Nil(t, err)
+	assert.Equal(t, bmcQueryor, bmc)
+	assert.Equal(t, 0, bmcQueryor.opens)
+
+	// dropped session is closed and re-established
+	bmcQueryor.sessionDropped = true
+
+	bmc, err = queryor.ensureSession(context.TODO(), bmcQueryor, asset)
+	assert.Nil(t, err)
+	assert.NotNil(t, bmc)
+	assert.Equal(t, 1, bmcQueryor.opens)
+	assert.Equal(t, 1, bmcQueryor.closes)
+	assert.False(t, bmcQueryor.sessionDropped)
+}
+
+func Test_LoginCredentialFallback(t *testing.T) {
+	cases := []struct {
+		name       string
+		openErrs   []error
+		wantErr    bool
+		wantOpens  int
+		wantSource string
+	}{
+		{
+			"store credential",
+			nil,
+			false,
+			1,
+			model.BMCCredentialSourceStore,
+		},
+		{
+			"fallback credential on unauthorized",
+			[]error{errors.New("401: Unauthorized"), errors.New("401: Unauthorized")},
+			false,
+			3,
+			"default:supermicro",
+		},
+		{
+			"no fallback on connection errors",
+			[]error{errors.New("connection refused")},
+			true,
+			1,
+			"",
+		},
+		{
+			"all candidates unauthorized",
+			[]error{errors.New("401: Unauthorized"), errors.New("401: Unauthorized"), errors.New("401: Unauthorized")},
+			true,
+			3,
+			"",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bmcQueryor := NewMockBmclibClient()
+			bmcQueryor.openErrs = tc.openErrs
+
+			queryor := &Queryor{
+				mockClient:    bmcQueryor,
+				logger:        logrus.NewEntry(logrus.New()),
+				retryPolicies: app.OutofbandRetryOptions{Login: app.RetryPolicy{MaxAttempts: 1}},
+			}
+
+			asset := &model.Asset{
+				BMCUsername: "root",
+				BMCPassword: "stale",
+				BMCCredentials: []model.BMCCredential{
+					{Source: "default:dell", Username: "root", Password: "calvin"},
+					{Source: "default:supermicro", Username: "ADMIN", Password: "ADMIN"},
+				},
+			}
+
+			_, err := queryor.bmcLogin(context.TODO(), asset)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrConnect)
+				assert.True(t, asset.HasError(LoginError))
+			} else {
+				assert.Nil(t, err)
+				assert.False(t, asset.HasError(LoginError))
+			}
+
+			assert.Equal(t, tc.wantOpens, bmcQueryor.opens)
+			assert.Equal(t, tc.wantSource, asset.BMCCredentialSource)
+			assert.Equal(t, tc.wantOpens > 1 && !tc.wantErr, asset.HasError(CredentialFallback))
+		})
+	}
+}
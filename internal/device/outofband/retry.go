@@ -0,0 +1,77 @@
+package outofband
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+// retry runs the operation until it succeeds, returns an error that is not transient,
+// the policy max attempts are exhausted, or the context is canceled.
+//
+// Connection timeouts are retried only with a deadline configured for the asset BMC,
+// since each attempt on a BMC that does not respond may take up to the provider timeout.
+//
+// The last error returned by the operation is returned.
+func (o *Queryor) retry(ctx context.Context, policy app.RetryPolicy, asset *model.Asset, operation string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		class, reason := classifyError(err)
+		if class != ErrorClassTransient || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		if reason == reasonConnTimeout && bmcClientOptions(o.bmcClients, asset).Deadline <= 0 {
+			return err
+		}
+
+		wait := backoff(policy, attempt)
+
+		o.logger.WithFields(
+			logrus.Fields{
+				"serverID":  asset.ID,
+				"IP":        asset.BMCAddress.String(),
+				"operation": operation,
+				"attempt":   attempt,
+				"reason":    reason,
+				"wait":      wait.String(),
+				"err":       err,
+			}).Info("transient BMC error, retrying")
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+
+			return err
+		}
+	}
+}
+
+// backoff returns the interval to wait before the given attempt is retried.
+func backoff(policy app.RetryPolicy, attempt int) time.Duration {
+	interval := float64(policy.InitialInterval) * math.Pow(policy.Multiplier, float64(attempt-1))
+
+	if policy.MaxInterval > 0 && interval > float64(policy.MaxInterval) {
+		interval = float64(policy.MaxInterval)
+	}
+
+	if policy.Jitter > 0 {
+		// nolint:gosec // jitter does not require a cryptographically secure random value.
+		interval += interval * policy.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(interval)
+}
@@ -0,0 +1,146 @@
+package helpers
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+var (
+	ErrAssetIDsFile = errors.New("error in asset IDs file")
+)
+
+const (
+	// StdinFilename is the asset IDs filename to read the asset IDs from stdin.
+	StdinFilename = "-"
+
+	assetIDsColumnID     = "id"
+	assetIDsColumnVendor = "vendor"
+)
+
+// ReadAssetIDsFile returns the assets listed in the given file, or stdin when the filename is StdinFilename.
+//
+// See ReadAssetIDs for the accepted file format.
+func ReadAssetIDsFile(filename string, stdin io.Reader) ([]*model.Asset, error) {
+	if filename == StdinFilename {
+		return ReadAssetIDs(stdin)
+	}
+
+	fh, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(ErrAssetIDsFile, err.Error())
+	}
+
+	defer fh.Close()
+
+	return ReadAssetIDs(fh)
+}
+
+// ReadAssetIDs returns the assets listed in the reader, with the asset ID and the optional vendor hint set.
+//
+// The input is one asset ID per line, or CSV records with the asset ID in the first column
+// and an optional vendor hint in the second column,
+//
+//	fc167440-18d3-4455-b5ee-1c8e347b3f36
+//	f0c8e4ac-5cce-4370-93ff-bd9b7d07c3d0,supermicro
+//
+// CSV input with a header row is read from the id, vendor columns, other columns are ignored.
+// Empty lines and lines starting with # are skipped, assets listed more than once are returned once.
+func ReadAssetIDs(r io.Reader) ([]*model.Asset, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	// column indexes, set from the header row when present.
+	idCol, vendorCol := 0, 1
+
+	var header bool
+
+	var assets []*model.Asset
+
+	seen := map[string]bool{}
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return assets, nil
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(ErrAssetIDsFile, err.Error())
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		if first && isAssetIDsHeader(record) {
+			idCol, vendorCol = assetIDsColumns(record)
+			header = true
+
+			continue
+		}
+
+		// without a header, the records are expected to be the asset ID with an optional vendor hint.
+		if !header && len(record) > 2 { // nolint:gomnd // column count is clear as is
+			return nil, errors.Wrap(ErrAssetIDsFile, "line "+strconv.Itoa(line)+": expected an asset ID and an optional vendor")
+		}
+
+		asset := &model.Asset{ID: field(record, idCol)}
+		if asset.ID == "" {
+			return nil, errors.Wrap(ErrAssetIDsFile, "line "+strconv.Itoa(line)+": asset ID expected")
+		}
+
+		if seen[asset.ID] {
+			continue
+		}
+
+		seen[asset.ID] = true
+
+		if vendorCol >= 0 {
+			asset.Vendor = strings.ToLower(field(record, vendorCol))
+		}
+
+		assets = append(assets, asset)
+	}
+}
+
+// isAssetIDsHeader returns true when the record is a header row with an id column.
+func isAssetIDsHeader(record []string) bool {
+	for _, col := range record {
+		if strings.EqualFold(strings.TrimSpace(col), assetIDsColumnID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// assetIDsColumns returns the id, vendor column indexes from the header row, the vendor index is -1 when not present.
+func assetIDsColumns(header []string) (idCol, vendorCol int) {
+	vendorCol = -1
+
+	for idx, col := range header {
+		switch strings.ToLower(strings.TrimSpace(col)) {
+		case assetIDsColumnID:
+			idCol = idx
+		case assetIDsColumnVendor:
+			vendorCol = idx
+		}
+	}
+
+	return idCol, vendorCol
+}
+
+func field(record []string, idx int) string {
+	if idx >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[idx])
+}
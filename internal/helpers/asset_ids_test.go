@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_ReadAssetIDs(t *testing.T) {
+	// nolint:govet // struct field ordering is fine as is for tests
+	tests := []struct {
+		name    string
+		input   string
+		want    []*model.Asset
+		wantErr bool
+	}{
+		{
+			"newline separated",
+			"a\n\n# comment\nb\na\n",
+			[]*model.Asset{{ID: "a"}, {ID: "b"}},
+			false,
+		},
+		{
+			"vendor hint",
+			"a,Dell\nb\nc, supermicro\n",
+			[]*model.Asset{{ID: "a", Vendor: "dell"}, {ID: "b"}, {ID: "c", Vendor: "supermicro"}},
+			false,
+		},
+		{
+			"csv with header",
+			"hostname,vendor,id\nfoo,dell,a\nbar,,b\n",
+			[]*model.Asset{{ID: "a", Vendor: "dell"}, {ID: "b"}},
+			false,
+		},
+		{
+			"extra columns without header",
+			"a,dell,foo\n",
+			nil,
+			true,
+		},
+		{
+			"empty asset ID",
+			"a\n,dell\n",
+			nil,
+			true,
+		},
+		{
+			"empty",
+			"",
+			nil,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadAssetIDs(strings.NewReader(tt.input))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrAssetIDsFile)
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_ReadAssetIDsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "assets.txt")
+	require.Nil(t, os.WriteFile(filename, []byte("a\nb\n"), 0o600))
+
+	got, err := ReadAssetIDsFile(filename, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []*model.Asset{{ID: "a"}, {ID: "b"}}, got)
+
+	got, err = ReadAssetIDsFile(StdinFilename, strings.NewReader("c\n"))
+	require.Nil(t, err)
+	assert.Equal(t, []*model.Asset{{ID: "c"}}, got)
+
+	_, err = ReadAssetIDsFile(filepath.Join(t.TempDir(), "missing.txt"), nil)
+	assert.ErrorIs(t, err, ErrAssetIDsFile)
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/metal-toolbox/alloy/internal/model"
@@ -58,3 +59,64 @@ func WriteDebugFile(name, dump string) {
 
 	_, _ = f.WriteString(dump)
 }
+
+// WriteFileAtomic writes the data to a temporary file in the same directory
+// and renames it to the given file name, so readers never observe a partially written file.
+func WriteFileAtomic(name string, data []byte) error {
+	tmp, err := writeTemp(name, data)
+	if err != nil {
+		return err
+	}
+
+	// cleanup the temp file if the rename did not go through
+	defer os.Remove(tmp)
+
+	return os.Rename(tmp, name)
+}
+
+// WriteFileExclusive writes the data to a temporary file in the same directory
+// and links it to the given file name, so readers never observe a partially written file.
+//
+// Like a file opened with O_EXCL, an existing file is not replaced and an error satisfying
+// errors.Is(err, os.ErrExist) is returned.
+func WriteFileExclusive(name string, data []byte) error {
+	tmp, err := writeTemp(name, data)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp)
+
+	return os.Link(tmp, name)
+}
+
+// writeTemp writes the data to a temporary file in the directory of the given file name,
+// and returns the temporary file name.
+func writeTemp(name string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return "", err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
@@ -0,0 +1,162 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+// secretFieldNames are substrings of log field names, whose values are redacted.
+//
+// The field names are compared in lower case with the '_', '-' separators removed.
+var secretFieldNames = []string{"password", "passwd", "secret", "token", "credential"}
+
+// secretHeaders matches the values of the HTTP headers that hold credentials or session tokens,
+// in request, response dumps and in headers marshalled to JSON - the values are redacted.
+var secretHeaders = regexp.MustCompile(`(?i)("?(?:authorization|x-auth-token)"?\s*:\s*)(\["[^"]*"\]|"[^"]*"|\[[^\]]*\]|[^\r\n",}]+)`)
+
+// RedactHook is a logrus hook that redacts secrets from log entries.
+//
+// The values of fields named like a secret are redacted, the BMC credentials of model.Asset field values are redacted,
+// and any of the secrets the hook was initialized with, along with the values of the Authorization, X-Auth-Token headers
+// are redacted from the log message and string, error field values.
+type RedactHook struct {
+	secrets []string
+}
+
+// NewRedactHook returns a RedactHook that redacts the given secret values in addition to the secret fields,
+// the secrets are redacted as given, JSON escaped and URL query escaped.
+func NewRedactHook(secrets ...string) *RedactHook {
+	h := &RedactHook{}
+
+	for _, secret := range secrets {
+		h.add(secretForms(secret)...)
+	}
+
+	return h
+}
+
+// WithBasicAuth adds the HTTP basic authentication credentials for the username, password to the redacted secrets,
+// for the base64 encoded credentials to be redacted where they are logged outside of an Authorization header.
+func (h *RedactHook) WithBasicAuth(username, password string) *RedactHook {
+	if password != "" {
+		h.add(base64.StdEncoding.EncodeToString([]byte(username + ":" + password)))
+	}
+
+	return h
+}
+
+// add includes the secrets in the redacted secrets,
+// longer secrets are redacted first for a secret that includes another secret to be redacted completely.
+func (h *RedactHook) add(secrets ...string) {
+	for _, secret := range secrets {
+		if secret != "" && !slices.Contains(h.secrets, secret) {
+			h.secrets = append(h.secrets, secret)
+		}
+	}
+
+	sort.SliceStable(h.secrets, func(i, j int) bool {
+		return len(h.secrets[i]) > len(h.secrets[j])
+	})
+}
+
+// secretForms returns the secret as given, JSON escaped and URL query escaped, as it may be included in requests.
+func secretForms(secret string) []string {
+	if secret == "" {
+		return nil
+	}
+
+	forms := []string{secret, url.QueryEscape(secret)}
+
+	// the JSON escaped secret with, and without HTML characters escaped.
+	for _, escapeHTML := range []bool{true, false} {
+		buf := &bytes.Buffer{}
+
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(escapeHTML)
+
+		if err := enc.Encode(secret); err == nil {
+			forms = append(forms, strings.Trim(strings.TrimSpace(buf.String()), `"`))
+		}
+	}
+
+	return forms
+}
+
+// Levels implements the logrus.Hook interface.
+func (h *RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements the logrus.Hook interface.
+//
+// The entry passed is a copy of the logged entry, and so its data can be modified in place.
+func (h *RedactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.redactString(entry.Message)
+
+	for key, value := range entry.Data {
+		if isSecretField(key) {
+			entry.Data[key] = model.RedactedValue
+			continue
+		}
+
+		switch v := value.(type) {
+		case *model.Asset:
+			if v != nil {
+				entry.Data[key] = v.Redacted()
+			}
+		case model.Asset:
+			entry.Data[key] = v.Redacted()
+		case string:
+			entry.Data[key] = h.redactString(v)
+		case error:
+			if redacted := h.redactString(v.Error()); redacted != v.Error() {
+				entry.Data[key] = errors.New(redacted)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (h *RedactHook) redactString(s string) string {
+	for _, secret := range h.secrets {
+		s = strings.ReplaceAll(s, secret, model.RedactedValue)
+	}
+
+	return secretHeaders.ReplaceAllStringFunc(s, func(header string) string {
+		match := secretHeaders.FindStringSubmatch(header)
+
+		// the value quotes, brackets are retained.
+		switch value := match[2]; {
+		case strings.HasPrefix(value, `["`):
+			return match[1] + `["` + model.RedactedValue + `"]`
+		case strings.HasPrefix(value, `"`):
+			return match[1] + `"` + model.RedactedValue + `"`
+		default:
+			return match[1] + model.RedactedValue
+		}
+	})
+}
+
+func isSecretField(name string) bool {
+	name = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+
+	for _, secret := range secretFieldNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+
+	return false
+}
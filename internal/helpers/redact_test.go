@@ -0,0 +1,169 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func TestRedactHook(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	logger := logrus.New()
+	logger.Out = buf
+	logger.Formatter = &logrus.JSONFormatter{}
+	logger.AddHook(NewRedactHook("hunter2"))
+
+	asset := &model.Asset{ID: "foo", BMCUsername: "root", BMCPassword: "calvin"}
+
+	logger.WithFields(logrus.Fields{
+		"asset":            asset,
+		"bmc_password":     "calvin",
+		"OidcClientSecret": "s3cr3t",
+		"request":          "POST /login {\"password\": \"hunter2\"}",
+		"err":              errors.New("login failed for hunter2"),
+		"vendor":           "dell",
+	}).Info("login with hunter2")
+
+	out := buf.String()
+	for _, secret := range []string{"calvin", "root", "s3cr3t", "hunter2"} {
+		assert.NotContains(t, out, secret)
+	}
+
+	entry := map[string]any{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "dell", entry["vendor"])
+	assert.Equal(t, "login with "+model.RedactedValue, entry["msg"])
+
+	// the logged asset is not modified
+	assert.Equal(t, "calvin", asset.BMCPassword)
+}
+
+func TestRedactHook_RequestDump(t *testing.T) {
+	username, password := "root", `c<a"l&v\in`
+
+	body, err := json.Marshal(map[string]string{"UserName": username, "Password": password})
+	require.Nil(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "https://127.0.0.1/redfish/v1/SessionService/Sessions", bytes.NewReader(body))
+	require.Nil(t, err)
+
+	req.SetBasicAuth(username, password)
+	req.Header.Set("X-Auth-Token", "1a2b3c4d")
+
+	requestDump, err := httputil.DumpRequestOut(req, true)
+	require.Nil(t, err)
+
+	form, err := http.NewRequest(
+		http.MethodPost,
+		"https://127.0.0.1/api/session",
+		strings.NewReader(url.Values{"username": {username}, "password": {password}}.Encode()),
+	)
+	require.Nil(t, err)
+
+	formDump, err := httputil.DumpRequestOut(form, true)
+	require.Nil(t, err)
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set("X-Auth-Token", "5e6f7a8b")
+	rec.Header().Set("Location", "/redfish/v1/SessionService/Sessions/1")
+	rec.WriteHeader(http.StatusCreated)
+
+	responseDump, err := httputil.DumpResponse(rec.Result(), true)
+	require.Nil(t, err)
+
+	headers, err := json.Marshal(req.Header)
+	require.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+
+	logger := logrus.New()
+	logger.Out = buf
+	logger.Level = logrus.TraceLevel
+	logger.Formatter = &logrus.JSONFormatter{}
+	logger.AddHook(NewRedactHook(password).WithBasicAuth(username, password))
+
+	logger.WithFields(logrus.Fields{
+		"requestDump":  string(requestDump),
+		"formDump":     string(formDump),
+		"responseDump": string(responseDump),
+		"headers":      string(headers),
+		"goHeaders":    fmt.Sprintf("%v", req.Header),
+	}).Trace(string(requestDump))
+
+	entry := map[string]any{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	escaped, err := json.Marshal(password)
+	require.Nil(t, err)
+
+	// the password is JSON escaped in the request body.
+	require.Contains(t, string(body), strings.Trim(string(escaped), `"`))
+
+	basicAuth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+
+	for key, value := range entry {
+		for _, secret := range []string{password, strings.Trim(string(escaped), `"`), url.QueryEscape(password), basicAuth, "1a2b3c4d", "5e6f7a8b"} {
+			assert.NotContains(t, value, secret, key)
+		}
+	}
+
+	// the dumps are otherwise retained.
+	assert.Contains(t, entry["requestDump"], "Authorization: "+model.RedactedValue+"\r\n")
+	assert.Contains(t, entry["requestDump"], "X-Auth-Token: "+model.RedactedValue+"\r\n")
+	assert.Contains(t, entry["requestDump"], `"UserName":"root"`)
+	assert.Contains(t, entry["responseDump"], "Location: /redfish/v1/SessionService/Sessions/1")
+	assert.Contains(t, entry["headers"], `"X-Auth-Token":["`+model.RedactedValue+`"]`)
+	assert.Contains(t, entry["goHeaders"], "X-Auth-Token:"+model.RedactedValue)
+}
+
+func TestRedactHook_Headers(t *testing.T) {
+	testcases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"dump", "Authorization: Basic cm9vdDpjYWx2aW4=\r\nAccept: */*", "Authorization: [REDACTED]\r\nAccept: */*"},
+		{"json string", `{"X-Auth-Token":"abc","Accept":"*/*"}`, `{"X-Auth-Token":"[REDACTED]","Accept":"*/*"}`},
+		{"json list", `{"x-auth-token": ["abc"]}`, `{"x-auth-token": ["[REDACTED]"]}`},
+		{"already redacted", "Authorization: [REDACTED]", "Authorization: [REDACTED]"},
+		{"no header", "Accept: */*", "Accept: */*"},
+	}
+
+	h := NewRedactHook()
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, h.redactString(tc.input))
+		})
+	}
+}
+
+func TestAssetMarshalJSONRedacted(t *testing.T) {
+	asset := model.Asset{ID: "foo", BMCUsername: "root", BMCPassword: "calvin"}
+
+	for _, v := range []any{asset, &asset} {
+		b, err := json.Marshal(v)
+		require.Nil(t, err)
+
+		got := &model.Asset{}
+		require.Nil(t, json.Unmarshal(b, got))
+		assert.Equal(t, "foo", got.ID)
+		assert.Equal(t, model.RedactedValue, got.BMCUsername)
+		assert.Equal(t, model.RedactedValue, got.BMCPassword)
+	}
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -18,8 +19,40 @@ var (
 
 	// metricBMCQueryErrorCount counts the number of query errors - when querying information from BMCs.
 	metricBMCQueryErrorCount *prometheus.CounterVec
+
+	// metricBMCQueryErrorClassCount counts the BMC query errors by the error class.
+	metricBMCQueryErrorClassCount *prometheus.CounterVec
+
+	// metricBMCCredentialFallbackCount counts the BMC logins that succeeded with a fallback credential.
+	metricBMCCredentialFallbackCount *prometheus.CounterVec
+
+	// bmcQueryObservers are invoked with the BMC query times measured, by the observer ID.
+	bmcQueryObservers   = map[int]BMCQueryObserver{}
+	bmcQueryObserverID  int
+	bmcQueryObserversMu sync.RWMutex
 )
 
+// BMCQueryObserver is invoked with the BMC query times measured by ObserveBMCQueryTimeSummary.
+type BMCQueryObserver func(queryKind string, elapsed time.Duration)
+
+// AddBMCQueryObserver registers the observer to be invoked with the BMC query times measured,
+// the returned func removes the observer.
+func AddBMCQueryObserver(observer BMCQueryObserver) (remove func()) {
+	bmcQueryObserversMu.Lock()
+	defer bmcQueryObserversMu.Unlock()
+
+	bmcQueryObserverID++
+	id := bmcQueryObserverID
+	bmcQueryObservers[id] = observer
+
+	return func() {
+		bmcQueryObserversMu.Lock()
+		defer bmcQueryObserversMu.Unlock()
+
+		delete(bmcQueryObservers, id)
+	}
+}
+
 func init() {
 	metricBMCQueryTimeSummary = promauto.NewSummaryVec(
 		prometheus.SummaryOpts{
@@ -36,10 +69,28 @@ func init() {
 		},
 		[]string{"stage", "query_kind", "model", "vendor"},
 	)
+
+	metricBMCQueryErrorClassCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alloy_bmc_query_errors_by_class_total",
+			Help: "A counter metric to measure the total count of errors when querying the BMC, by the error class.",
+		},
+		[]string{"stage", "query_kind", "error_class", "model", "vendor"},
+	)
+
+	metricBMCCredentialFallbackCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alloy_bmc_credential_fallback_total",
+			Help: "A counter metric to measure the total count of BMC logins that succeeded with a fallback credential.",
+		},
+		[]string{"stage", "source", "model", "vendor"},
+	)
 }
 
 // collect BMC query count error if the BMC vendor, model attributes are available
-func IncrementBMCQueryErrorCount(assetVendor, assetModel, queryKind string) {
+//
+// The errorClass is one of transient, permanent.
+func IncrementBMCQueryErrorCount(assetVendor, assetModel, queryKind, errorClass string) {
 	if assetModel == "" {
 		assetModel = "unknown"
 	}
@@ -58,6 +109,38 @@ func IncrementBMCQueryErrorCount(assetVendor, assetModel, queryKind string) {
 				"model":      assetModel,
 			}),
 	).Inc()
+
+	metricBMCQueryErrorClassCount.With(
+		AddLabels(
+			StageLabelCollector,
+			prometheus.Labels{
+				"query_kind":  queryKind,
+				"error_class": errorClass,
+				"vendor":      assetVendor,
+				"model":       assetModel,
+			}),
+	).Inc()
+}
+
+// count BMC logins that succeeded with the fallback credential from the given source
+func IncrementBMCCredentialFallbackCount(assetVendor, assetModel, source string) {
+	if assetModel == "" {
+		assetModel = "unknown"
+	}
+
+	if assetVendor == "" {
+		assetVendor = "unknown"
+	}
+
+	metricBMCCredentialFallbackCount.With(
+		AddLabels(
+			StageLabelCollector,
+			prometheus.Labels{
+				"source": source,
+				"vendor": assetVendor,
+				"model":  assetModel,
+			}),
+	).Inc()
 }
 
 // collect BMC query time metrics
@@ -70,6 +153,14 @@ func ObserveBMCQueryTimeSummary(assetVendor, assetModel, queryKind string, start
 		assetVendor = "unknown"
 	}
 
+	elapsed := time.Since(startTS)
+
+	bmcQueryObserversMu.RLock()
+	for _, observer := range bmcQueryObservers {
+		observer(queryKind, elapsed)
+	}
+	bmcQueryObserversMu.RUnlock()
+
 	// measure BMC query time from the given startTS
 	metricBMCQueryTimeSummary.With(
 		AddLabels(
@@ -79,5 +170,5 @@ func ObserveBMCQueryTimeSummary(assetVendor, assetModel, queryKind string, start
 				"vendor":     assetVendor,
 				"model":      assetModel,
 			}),
-	).Observe(time.Since(startTS).Seconds())
+	).Observe(elapsed.Seconds())
 }
@@ -49,6 +49,27 @@ var (
 	// OOBCollectionActive indicates when inventory collection is active.
 	OOBCollectionActive prometheus.Gauge
 
+	// BMCCircuitsOpen measures the number of BMC circuits open, for which collection is skipped.
+	BMCCircuitsOpen prometheus.Gauge
+
+	// ShardShare measures the fraction of the assets owned by the replica, when the assets are sharded across replicas.
+	ShardShare *prometheus.GaugeVec
+
+	// ShardReplicas measures the count of live replicas the assets are sharded across.
+	ShardReplicas prometheus.Gauge
+
+	// AssetsScheduledByStaleness measures the count of assets in the last collection ordered by staleness, by the priority reason.
+	AssetsScheduledByStaleness *prometheus.GaugeVec
+
+	// CollectionsDeferred counts the collections deferred outside the maintenance windows, by stage and reason.
+	CollectionsDeferred *prometheus.CounterVec
+
+	// EffectiveConcurrency measures the collections allowed in flight, as adjusted by the adaptive concurrency, by stage.
+	EffectiveConcurrency *prometheus.GaugeVec
+
+	// MaintenanceWindowClosed indicates when collection is paused for the facility outside its maintenance windows.
+	MaintenanceWindowClosed prometheus.Gauge
+
 	NATSErrors *prometheus.CounterVec
 
 	EventsCounter *prometheus.CounterVec
@@ -138,6 +159,59 @@ func init() {
 		},
 	)
 
+	BMCCircuitsOpen = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "alloy_bmc_circuits_open",
+			Help: "A gauge metric that counts the BMC circuits open, for which collection is skipped until the cool-down period passes.",
+		},
+	)
+
+	ShardShare = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "alloy_shard_share",
+			Help: "A gauge metric that indicates the fraction (0 - 1) of the asset ID hash space owned by the replica, when sharded across replicas.",
+		},
+		[]string{"replica"},
+	)
+
+	ShardReplicas = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "alloy_shard_replicas",
+			Help: "A gauge metric that counts the live replicas the assets are sharded across.",
+		},
+	)
+
+	AssetsScheduledByStaleness = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "alloy_assets_scheduled_by_staleness",
+			Help: "A gauge metric that counts the assets in the last collection ordered by staleness, by the reason the asset was prioritized.",
+		},
+		[]string{"priority"},
+	)
+
+	CollectionsDeferred = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alloy_collections_deferred",
+			Help: "A counter metric to measure the collections deferred outside the maintenance windows, by stage and reason.",
+		},
+		[]string{"stage", "reason"},
+	)
+
+	EffectiveConcurrency = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "alloy_effective_concurrency",
+			Help: "A gauge metric that indicates the collections allowed in flight, as adjusted by the adaptive concurrency.",
+		},
+		[]string{"stage"},
+	)
+
+	MaintenanceWindowClosed = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "alloy_maintenance_window_closed",
+			Help: "A gauge metric that indicates when collection is paused for the facility outside its maintenance windows.",
+		},
+	)
+
 	NATSErrors = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "alloy_nats_errors",
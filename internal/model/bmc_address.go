@@ -0,0 +1,186 @@
+package model
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrBMCAddress = errors.New("invalid BMC address")
+)
+
+const (
+	// hostname length limits as per RFC 1035.
+	maxHostnameLength = 253
+	maxLabelLength    = 63
+)
+
+// BMCAddress is the network address of a BMC.
+//
+// The Host is an IPv4, IPv6 address or a DNS hostname,
+// the Port is optional and zero when not specified, in which case the BMC client default ports apply.
+type BMCAddress struct {
+	Host string
+	Port int
+}
+
+// ParseBMCAddress parses a BMC address in one of the forms,
+//
+//	192.168.1.10, 192.168.1.10:8443
+//	2001:db8::10, [2001:db8::10], [2001:db8::10]:8443
+//	bmc.example.com, bmc.example.com:8443
+func ParseBMCAddress(s string) (*BMCAddress, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.Wrap(ErrBMCAddress, "empty address")
+	}
+
+	// IPv4 or IPv6 address without a port
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return newIPBMCAddress(addr, 0, s)
+	}
+
+	host, port := s, 0
+
+	// a single colon separates the host and port, IPv6 addresses with a port are enclosed in brackets.
+	if strings.HasPrefix(s, "[") || strings.Count(s, ":") == 1 {
+		h, p, err := net.SplitHostPort(s)
+		if err != nil {
+			// an IPv6 address in brackets without a port
+			if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+				return nil, errors.Wrap(ErrBMCAddress, err.Error())
+			}
+
+			h, p = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"), ""
+		}
+
+		if p != "" {
+			port, err = strconv.Atoi(p)
+			if err != nil || port < 1 || port > 65535 {
+				return nil, errors.Wrap(ErrBMCAddress, "invalid port: "+s)
+			}
+		}
+
+		if strings.HasPrefix(s, "[") {
+			addr, err := netip.ParseAddr(h)
+			if err != nil || !addr.Is6() {
+				return nil, errors.Wrap(ErrBMCAddress, "invalid IPv6 address: "+s)
+			}
+
+			return newIPBMCAddress(addr, port, s)
+		}
+
+		host = h
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return newIPBMCAddress(addr, port, s)
+	}
+
+	if !validHostname(host) {
+		return nil, errors.Wrap(ErrBMCAddress, "invalid hostname: "+s)
+	}
+
+	return &BMCAddress{Host: strings.ToLower(strings.TrimSuffix(host, ".")), Port: port}, nil
+}
+
+func newIPBMCAddress(addr netip.Addr, port int, s string) (*BMCAddress, error) {
+	// zoned addresses would require the zone to be escaped in the BMC URLs.
+	if addr.Zone() != "" {
+		return nil, errors.Wrap(ErrBMCAddress, "IPv6 zones are not supported: "+s)
+	}
+
+	return &BMCAddress{Host: addr.Unmap().String(), Port: port}, nil
+}
+
+// validHostname returns true if the host is a valid DNS hostname.
+//
+// A host that consists of only digits and dots is not a hostname,
+// and is assumed to be an incomplete IPv4 address.
+func validHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > maxHostnameLength {
+		return false
+	}
+
+	if strings.Trim(host, "0123456789.") == "" {
+		return false
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > maxLabelLength {
+			return false
+		}
+
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// IsIP returns true when the host is an IP address.
+func (b *BMCAddress) IsIP() bool {
+	if b == nil {
+		return false
+	}
+
+	_, err := netip.ParseAddr(b.Host)
+
+	return err == nil
+}
+
+// ClientHost returns the host as included in a BMC URL, IPv6 addresses are enclosed in brackets.
+func (b *BMCAddress) ClientHost() string {
+	if b == nil {
+		return ""
+	}
+
+	if strings.Contains(b.Host, ":") {
+		return "[" + b.Host + "]"
+	}
+
+	return b.Host
+}
+
+// String returns the address in the form it is parsed from,
+// the host is returned as is when a port is not specified.
+func (b *BMCAddress) String() string {
+	if b == nil {
+		return ""
+	}
+
+	if b.Port == 0 {
+		return b.Host
+	}
+
+	return net.JoinHostPort(b.Host, strconv.Itoa(b.Port))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (b BMCAddress) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (b *BMCAddress) UnmarshalText(text []byte) error {
+	parsed, err := ParseBMCAddress(string(text))
+	if err != nil {
+		return err
+	}
+
+	*b = *parsed
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseBMCAddress(t *testing.T) {
+	cases := []struct {
+		name     string
+		address  string
+		expected *BMCAddress
+		str      string
+		wantErr  bool
+	}{
+		{"IPv4", " 192.168.1.10 ", &BMCAddress{Host: "192.168.1.10"}, "192.168.1.10", false},
+		{"IPv4 with port", "192.168.1.10:8443", &BMCAddress{Host: "192.168.1.10", Port: 8443}, "192.168.1.10:8443", false},
+		{"IPv6", "2001:DB8::10", &BMCAddress{Host: "2001:db8::10"}, "2001:db8::10", false},
+		{"IPv6 in brackets", "[2001:db8::10]", &BMCAddress{Host: "2001:db8::10"}, "2001:db8::10", false},
+		{"IPv6 with port", "[2001:db8::10]:8443", &BMCAddress{Host: "2001:db8::10", Port: 8443}, "[2001:db8::10]:8443", false},
+		{"IPv4 mapped IPv6", "::ffff:192.168.1.10", &BMCAddress{Host: "192.168.1.10"}, "192.168.1.10", false},
+		{"hostname", "BMC-01.example.com.", &BMCAddress{Host: "bmc-01.example.com"}, "bmc-01.example.com", false},
+		{"hostname with port", "bmc01:623", &BMCAddress{Host: "bmc01", Port: 623}, "bmc01:623", false},
+		{"empty", " ", nil, "", true},
+		{"incomplete IPv4", "192.168.1", nil, "", true},
+		{"invalid port", "bmc01:http", nil, "", true},
+		{"port out of range", "bmc01:65536", nil, "", true},
+		{"IPv6 with zone", "fe80::1%eth0", nil, "", true},
+		{"IPv4 in brackets", "[192.168.1.10]", nil, "", true},
+		{"invalid hostname", "bmc_01.example.com", nil, "", true},
+		{"label with leading hyphen", "-bmc.example.com", nil, "", true},
+		{"URL", "https://bmc01", nil, "", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseBMCAddress(tc.address)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrBMCAddress)
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, got)
+			assert.Equal(t, tc.str, got.String())
+		})
+	}
+}
+
+func Test_BMCAddressClientHost(t *testing.T) {
+	assert.Equal(t, "[2001:db8::10]", (&BMCAddress{Host: "2001:db8::10", Port: 443}).ClientHost())
+	assert.Equal(t, "bmc01", (&BMCAddress{Host: "bmc01"}).ClientHost())
+
+	var nilAddress *BMCAddress
+	assert.Equal(t, "", nilAddress.ClientHost())
+	assert.Equal(t, "", nilAddress.String())
+}
+
+func Test_BMCAddressJSON(t *testing.T) {
+	asset := &Asset{ID: "foo", BMCAddress: &BMCAddress{Host: "2001:db8::10", Port: 8443}}
+
+	b, err := json.Marshal(asset)
+	require.Nil(t, err)
+	assert.Contains(t, string(b), `"BMCAddress":"[2001:db8::10]:8443"`)
+
+	got := &Asset{}
+	require.Nil(t, json.Unmarshal(b, got))
+	assert.Equal(t, asset.BMCAddress, got.BMCAddress)
+}
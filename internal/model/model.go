@@ -1,8 +1,9 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
-	"net"
+	"time"
 
 	common "github.com/metal-toolbox/bmc-common"
 	rctypes "github.com/metal-toolbox/rivets/v2/condition"
@@ -35,6 +36,8 @@ const (
 	StoreKindCsv     StoreKind = "csv"
 	StoreKindFleetDB StoreKind = "fleetdb"
 	StoreKindMock    StoreKind = "mock"
+	StoreKindFile    StoreKind = "file"
+	StoreKindSqlite  StoreKind = "sqlite"
 
 	LogLevelInfo  LogLevel = "info"
 	LogLevelDebug LogLevel = "debug"
@@ -48,6 +51,12 @@ const (
 	// EnvVarDumpDiffers when enabled, will dump component differ data for debugging
 	// differences identified in component objects in the publish package.
 	EnvVarDumpDiffers = "DEBUG_DUMP_DIFFERS"
+
+	// RedactedValue replaces secrets in output and logs.
+	RedactedValue = "[REDACTED]"
+
+	// BMCCredentialSourceStore is the source of the BMC credential from the inventory store.
+	BMCCredentialSourceStore = "store"
 )
 
 // Asset represents attributes of an asset retrieved from the asset store
@@ -72,12 +81,90 @@ type Asset struct {
 	BMCUsername string
 	// Password is the BMC login password from the inventory store
 	BMCPassword string
+	// BMCCredentialKey references the BMC credential in the secret provider,
+	// the BMCUsername, BMCPassword are resolved from the secret provider when this is set.
+	BMCCredentialKey string
 	// Errors is a map of errors,
 	// where the key is the stage at which the error occurred,
 	// and the value is the error.
 	Errors map[string]string
-	// Address is the BMC IP address from the inventory store
-	BMCAddress net.IP
+	// Address is the BMC IP address or hostname, with an optional port from the inventory store
+	BMCAddress *BMCAddress
+	// BMCCredentials are the fallback credentials tried in order, when the login with the BMCUsername, BMCPassword fails.
+	BMCCredentials []BMCCredential
+	// BMCCredentialSource is the source of the credential the BMC login succeeded with.
+	BMCCredentialSource string
+	// Attributes is the attribute data by namespace from the inventory store, for stores that support attributes.
+	Attributes map[string]json.RawMessage
+	// LastCollectedAt is the time the asset data was last collected, zero when unknown or never collected.
+	LastCollectedAt time.Time
+	// LastCollectFailed is set when the inventory was not collected in the last collection attempt.
+	LastCollectFailed bool
+}
+
+// BMCCredential is a candidate BMC login credential.
+type BMCCredential struct {
+	// Source identifies where the credential was loaded from.
+	Source   string
+	Username string
+	Password string
+}
+
+// BMCCredentialCandidates returns the BMC credentials to login with, in the order they are to be tried.
+//
+// The credential a previous login succeeded with is returned first,
+// followed by the inventory store credential and the fallback credentials.
+func (a *Asset) BMCCredentialCandidates() []BMCCredential {
+	candidates := make([]BMCCredential, 0, len(a.BMCCredentials)+1)
+
+	if a.BMCUsername != "" || a.BMCPassword != "" {
+		candidates = append(candidates, BMCCredential{Source: BMCCredentialSourceStore, Username: a.BMCUsername, Password: a.BMCPassword})
+	}
+
+	candidates = append(candidates, a.BMCCredentials...)
+
+	for idx, candidate := range candidates {
+		if idx > 0 && candidate.Source == a.BMCCredentialSource {
+			candidates = append(append([]BMCCredential{candidate}, candidates[:idx]...), candidates[idx+1:]...)
+			break
+		}
+	}
+
+	return candidates
+}
+
+// Redacted returns a shallow copy of the asset with the BMC credentials redacted.
+func (a *Asset) Redacted() *Asset {
+	redacted := *a
+
+	if redacted.BMCUsername != "" {
+		redacted.BMCUsername = RedactedValue
+	}
+
+	if redacted.BMCPassword != "" {
+		redacted.BMCPassword = RedactedValue
+	}
+
+	if len(redacted.BMCCredentials) > 0 {
+		redacted.BMCCredentials = make([]BMCCredential, 0, len(a.BMCCredentials))
+
+		for _, credential := range a.BMCCredentials {
+			redacted.BMCCredentials = append(
+				redacted.BMCCredentials,
+				BMCCredential{Source: credential.Source, Username: RedactedValue, Password: RedactedValue},
+			)
+		}
+	}
+
+	return &redacted
+}
+
+// MarshalJSON implements the json.Marshaler interface, the BMC credentials are redacted.
+func (a Asset) MarshalJSON() ([]byte, error) {
+	// the alias type does not implement json.Marshaler.
+	type asset Asset
+
+	return json.Marshal((*asset)(a.Redacted()))
 }
 
 // AppendError includes the given error key and value in the asset
@@ -94,3 +181,31 @@ func (a *Asset) HasError(cErr CollectorError) bool {
 	_, exists := a.Errors[string(cErr)]
 	return exists
 }
+
+// AssetSnapshot is the collected asset data as written to files by the csv, file stores.
+//
+// The BMC credentials are not included.
+type AssetSnapshot struct {
+	Inventory   *common.Device    `json:"inventory,omitempty"`
+	BiosConfig  map[string]string `json:"bios_config,omitempty"`
+	Errors      map[string]string `json:"errors,omitempty"`
+	ID          string            `json:"id"`
+	Vendor      string            `json:"vendor,omitempty"`
+	Model       string            `json:"model,omitempty"`
+	Serial      string            `json:"serial,omitempty"`
+	CollectedAt time.Time         `json:"collected_at"`
+}
+
+// NewAssetSnapshot returns an AssetSnapshot of the asset data collected at the given time.
+func NewAssetSnapshot(asset *Asset, collectedAt time.Time) *AssetSnapshot {
+	return &AssetSnapshot{
+		ID:          asset.ID,
+		Vendor:      asset.Vendor,
+		Model:       asset.Model,
+		Serial:      asset.Serial,
+		Inventory:   asset.Inventory,
+		BiosConfig:  asset.BiosConfig,
+		Errors:      asset.Errors,
+		CollectedAt: collectedAt,
+	}
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BMCCredentialCandidates(t *testing.T) {
+	asset := &Asset{
+		BMCUsername: "root",
+		BMCPassword: "stale",
+		BMCCredentials: []BMCCredential{
+			{Source: "default:dell", Username: "root", Password: "calvin"},
+			{Source: "default:any", Username: "ADMIN", Password: "ADMIN"},
+		},
+	}
+
+	sources := func() []string {
+		s := []string{}
+		for _, c := range asset.BMCCredentialCandidates() {
+			s = append(s, c.Source)
+		}
+
+		return s
+	}
+
+	assert.Equal(t, []string{BMCCredentialSourceStore, "default:dell", "default:any"}, sources())
+
+	// the credential a previous login succeeded with is tried first
+	asset.BMCCredentialSource = "default:any"
+	assert.Equal(t, []string{"default:any", BMCCredentialSourceStore, "default:dell"}, sources())
+	assert.Len(t, asset.BMCCredentials, 2)
+	assert.Equal(t, "default:dell", asset.BMCCredentials[0].Source)
+}
+
+func Test_AssetRedacted(t *testing.T) {
+	asset := &Asset{
+		BMCUsername:    "root",
+		BMCPassword:    "hunter2",
+		BMCCredentials: []BMCCredential{{Source: "default:dell", Username: "root", Password: "calvin"}},
+	}
+
+	redacted := asset.Redacted()
+	assert.Equal(t, RedactedValue, redacted.BMCPassword)
+	assert.Equal(t, BMCCredential{Source: "default:dell", Username: RedactedValue, Password: RedactedValue}, redacted.BMCCredentials[0])
+
+	// the asset is not modified
+	assert.Equal(t, "calvin", asset.BMCCredentials[0].Password)
+}
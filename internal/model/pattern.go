@@ -0,0 +1,21 @@
+package model
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchPattern returns true if the value matches the pattern, the facility, vendor and model patterns
+// in the configuration are matched with the asset attributes by this func.
+//
+// Patterns are in the path.Match syntax and compared case insensitive - dell, Dell and d* match the vendor Dell,
+// an empty pattern matches any value and an invalid pattern matches none.
+func MatchPattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+
+	return err == nil && matched
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MatchPattern(t *testing.T) {
+	testcases := []struct {
+		name     string
+		pattern  string
+		value    string
+		expected bool
+	}{
+		{"empty pattern", "", "Dell", true},
+		{"case insensitive", "dell", "Dell", true},
+		{"wildcard", "r6*", "R6515", true},
+		{"no match", "supermicro", "Dell", false},
+		{"invalid pattern", "[dell", "dell", false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, MatchPattern(tc.pattern, tc.value))
+		})
+	}
+}
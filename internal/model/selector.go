@@ -0,0 +1,242 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrAssetSelector = errors.New("invalid asset selector")
+)
+
+const (
+	// attributeSelectorSeparator separates the namespace, key, value of an attribute selector.
+	attributeSelectorSeparator = "~"
+
+	// attributeKeySeparator separates the keys of a nested attribute key path.
+	attributeKeySeparator = "."
+)
+
+// AssetSelector selects the assets returned by the asset iterator, the zero value selects all assets.
+//
+// Stores that support it apply the selector in the store query, the selector is also applied
+// to the assets returned by the store, so all stores return only the selected assets.
+type AssetSelector struct {
+	// Vendor, Model select assets with the vendor, model as registered in the inventory store.
+	Vendor string
+	Model  string
+
+	// SerialPrefix selects assets with a serial beginning with the prefix.
+	SerialPrefix string
+
+	// Attributes select assets with all of the attributes.
+	Attributes []AttributeSelector
+
+	// CollectedBefore selects assets last collected before the time, or never collected.
+	CollectedBefore time.Time
+
+	// CollectedAge selects assets last collected more than the duration ago, or never collected,
+	// the time is relative to when the selector is applied, for periodic collections.
+	CollectedAge time.Duration
+}
+
+// AttributeSelector selects assets by an attribute in the inventory store.
+//
+// An empty Key selects assets with the Namespace attribute,
+// an empty Value selects assets with the Key in the Namespace attribute.
+type AttributeSelector struct {
+	Namespace string
+	// Key is the attribute data key, nested keys are separated by a dot.
+	Key   string
+	Value string
+}
+
+// ParseAttributeSelector parses an attribute selector in the form namespace[~key[~value]].
+func ParseAttributeSelector(s string) (AttributeSelector, error) {
+	parts := strings.SplitN(s, attributeSelectorSeparator, 3) // nolint:gomnd // namespace, key, value
+
+	selector := AttributeSelector{Namespace: strings.TrimSpace(parts[0])}
+	if selector.Namespace == "" {
+		return AttributeSelector{}, errors.Wrap(ErrAssetSelector, "attribute namespace expected: "+s)
+	}
+
+	if len(parts) > 1 {
+		selector.Key = strings.TrimSpace(parts[1])
+		if selector.Key == "" {
+			return AttributeSelector{}, errors.Wrap(ErrAssetSelector, "attribute key expected: "+s)
+		}
+	}
+
+	if len(parts) > 2 { // nolint:gomnd // namespace, key, value
+		selector.Value = parts[2]
+	}
+
+	return selector, nil
+}
+
+// SetCollectedBefore sets the CollectedBefore time from an RFC3339 timestamp,
+// or the CollectedAge from a duration - 24h selects assets not collected in the last 24 hours.
+func (s *AssetSelector) SetCollectedBefore(value string) error {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		s.CollectedBefore = t
+
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return errors.Wrap(ErrAssetSelector, "RFC3339 timestamp or positive duration expected: "+value)
+	}
+
+	s.CollectedAge = d
+
+	return nil
+}
+
+// IsEmpty returns true when the selector selects all assets.
+func (s *AssetSelector) IsEmpty() bool {
+	return s == nil ||
+		(s.Vendor == "" && s.Model == "" && s.SerialPrefix == "" && len(s.Attributes) == 0 &&
+			s.CollectedBefore.IsZero() && s.CollectedAge == 0)
+}
+
+// SelectsCollected returns true when assets are selected by the time they were last collected.
+func (s *AssetSelector) SelectsCollected() bool {
+	return s != nil && (!s.CollectedBefore.IsZero() || s.CollectedAge > 0)
+}
+
+// String returns the selector in a canonical form, selectors with the same form select the same assets,
+// an empty string is returned when the selector selects all assets.
+func (s *AssetSelector) String() string {
+	if s.IsEmpty() {
+		return ""
+	}
+
+	var parts []string
+
+	add := func(name, value string) {
+		if value != "" {
+			parts = append(parts, name+"="+value)
+		}
+	}
+
+	add("vendor", s.Vendor)
+	add("model", s.Model)
+	add("serial_prefix", s.SerialPrefix)
+
+	attributes := make([]string, 0, len(s.Attributes))
+	for _, attribute := range s.Attributes {
+		attributes = append(
+			attributes,
+			strings.Join([]string{attribute.Namespace, attribute.Key, attribute.Value}, attributeSelectorSeparator),
+		)
+	}
+
+	sort.Strings(attributes)
+
+	for _, attribute := range attributes {
+		add("attribute", attribute)
+	}
+
+	if !s.CollectedBefore.IsZero() {
+		add("collected_before", s.CollectedBefore.UTC().Format(time.RFC3339))
+	}
+
+	if s.CollectedAge > 0 {
+		add("collected_age", s.CollectedAge.String())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// collectedBefore returns the time assets are to be last collected before, the zero time when not set.
+func (s *AssetSelector) collectedBefore(now time.Time) time.Time {
+	before := s.CollectedBefore
+
+	if s.CollectedAge > 0 {
+		if t := now.Add(-s.CollectedAge); before.IsZero() || t.Before(before) {
+			before = t
+		}
+	}
+
+	return before
+}
+
+// Match returns true when the asset is selected.
+func (s *AssetSelector) Match(asset *Asset) bool {
+	if s.IsEmpty() {
+		return true
+	}
+
+	if s.Vendor != "" && s.Vendor != asset.Vendor {
+		return false
+	}
+
+	if s.Model != "" && s.Model != asset.Model {
+		return false
+	}
+
+	if s.SerialPrefix != "" && !strings.HasPrefix(asset.Serial, s.SerialPrefix) {
+		return false
+	}
+
+	for _, attribute := range s.Attributes {
+		if !attribute.Match(asset.Attributes) {
+			return false
+		}
+	}
+
+	if before := s.collectedBefore(time.Now()); !before.IsZero() && !asset.LastCollectedAt.IsZero() && !asset.LastCollectedAt.Before(before) {
+		return false
+	}
+
+	return true
+}
+
+// Match returns true when the attributes include the selected attribute.
+func (a *AttributeSelector) Match(attributes map[string]json.RawMessage) bool {
+	if a.Key == "" {
+		_, exists := attributes[a.Namespace]
+		return exists
+	}
+
+	value, exists := a.Lookup(attributes)
+	if !exists {
+		return false
+	}
+
+	return a.Value == "" || value == a.Value
+}
+
+// Lookup returns the value of the Key in the Namespace attribute, false is returned when the attributes do not include it.
+//
+// String values are returned unquoted, other values by their JSON representation.
+func (a *AttributeSelector) Lookup(attributes map[string]json.RawMessage) (string, bool) {
+	data, exists := attributes[a.Namespace]
+	if !exists {
+		return "", false
+	}
+
+	for _, key := range strings.Split(a.Key, attributeKeySeparator) {
+		object := map[string]json.RawMessage{}
+		if err := json.Unmarshal(data, &object); err != nil {
+			return "", false
+		}
+
+		if data, exists = object[key]; !exists {
+			return "", false
+		}
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		return value, true
+	}
+
+	return string(bytes.TrimSpace(data)), true
+}
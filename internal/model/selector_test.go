@@ -0,0 +1,127 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseAttributeSelector(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected AttributeSelector
+		err      bool
+	}{
+		{"sh.hollow.bmc_info", AttributeSelector{Namespace: "sh.hollow.bmc_info"}, false},
+		{"sh.hollow.bmc_info~address", AttributeSelector{Namespace: "sh.hollow.bmc_info", Key: "address"}, false},
+		{"ns~a.b~foo~bar", AttributeSelector{Namespace: "ns", Key: "a.b", Value: "foo~bar"}, false},
+		{"~key~value", AttributeSelector{}, true},
+		{"ns~~value", AttributeSelector{}, true},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseAttributeSelector(tc.input)
+		if tc.err {
+			assert.ErrorIs(t, err, ErrAssetSelector, tc.input)
+			continue
+		}
+
+		require.Nil(t, err, tc.input)
+		assert.Equal(t, tc.expected, got, tc.input)
+	}
+}
+
+func Test_AssetSelectorSetCollectedBefore(t *testing.T) {
+	selector := &AssetSelector{Vendor: "dell"}
+	assert.False(t, selector.SelectsCollected())
+	assert.False(t, (*AssetSelector)(nil).SelectsCollected())
+
+	require.Nil(t, selector.SetCollectedBefore("2024-01-02T15:04:05Z"))
+	assert.Equal(t, time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), selector.CollectedBefore)
+	assert.True(t, selector.SelectsCollected())
+
+	require.Nil(t, selector.SetCollectedBefore("24h"))
+	assert.Equal(t, 24*time.Hour, selector.CollectedAge)
+
+	assert.ErrorIs(t, selector.SetCollectedBefore("-1h"), ErrAssetSelector)
+	assert.ErrorIs(t, selector.SetCollectedBefore("yesterday"), ErrAssetSelector)
+}
+
+func Test_AssetSelectorString(t *testing.T) {
+	testcases := []struct {
+		name     string
+		selector *AssetSelector
+		expected string
+	}{
+		{"nil", nil, ""},
+		{"empty", &AssetSelector{}, ""},
+		{"vendor, model", &AssetSelector{Vendor: "dell", Model: "r640"}, "vendor=dell,model=r640"},
+		{
+			"attributes sorted",
+			&AssetSelector{
+				Attributes: []AttributeSelector{
+					{Namespace: "sh.hollow.location", Key: "rack", Value: "r2"},
+					{Namespace: "sh.hollow.bmc"},
+				},
+			},
+			"attribute=sh.hollow.bmc~~,attribute=sh.hollow.location~rack~r2",
+		},
+		{
+			"collected",
+			&AssetSelector{CollectedBefore: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), CollectedAge: 24 * time.Hour},
+			"collected_before=2024-01-02T15:04:05Z,collected_age=24h0m0s",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.selector.String())
+		})
+	}
+}
+
+func Test_AssetSelectorMatch(t *testing.T) {
+	asset := &Asset{
+		Vendor: "dell",
+		Model:  "r6515",
+		Serial: "ABC123",
+		Attributes: map[string]json.RawMessage{
+			"sh.hollow.bmc_info": json.RawMessage(`{"address":"10.0.0.1","port":{"https":443}}`),
+		},
+		LastCollectedAt: time.Now().Add(-48 * time.Hour),
+	}
+
+	cases := []struct {
+		name     string
+		selector *AssetSelector
+		expected bool
+	}{
+		{"nil selector", nil, true},
+		{"vendor, model", &AssetSelector{Vendor: "dell", Model: "r6515"}, true},
+		{"model mismatch", &AssetSelector{Vendor: "dell", Model: "r640"}, false},
+		{"serial prefix", &AssetSelector{SerialPrefix: "ABC"}, true},
+		{"serial prefix mismatch", &AssetSelector{SerialPrefix: "abc"}, false},
+		{"attribute namespace", &AssetSelector{Attributes: []AttributeSelector{{Namespace: "sh.hollow.bmc_info"}}}, true},
+		{"attribute namespace missing", &AssetSelector{Attributes: []AttributeSelector{{Namespace: "foo"}}}, false},
+		{"attribute value", &AssetSelector{Attributes: []AttributeSelector{{Namespace: "sh.hollow.bmc_info", Key: "address", Value: "10.0.0.1"}}}, true},
+		{"nested attribute value", &AssetSelector{Attributes: []AttributeSelector{{Namespace: "sh.hollow.bmc_info", Key: "port.https", Value: "443"}}}, true},
+		{"attribute value mismatch", &AssetSelector{Attributes: []AttributeSelector{{Namespace: "sh.hollow.bmc_info", Key: "address", Value: "10.0.0.2"}}}, false},
+		{"attribute key missing", &AssetSelector{Attributes: []AttributeSelector{{Namespace: "sh.hollow.bmc_info", Key: "port.http"}}}, false},
+		{"collected before", &AssetSelector{CollectedBefore: time.Now().Add(-24 * time.Hour)}, true},
+		{"collected after", &AssetSelector{CollectedBefore: time.Now().Add(-72 * time.Hour)}, false},
+		{"collected age", &AssetSelector{CollectedAge: 24 * time.Hour}, true},
+		{"collected within age", &AssetSelector{CollectedAge: 72 * time.Hour}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.selector.Match(asset))
+		})
+	}
+
+	// assets never collected are selected by the collected before selector.
+	assert.True(t, (&AssetSelector{CollectedAge: time.Hour}).Match(&Asset{}))
+}
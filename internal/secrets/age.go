@@ -0,0 +1,117 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ageCredentialsFile is the format of the decrypted credentials file.
+type ageCredentialsFile struct {
+	Credentials map[string]struct {
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"credentials"`
+}
+
+// ageFileProvider reads BMC credentials from an age encrypted file.
+type ageFileProvider struct {
+	credentials map[string]Credential
+}
+
+// NewAgeFileProvider returns a SecretProvider that reads credentials from the age encrypted YAML file,
+// decrypted with the identities in the identity file,
+//
+//	credentials:
+//	  rack-a:
+//	    username: root
+//	    password: hunter2
+//
+// The file is decrypted once, the provider is to be re-initialized for changes to be picked up.
+func NewAgeFileProvider(filename, identityFile string) (SecretProvider, error) {
+	if filename == "" || identityFile == "" {
+		return nil, errors.Wrap(ErrSecretProvider, "credentials file and identity file expected")
+	}
+
+	identities, err := readIdentities(identityFile)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(ErrSecretProvider, err.Error())
+	}
+
+	var src io.Reader = bytes.NewReader(encrypted)
+	if bytes.HasPrefix(bytes.TrimSpace(encrypted), []byte(armor.Header)) {
+		src = armor.NewReader(src)
+	}
+
+	decrypted, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, errors.Wrap(ErrSecretProvider, "credentials file decrypt error: "+err.Error())
+	}
+
+	b, err := io.ReadAll(decrypted)
+	if err != nil {
+		return nil, errors.Wrap(ErrSecretProvider, "credentials file decrypt error: "+err.Error())
+	}
+
+	var f ageCredentialsFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, errors.Wrap(ErrSecretProvider, "credentials file: "+err.Error())
+	}
+
+	p := &ageFileProvider{credentials: make(map[string]Credential, len(f.Credentials))}
+
+	for key, c := range f.Credentials {
+		credential := Credential{Username: c.Username, Password: c.Password}
+		if err := validateCredential(key, &credential); err != nil {
+			return nil, err
+		}
+
+		p.credentials[key] = credential
+	}
+
+	return p, nil
+}
+
+func readIdentities(identityFile string) ([]age.Identity, error) {
+	fh, err := os.Open(identityFile)
+	if err != nil {
+		return nil, errors.Wrap(ErrSecretProvider, err.Error())
+	}
+
+	defer fh.Close()
+
+	identities, err := age.ParseIdentities(fh)
+	if err != nil {
+		return nil, errors.Wrap(ErrSecretProvider, "identity file: "+err.Error())
+	}
+
+	return identities, nil
+}
+
+func (a *ageFileProvider) Kind() string {
+	return ProviderKindAgeFile
+}
+
+func (a *ageFileProvider) Credential(_ context.Context, key string) (*Credential, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	credential, exists := a.credentials[key]
+	if !exists {
+		return nil, errors.Wrap(ErrSecretNotFound, key)
+	}
+
+	return &credential, nil
+}
@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dirProvider reads BMC credentials from files in a directory.
+type dirProvider struct {
+	dir string
+}
+
+// NewDirProvider returns a SecretProvider that reads the credential for a key
+// from the <dir>/<key>/username, <dir>/<key>/password files,
+// which is the layout of a Kubernetes secret with username, password keys mounted at <dir>/<key>.
+func NewDirProvider(dir string) (SecretProvider, error) {
+	if dir == "" {
+		return nil, errors.Wrap(ErrSecretProvider, "secrets directory not defined")
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, errors.Wrap(ErrSecretProvider, err.Error())
+	}
+
+	if !info.IsDir() {
+		return nil, errors.Wrap(ErrSecretProvider, "not a directory: "+dir)
+	}
+
+	return &dirProvider{dir: dir}, nil
+}
+
+func (d *dirProvider) Kind() string {
+	return ProviderKindDir
+}
+
+func (d *dirProvider) Credential(_ context.Context, key string) (*Credential, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	// the secret files are re-read so rotated secrets are picked up.
+	username, err := d.read(key, "username")
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := d.read(key, "password")
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &Credential{Username: username, Password: password}
+	if err := validateCredential(key, credential); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+func (d *dirProvider) read(key, name string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(d.dir, key, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", errors.Wrap(ErrSecretNotFound, key+"/"+name)
+		}
+
+		return "", errors.Wrap(ErrSecretProvider, err.Error())
+	}
+
+	// secrets written with a trailing newline are common.
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
@@ -0,0 +1,51 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// envProvider reads BMC credentials from environment variables.
+type envProvider struct {
+	prefix string
+}
+
+// NewEnvProvider returns a SecretProvider that reads the credential for a key
+// from the <prefix><KEY>_USERNAME, <prefix><KEY>_PASSWORD environment variables,
+// the key is upper cased with the '.', '-' characters replaced by '_'.
+func NewEnvProvider(prefix string) SecretProvider {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+
+	return &envProvider{prefix: prefix}
+}
+
+func (e *envProvider) Kind() string {
+	return ProviderKindEnv
+}
+
+func (e *envProvider) Credential(_ context.Context, key string) (*Credential, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	name := e.prefix + strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(key))
+
+	username, usernameSet := os.LookupEnv(name + "_USERNAME")
+	password, passwordSet := os.LookupEnv(name + "_PASSWORD")
+
+	if !usernameSet && !passwordSet {
+		return nil, errors.Wrap(ErrSecretNotFound, key)
+	}
+
+	credential := &Credential{Username: username, Password: password}
+	if err := validateCredential(key, credential); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+)
+
+var (
+	ErrSecretProvider = errors.New("secret provider error")
+	ErrSecretNotFound = errors.New("secret not found")
+	ErrSecretKey      = errors.New("invalid secret key")
+)
+
+const (
+	ProviderKindEnv     = "env"
+	ProviderKindDir     = "dir"
+	ProviderKindAgeFile = "age_file"
+
+	// DefaultEnvPrefix is the prefix of the environment variables the env provider reads when not configured.
+	DefaultEnvPrefix = "ALLOY_BMC_CREDENTIAL_"
+)
+
+// keyRegexp matches the valid credential keys,
+// keys are limited to these characters so they can be used as file and environment variable names.
+var keyRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Credential is a BMC credential from a secret provider.
+type Credential struct {
+	Username string
+	Password string
+}
+
+// SecretProvider returns the BMC credentials referenced by key.
+type SecretProvider interface {
+	// Kind returns the secret provider kind.
+	Kind() string
+
+	// Credential returns the credential for the key, ErrSecretNotFound is returned when the key does not exist.
+	Credential(ctx context.Context, key string) (*Credential, error)
+}
+
+// NewProvider returns the SecretProvider for the configured provider kind.
+func NewProvider(cfg *app.SecretsOptions) (SecretProvider, error) {
+	if cfg == nil {
+		return nil, errors.Wrap(ErrSecretProvider, "configuration not defined")
+	}
+
+	switch cfg.Provider {
+	case ProviderKindEnv:
+		return NewEnvProvider(cfg.EnvPrefix), nil
+
+	case ProviderKindDir:
+		return NewDirProvider(cfg.Dir)
+
+	case ProviderKindAgeFile:
+		return NewAgeFileProvider(cfg.File, cfg.IdentityFile)
+
+	default:
+		return nil, errors.Wrap(ErrSecretProvider, "unsupported provider kind: "+cfg.Provider)
+	}
+}
+
+func validateKey(key string) error {
+	if !keyRegexp.MatchString(key) {
+		return errors.Wrap(ErrSecretKey, key)
+	}
+
+	return nil
+}
+
+func validateCredential(key string, credential *Credential) error {
+	if credential.Username == "" || credential.Password == "" {
+		return errors.Wrap(ErrSecretProvider, "username, password expected for key: "+key)
+	}
+
+	return nil
+}
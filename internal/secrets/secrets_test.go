@@ -0,0 +1,116 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+)
+
+func Test_EnvProvider(t *testing.T) {
+	t.Setenv("ALLOY_BMC_CREDENTIAL_RACK_A_USERNAME", "root")
+	t.Setenv("ALLOY_BMC_CREDENTIAL_RACK_A_PASSWORD", "hunter2")
+	t.Setenv("ALLOY_BMC_CREDENTIAL_RACK_B_USERNAME", "root")
+
+	provider, err := NewProvider(&app.SecretsOptions{Provider: ProviderKindEnv})
+	require.Nil(t, err)
+
+	credential, err := provider.Credential(context.TODO(), "rack-a")
+	require.Nil(t, err)
+	assert.Equal(t, &Credential{Username: "root", Password: "hunter2"}, credential)
+
+	_, err = provider.Credential(context.TODO(), "rack-b")
+	assert.ErrorIs(t, err, ErrSecretProvider)
+
+	_, err = provider.Credential(context.TODO(), "rack-c")
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+}
+
+func Test_DirProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "rack-a"), 0o700))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "rack-a", "username"), []byte("root\n"), 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "rack-a", "password"), []byte("hunter2\n"), 0o600))
+
+	provider, err := NewProvider(&app.SecretsOptions{Provider: ProviderKindDir, Dir: dir})
+	require.Nil(t, err)
+
+	credential, err := provider.Credential(context.TODO(), "rack-a")
+	require.Nil(t, err)
+	assert.Equal(t, &Credential{Username: "root", Password: "hunter2"}, credential)
+
+	_, err = provider.Credential(context.TODO(), "rack-b")
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+
+	// keys are not to reference files outside the directory
+	for _, key := range []string{"../rack-a", "rack-a/..", ".hidden", ""} {
+		_, err = provider.Credential(context.TODO(), key)
+		assert.ErrorIs(t, err, ErrSecretKey, key)
+	}
+}
+
+func Test_AgeFileProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	identity, err := age.GenerateX25519Identity()
+	require.Nil(t, err)
+
+	identityFile := filepath.Join(dir, "identity.txt")
+	require.Nil(t, os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0o600))
+
+	plaintext := "credentials:\n  rack-a:\n    username: root\n    password: hunter2\n"
+
+	for _, armored := range []bool{false, true} {
+		buf := &bytes.Buffer{}
+
+		var dst io.Writer = buf
+
+		var armorWriter io.WriteCloser
+		if armored {
+			armorWriter = armor.NewWriter(buf)
+			dst = armorWriter
+		}
+
+		w, err := age.Encrypt(dst, identity.Recipient())
+		require.Nil(t, err)
+
+		_, err = w.Write([]byte(plaintext))
+		require.Nil(t, err)
+		require.Nil(t, w.Close())
+
+		if armorWriter != nil {
+			require.Nil(t, armorWriter.Close())
+		}
+
+		file := filepath.Join(dir, "credentials.yaml.age")
+		require.Nil(t, os.WriteFile(file, buf.Bytes(), 0o600))
+
+		provider, err := NewProvider(&app.SecretsOptions{Provider: ProviderKindAgeFile, File: file, IdentityFile: identityFile})
+		require.Nil(t, err)
+
+		credential, err := provider.Credential(context.TODO(), "rack-a")
+		require.Nil(t, err)
+		assert.Equal(t, &Credential{Username: "root", Password: "hunter2"}, credential)
+
+		_, err = provider.Credential(context.TODO(), "rack-b")
+		assert.ErrorIs(t, err, ErrSecretNotFound)
+	}
+
+	// the file is not decrypted with another identity
+	other, err := age.GenerateX25519Identity()
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(identityFile, []byte(other.String()+"\n"), 0o600))
+
+	_, err = NewAgeFileProvider(filepath.Join(dir, "credentials.yaml.age"), identityFile)
+	assert.ErrorIs(t, err, ErrSecretProvider)
+}
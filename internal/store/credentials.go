@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+var (
+	ErrCredentialsFile = errors.New("error in BMC credentials file")
+)
+
+// DefaultCredential is a BMC credential tried for assets matching the vendor, model patterns,
+// when the login with the credential from the inventory store fails.
+type DefaultCredential struct {
+	// Name identifies the credential in the reported credential source.
+	Name string `yaml:"name"`
+
+	// Vendor, Model are the patterns matched with the asset vendor, model by model.MatchPattern.
+	Vendor string `yaml:"vendor"`
+	Model  string `yaml:"model"`
+
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// credentialsFile is the BMC credentials file format.
+type credentialsFile struct {
+	Defaults []DefaultCredential `yaml:"defaults"`
+}
+
+// LoadDefaultCredentials returns the default BMC credentials from the given YAML file,
+//
+//	defaults:
+//	  - name: dell-factory
+//	    vendor: dell
+//	    username: root
+//	    password: calvin
+func LoadDefaultCredentials(filename string) ([]DefaultCredential, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(ErrCredentialsFile, err.Error())
+	}
+
+	var f credentialsFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, errors.Wrap(ErrCredentialsFile, filename+": "+err.Error())
+	}
+
+	for idx := range f.Defaults {
+		if f.Defaults[idx].Username == "" || f.Defaults[idx].Password == "" {
+			return nil, errors.Wrap(ErrCredentialsFile, "username, password expected for entry: "+strconv.Itoa(idx))
+		}
+
+		if f.Defaults[idx].Name == "" {
+			f.Defaults[idx].Name = strconv.Itoa(idx)
+		}
+	}
+
+	return f.Defaults, nil
+}
+
+// Source returns the credential source reported when the BMC login succeeds with the credential.
+func (d *DefaultCredential) Source() string {
+	return "default:" + d.Name
+}
+
+func (d *DefaultCredential) matches(asset *model.Asset) bool {
+	return model.MatchPattern(d.Vendor, asset.Vendor) && model.MatchPattern(d.Model, asset.Model)
+}
+
+// credentialFallback is a Repository that includes the default credentials matching each asset
+// as the fallback BMC credentials of the assets returned by the wrapped Repository.
+type credentialFallback struct {
+	Repository
+	defaults []DefaultCredential
+}
+
+// WithCredentialFallback returns a Repository that includes the matching default credentials
+// as the fallback BMC credentials of the assets returned by the given Repository.
+func WithCredentialFallback(repository Repository, defaults []DefaultCredential) Repository {
+	return &credentialFallback{Repository: repository, defaults: defaults}
+}
+
+// AssetByID returns one asset from the inventory identified by its identifier.
+func (c *credentialFallback) AssetByID(ctx context.Context, assetID string, fetchBmcCredentials bool) (*model.Asset, error) {
+	asset, err := c.Repository.AssetByID(ctx, assetID, fetchBmcCredentials)
+	if err != nil || asset == nil || !fetchBmcCredentials {
+		return asset, err
+	}
+
+	c.setFallbackCredentials(asset)
+
+	return asset, nil
+}
+
+// AssetsByOffsetLimit returns the assets at the given offset (page), limit values.
+func (c *credentialFallback) AssetsByOffsetLimit(ctx context.Context, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	return c.AssetsBySelector(ctx, nil, offset, limit)
+}
+
+// AssetsBySelector returns the assets at the given offset (page), limit values, selected by the selector.
+func (c *credentialFallback) AssetsBySelector(ctx context.Context, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	assets, totalAssets, err = AssetsBySelector(ctx, c.Repository, selector, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, asset := range assets {
+		c.setFallbackCredentials(asset)
+	}
+
+	return assets, totalAssets, nil
+}
+
+// ListAssets returns the assets at the given offset (page), limit values, selected by the selector,
+// without the BMC credentials.
+func (c *credentialFallback) ListAssets(ctx context.Context, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	return ListAssets(ctx, c.Repository, selector, offset, limit)
+}
+
+func (c *credentialFallback) setFallbackCredentials(asset *model.Asset) {
+	for idx := range c.defaults {
+		if !c.defaults[idx].matches(asset) {
+			continue
+		}
+
+		asset.BMCCredentials = append(asset.BMCCredentials, model.BMCCredential{
+			Source:   c.defaults[idx].Source(),
+			Username: c.defaults[idx].Username,
+			Password: c.defaults[idx].Password,
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+	"github.com/metal-toolbox/alloy/internal/store/mock"
+)
+
+const testCredentialsFile = `
+defaults:
+  - name: dell-factory
+    vendor: Dell
+    username: root
+    password: calvin
+  - vendor: supermicro
+    model: x11*
+    username: ADMIN
+    password: ADMIN
+  - name: site
+    username: admin
+    password: hunter2
+`
+
+func Test_LoadDefaultCredentials(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "credentials.yaml")
+	require.Nil(t, os.WriteFile(filename, []byte(testCredentialsFile), 0o600))
+
+	defaults, err := LoadDefaultCredentials(filename)
+	require.Nil(t, err)
+	assert.Len(t, defaults, 3)
+	assert.Equal(t, "default:dell-factory", defaults[0].Source())
+	assert.Equal(t, "default:1", defaults[1].Source())
+
+	require.Nil(t, os.WriteFile(filename, []byte("defaults:\n  - vendor: dell\n    username: root\n"), 0o600))
+
+	_, err = LoadDefaultCredentials(filename)
+	assert.ErrorIs(t, err, ErrCredentialsFile)
+}
+
+func Test_CredentialFallback(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "credentials.yaml")
+	require.Nil(t, os.WriteFile(filename, []byte(testCredentialsFile), 0o600))
+
+	defaults, err := LoadDefaultCredentials(filename)
+	require.Nil(t, err)
+
+	repository, err := mock.New(1)
+	require.Nil(t, err)
+
+	fallback := WithCredentialFallback(repository, defaults)
+
+	cases := []struct {
+		vendor  string
+		model   string
+		sources []string
+	}{
+		{"dell", "PowerEdge R640", []string{"default:dell-factory", "default:site"}},
+		{"supermicro", "X11DPT-B", []string{"default:1", "default:site"}},
+		{"supermicro", "X12STH-SYS", []string{"default:site"}},
+	}
+
+	for _, tc := range cases {
+		asset := &model.Asset{Vendor: tc.vendor, Model: tc.model}
+		fallback.(*credentialFallback).setFallbackCredentials(asset)
+
+		sources := []string{}
+		for _, c := range asset.BMCCredentials {
+			sources = append(sources, c.Source)
+		}
+
+		assert.Equal(t, tc.sources, sources, tc.vendor+" "+tc.model)
+	}
+
+	// the assets returned by the repository include the matching fallback credentials
+	assets, _, err := fallback.AssetsByOffsetLimit(context.TODO(), 1, 1)
+	require.Nil(t, err)
+	require.Len(t, assets, 1)
+	assert.Len(t, assets[0].BMCCredentials, 1)
+
+	asset, err := fallback.AssetByID(context.TODO(), "foo", true)
+	require.Nil(t, err)
+	assert.Len(t, asset.BMCCredentials, 1)
+
+	// assets fetched without BMC credentials do not include the fallback credentials
+	asset, err = fallback.AssetByID(context.TODO(), "foo", false)
+	require.Nil(t, err)
+	assert.Empty(t, asset.BMCCredentials)
+}
@@ -3,12 +3,15 @@ package csv
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"io"
-	"net"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/metal-toolbox/alloy/internal/helpers"
 	"github.com/metal-toolbox/alloy/internal/model"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -22,24 +25,42 @@ const (
 var (
 	ErrAssetNotFound = errors.New("not found")
 	ErrCSVSource     = errors.New("error in CSV")
+	ErrAssetUpdate   = errors.New("error writing asset data")
 )
 
 type Store struct {
-	csvReader io.ReadCloser
 	logger    *logrus.Entry
+	outputDir string
+	assets    []*model.Asset
 }
 
 // New returns a new csv asset getter to retrieve asset information from a CSV file for inventory collection.
-func New(_ context.Context, csvFile string, logger *logrus.Logger) (*Store, error) {
+//
+// When the outputDir parameter is set, the collected asset data is written to the directory.
+func New(ctx context.Context, csvFile, outputDir string, logger *logrus.Logger) (*Store, error) {
 	fh, err := os.Open(csvFile)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Store{
+	s := &Store{
 		logger:    logger.WithField("component", "store.csv"),
-		csvReader: fh,
-	}, nil
+		outputDir: outputDir,
+	}
+
+	s.assets, err = s.loadAssets(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+
+	if outputDir != "" {
+		// nolint:gomnd // directory permissions are clearer in this form.
+		if err := os.MkdirAll(outputDir, 0o750); err != nil {
+			return nil, errors.Wrap(ErrAssetUpdate, err.Error())
+		}
+	}
+
+	return s, nil
 }
 
 // Kind returns the repository store kind.
@@ -48,29 +69,81 @@ func (c *Store) Kind() model.StoreKind {
 }
 
 // AssetByID returns one asset from the inventory identified by its identifier.
-func (c *Store) AssetByID(ctx context.Context, assetID string, _ bool) (*model.Asset, error) {
-	assets, err := c.loadAssets(ctx, c.csvReader)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, asset := range assets {
+func (c *Store) AssetByID(_ context.Context, assetID string, _ bool) (*model.Asset, error) {
+	for _, asset := range c.assets {
 		if asset.ID == assetID {
-			return asset, nil
+			return copyAsset(asset), nil
 		}
 	}
 
 	return nil, errors.Wrap(ErrAssetNotFound, assetID)
 }
 
-func (c *Store) AssetsByOffsetLimit(_ context.Context, _, _ int) (assets []*model.Asset, totalAssets int, err error) {
-	return nil, 0, nil
+// AssetsByOffsetLimit returns the assets in the CSV at the given offset (page), limit values.
+func (c *Store) AssetsByOffsetLimit(_ context.Context, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	if offset < 1 || limit < 1 {
+		return nil, len(c.assets), nil
+	}
+
+	start := (offset - 1) * limit
+	if start >= len(c.assets) {
+		return nil, len(c.assets), nil
+	}
+
+	end := start + limit
+	if end > len(c.assets) {
+		end = len(c.assets)
+	}
+
+	for _, asset := range c.assets[start:end] {
+		assets = append(assets, copyAsset(asset))
+	}
+
+	return assets, len(c.assets), nil
 }
 
-func (c *Store) AssetUpdate(_ context.Context, _ *model.Asset) error {
+// AssetUpdate writes the collected inventory, BIOS configuration and errors for the asset
+// as a JSON file named by the asset ID in the output directory.
+//
+// The asset data is not written when the output directory is not configured.
+func (c *Store) AssetUpdate(_ context.Context, asset *model.Asset) error {
+	if c.outputDir == "" {
+		c.logger.WithField("assetID", asset.ID).Debug("output directory not configured, asset data not written")
+
+		return nil
+	}
+
+	if _, err := uuid.Parse(asset.ID); err != nil {
+		return errors.Wrap(ErrAssetUpdate, "invalid asset ID: "+asset.ID)
+	}
+
+	b, err := json.MarshalIndent(model.NewAssetSnapshot(asset, time.Now()), "", " ")
+	if err != nil {
+		return errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	if err := helpers.WriteFileAtomic(filepath.Join(c.outputDir, asset.ID+".json"), b); err != nil {
+		return errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	c.logger.WithField("assetID", asset.ID).Debug("asset data written")
+
 	return nil
 }
 
+// copyAsset returns a copy of the asset attributes loaded from the CSV,
+// so callers updating the returned asset don't modify the loaded data.
+func copyAsset(asset *model.Asset) *model.Asset {
+	return &model.Asset{
+		ID:               asset.ID,
+		BMCUsername:      asset.BMCUsername,
+		BMCPassword:      asset.BMCPassword,
+		BMCCredentialKey: asset.BMCCredentialKey,
+		BMCAddress:       asset.BMCAddress,
+		Vendor:           asset.Vendor,
+	}
+}
+
 // loadAssets returns a slice of assets from the given csv io.Reader
 func (c *Store) loadAssets(_ context.Context, csvReader io.ReadCloser) ([]*model.Asset, error) {
 	records, err := csv.NewReader(csvReader).ReadAll()
@@ -87,7 +160,9 @@ func (c *Store) loadAssets(_ context.Context, csvReader io.ReadCloser) ([]*model
 	assets := []*model.Asset{}
 
 	// csv is of the format
-	// uuid, IP address, username, password
+	// uuid, BMC address, username, password, vendor, credential key
+	//
+	// the username, password may be left empty when the credential key references the credential in the secret provider.
 	for idx, rec := range records {
 		// skip csv header
 		if idx == 0 {
@@ -95,43 +170,49 @@ func (c *Store) loadAssets(_ context.Context, csvReader io.ReadCloser) ([]*model
 		}
 
 		id := strings.TrimSpace(rec[0])
-		ip := strings.TrimSpace(rec[1])
+		address := strings.TrimSpace(rec[1])
 		username := strings.TrimSpace(rec[2])
 		password := strings.TrimSpace(rec[3])
 
-		var vendor string
+		var vendor, credentialKey string
 
 		// nolint:gomnd // field 4 is the vendor name, and its optional.
 		if len(rec) > 4 {
 			vendor = strings.TrimSpace(rec[4])
 		}
 
+		// nolint:gomnd // field 5 is the credential key, and its optional.
+		if len(rec) > 5 {
+			credentialKey = strings.TrimSpace(rec[5])
+		}
+
 		_, err := uuid.Parse(strings.TrimSpace(id))
 		if err != nil {
 			return nil, errors.Wrap(ErrCSVSource, err.Error()+": "+id)
 		}
 
-		parsedIP := net.ParseIP(ip)
-		if parsedIP == nil {
-			return nil, errors.Wrap(ErrCSVSource, "invalid IP address: "+ip)
+		bmcAddress, err := model.ParseBMCAddress(address)
+		if err != nil {
+			return nil, errors.Wrap(ErrCSVSource, err.Error())
 		}
 
-		if username == "" {
+		if username == "" && credentialKey == "" {
 			return nil, errors.Wrap(ErrCSVSource, "invalid username string")
 		}
 
-		if password == "" {
+		if password == "" && credentialKey == "" {
 			return nil, errors.Wrap(ErrCSVSource, "invalid password string")
 		}
 
 		assets = append(
 			assets,
 			&model.Asset{
-				ID:          id,
-				BMCUsername: username,
-				BMCPassword: password,
-				BMCAddress:  parsedIP,
-				Vendor:      vendor,
+				ID:               id,
+				BMCUsername:      username,
+				BMCPassword:      password,
+				BMCCredentialKey: credentialKey,
+				BMCAddress:       bmcAddress,
+				Vendor:           vendor,
 			},
 		)
 	}
@@ -0,0 +1,119 @@
+package csv
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	common "github.com/metal-toolbox/bmc-common"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+const testCSV = `id,ipaddress,username,password,vendor
+7b8a090d-3900-4c45-89e1-041044d27402,192.168.1.1,root,calvin,dell
+a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2,192.168.1.2,root,calvin,supermicro
+f0c8e4ac-5cce-4370-93ce-e0a5ec0e5c4c,bmc-3.example.com:8443,root,calvin,
+`
+
+func testStore(t *testing.T, outputDir string) *Store {
+	t.Helper()
+
+	csvFile := filepath.Join(t.TempDir(), "assets.csv")
+	if err := os.WriteFile(csvFile, []byte(testCSV), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := New(context.TODO(), csvFile, outputDir, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func Test_AssetByID(t *testing.T) {
+	store := testStore(t, "")
+
+	// the CSV is read once, assets are available for subsequent lookups
+	for i := 0; i < 2; i++ {
+		asset, err := store.AssetByID(context.TODO(), "a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2", true)
+		require.Nil(t, err)
+		assert.Equal(t, "192.168.1.2", asset.BMCAddress.String())
+		assert.Equal(t, "supermicro", asset.Vendor)
+	}
+
+	// BMCs may be registered by hostname with a port
+	asset, err := store.AssetByID(context.TODO(), "f0c8e4ac-5cce-4370-93ce-e0a5ec0e5c4c", true)
+	require.Nil(t, err)
+	assert.Equal(t, &model.BMCAddress{Host: "bmc-3.example.com", Port: 8443}, asset.BMCAddress)
+
+	_, err = store.AssetByID(context.TODO(), "1ce1b6c1-1b1f-4b2a-8a3c-0c55f07c3f10", true)
+	assert.ErrorIs(t, err, ErrAssetNotFound)
+}
+
+func Test_AssetsByOffsetLimit(t *testing.T) {
+	store := testStore(t, "")
+
+	cases := []struct {
+		name     string
+		offset   int
+		limit    int
+		expected int
+	}{
+		{"first page", 1, 2, 2},
+		{"last page", 2, 2, 1},
+		{"beyond last page", 3, 2, 0},
+		{"limit higher than total", 1, 10, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assets, total, err := store.AssetsByOffsetLimit(context.TODO(), tc.offset, tc.limit)
+			require.Nil(t, err)
+			assert.Equal(t, 3, total)
+			assert.Equal(t, tc.expected, len(assets))
+		})
+	}
+}
+
+func Test_AssetUpdate(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "output")
+	store := testStore(t, outputDir)
+
+	asset, err := store.AssetByID(context.TODO(), "7b8a090d-3900-4c45-89e1-041044d27402", true)
+	require.Nil(t, err)
+
+	asset.Inventory = &common.Device{Common: common.Common{Vendor: "dell", Model: "r6515"}}
+	asset.BiosConfig = map[string]string{"foo": "bar"}
+	asset.AppendError("GetBiosConfigError", "device not supported")
+
+	err = store.AssetUpdate(context.TODO(), asset)
+	require.Nil(t, err)
+
+	b, err := os.ReadFile(filepath.Join(outputDir, asset.ID+".json"))
+	require.Nil(t, err)
+
+	got := &model.AssetSnapshot{}
+	require.Nil(t, json.Unmarshal(b, got))
+
+	assert.Equal(t, asset.ID, got.ID)
+	assert.Equal(t, "r6515", got.Inventory.Model)
+	assert.Equal(t, asset.BiosConfig, got.BiosConfig)
+	assert.Equal(t, asset.Errors, got.Errors)
+	assert.NotContains(t, string(b), "calvin")
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(outputDir)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	// invalid asset IDs are not written
+	err = store.AssetUpdate(context.TODO(), &model.Asset{ID: "../foo"})
+	assert.ErrorIs(t, err, ErrAssetUpdate)
+}
@@ -0,0 +1,462 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
+	"github.com/metal-toolbox/alloy/internal/helpers"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+const (
+	// assetsDir is the sub directory in the store directory that holds the asset files.
+	assetsDir = "assets"
+
+	// snapshotsDir is the sub directory in the store directory that collected asset data is written to.
+	snapshotsDir = "snapshots"
+
+	// snapshotTimeFormat is the timestamp format of the snapshot file names,
+	// with nanoseconds for the snapshots written within a second to be retained.
+	snapshotTimeFormat = "20060102T150405.000000000Z"
+
+	// snapshotTimeFormatSeconds is the timestamp format of snapshot file names written by earlier releases.
+	snapshotTimeFormatSeconds = "20060102T150405Z"
+)
+
+var (
+	ErrAssetNotFound = errors.New("not found")
+	ErrAssetSource   = errors.New("error in asset file")
+	ErrAssetUpdate   = errors.New("error writing asset snapshot")
+)
+
+// Store is an asset inventory store backed by a local directory.
+//
+// Assets and their BMC credentials are read from the YAML/JSON files in the assets sub directory,
+// the collected asset data is written as timestamped JSON snapshots into the snapshots sub directory,
+//
+//	<dir>/assets/rack-a.yaml
+//	<dir>/snapshots/<asset ID>/20240102T150405Z.json
+//
+// The assets are loaded once per sweep, and re-loaded on the first page listed,
+// or when the asset files are changed, added or removed.
+type Store struct {
+	logger *logrus.Entry
+	dir    string
+	assets []*model.Asset
+	// index is the loaded assets by their ID.
+	index map[string]*model.Asset
+	// files is the modification time of the asset files the assets were loaded from.
+	files map[string]time.Time
+	// collectionStatus is set when the listed assets include the time they were last collected,
+	// for assets to be ordered or selected by it.
+	collectionStatus bool
+	mu               sync.RWMutex
+}
+
+// assetRecord is an asset entry in an asset file.
+//
+// An asset file holds a single asset entry or a list of asset entries.
+type assetRecord struct {
+	ID            string `yaml:"id"`
+	BMCAddress    string `yaml:"bmc_address"`
+	BMCUsername   string `yaml:"bmc_username"`
+	BMCPassword   string `yaml:"bmc_password"`
+	BMCCredential string `yaml:"bmc_credential"`
+	Vendor        string `yaml:"vendor"`
+	Model         string `yaml:"model"`
+	Serial        string `yaml:"serial"`
+	Facility      string `yaml:"facility"`
+}
+
+// New returns a file store that reads assets from, and writes collected asset data to the given directory.
+//
+// With collectionStatus set, the listed assets include the time they were last collected, read from their snapshots.
+func New(_ context.Context, dir string, collectionStatus bool, logger *logrus.Logger) (*Store, error) {
+	if dir == "" {
+		return nil, errors.Wrap(ErrAssetSource, "store directory not defined")
+	}
+
+	s := &Store{
+		dir:              dir,
+		collectionStatus: collectionStatus,
+		logger:           logger.WithField("component", "store.file"),
+	}
+
+	if err := s.loadAssets(); err != nil {
+		return nil, err
+	}
+
+	// nolint:gomnd // directory permissions are clearer in this form.
+	if err := os.MkdirAll(filepath.Join(dir, snapshotsDir), 0o750); err != nil {
+		return nil, errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	return s, nil
+}
+
+// Kind returns the repository store kind.
+func (s *Store) Kind() model.StoreKind {
+	return model.StoreKindFile
+}
+
+// AssetByID returns one asset from the inventory identified by its identifier.
+func (s *Store) AssetByID(_ context.Context, assetID string, fetchBmcCredentials bool) (*model.Asset, error) {
+	// assets are re-read when the asset files changed so that changes are picked up.
+	changed, err := s.assetFilesChanged()
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		if err := s.loadAssets(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	asset, exists := s.index[assetID]
+	if !exists {
+		return nil, errors.Wrap(ErrAssetNotFound, assetID)
+	}
+
+	return copyAsset(asset, fetchBmcCredentials), nil
+}
+
+// AssetsByOffsetLimit returns the assets at the given offset (page), limit values, ordered by the asset ID.
+//
+// The asset files are re-read when the first page is requested.
+func (s *Store) AssetsByOffsetLimit(_ context.Context, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	if offset == 1 {
+		if err := s.loadAssets(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if offset < 1 || limit < 1 {
+		return nil, len(s.assets), nil
+	}
+
+	start := (offset - 1) * limit
+	if start >= len(s.assets) {
+		return nil, len(s.assets), nil
+	}
+
+	end := start + limit
+	if end > len(s.assets) {
+		end = len(s.assets)
+	}
+
+	for _, asset := range s.assets[start:end] {
+		c := copyAsset(asset, true)
+		if s.collectionStatus {
+			c.LastCollectedAt, c.LastCollectFailed = s.lastCollection(asset.ID)
+		}
+
+		assets = append(assets, c)
+	}
+
+	return assets, len(s.assets), nil
+}
+
+// lastCollection returns the time of the most recent snapshot written for the asset with the inventory collected,
+// and if the inventory was not collected in the most recent snapshot,
+// a zero time is returned when the asset has no such snapshots.
+//
+// Snapshots of collections skipped on an open BMC circuit are not considered.
+func (s *Store) lastCollection(assetID string) (lastCollectedAt time.Time, lastCollectFailed bool) {
+	dir := filepath.Join(s.dir, snapshotsDir, assetID)
+
+	// entries are sorted by the file name, which is the snapshot timestamp.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	latest := true
+
+	for idx := len(entries) - 1; idx >= 0; idx-- {
+		collectedAt, err := parseSnapshotTime(entries[idx].Name())
+		if err != nil {
+			continue
+		}
+
+		failed, skipped := snapshotCollectionResult(filepath.Join(dir, entries[idx].Name()))
+		if skipped {
+			continue
+		}
+
+		if latest {
+			lastCollectFailed, latest = failed, false
+		}
+
+		if !failed {
+			return collectedAt, lastCollectFailed
+		}
+	}
+
+	return time.Time{}, lastCollectFailed
+}
+
+// parseSnapshotTime returns the time the snapshot with the given file name was written.
+func parseSnapshotTime(name string) (time.Time, error) {
+	value := strings.TrimSuffix(name, ".json")
+
+	collectedAt, err := time.Parse(snapshotTimeFormat, value)
+	if err != nil {
+		return time.Parse(snapshotTimeFormatSeconds, value)
+	}
+
+	return collectedAt, nil
+}
+
+// snapshotCollectionResult returns if the snapshot records the inventory was not collected,
+// or the collection was skipped, snapshots that cannot be read are considered failed.
+func snapshotCollectionResult(name string) (failed, skipped bool) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return true, false
+	}
+
+	snapshot := &model.AssetSnapshot{}
+	if err := json.Unmarshal(b, snapshot); err != nil {
+		return true, false
+	}
+
+	asset := &model.Asset{Errors: snapshot.Errors}
+
+	return outofband.InventoryFailed(asset), outofband.CollectionSkipped(asset)
+}
+
+// AssetUpdate writes the collected asset data as a timestamped snapshot in the snapshots directory.
+func (s *Store) AssetUpdate(_ context.Context, asset *model.Asset) error {
+	if asset == nil {
+		return errors.Wrap(ErrAssetUpdate, "asset object is nil")
+	}
+
+	if _, err := uuid.Parse(asset.ID); err != nil {
+		return errors.Wrap(ErrAssetUpdate, "invalid asset ID: "+asset.ID)
+	}
+
+	collectedAt := time.Now().UTC()
+
+	b, err := json.MarshalIndent(model.NewAssetSnapshot(asset, collectedAt), "", " ")
+	if err != nil {
+		return errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	dir := filepath.Join(s.dir, snapshotsDir, asset.ID)
+
+	// nolint:gomnd // directory permissions are clearer in this form.
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	// an existing snapshot is not overwritten.
+	name := filepath.Join(dir, collectedAt.Format(snapshotTimeFormat)+".json")
+	if err := helpers.WriteFileExclusive(name, b); err != nil {
+		return errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"assetID":  asset.ID,
+		"snapshot": name,
+	}).Debug("asset snapshot written")
+
+	return nil
+}
+
+// assetFiles returns the asset files in the assets directory with their modification time.
+func (s *Store) assetFiles() (map[string]time.Time, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, assetsDir, "*"))
+	if err != nil {
+		return nil, errors.Wrap(ErrAssetSource, err.Error())
+	}
+
+	modified := make(map[string]time.Time, len(files))
+
+	for _, file := range files {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, errors.Wrap(ErrAssetSource, err.Error())
+		}
+
+		modified[file] = info.ModTime()
+	}
+
+	return modified, nil
+}
+
+// assetFilesChanged returns true when asset files were changed, added or removed since the assets were loaded.
+func (s *Store) assetFilesChanged() (bool, error) {
+	files, err := s.assetFiles()
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(files) != len(s.files) {
+		return true, nil
+	}
+
+	for file, modified := range files {
+		if loaded, exists := s.files[file]; !exists || !loaded.Equal(modified) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// loadAssets reads the asset files in the assets directory.
+func (s *Store) loadAssets() error {
+	files, err := s.assetFiles()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for file := range files {
+		names = append(names, file)
+	}
+
+	// files are read in the name order, for the duplicate asset errors to be reported consistently.
+	sort.Strings(names)
+
+	assets := []*model.Asset{}
+	index := make(map[string]*model.Asset)
+	seen := map[string]string{}
+
+	for _, file := range names {
+		records, err := readAssetFile(file)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			asset, err := toAsset(record)
+			if err != nil {
+				return errors.Wrap(err, file)
+			}
+
+			if other, exists := seen[asset.ID]; exists {
+				return errors.Wrap(ErrAssetSource, "duplicate asset ID "+asset.ID+" in files: "+other+", "+file)
+			}
+
+			seen[asset.ID] = file
+			index[asset.ID] = asset
+
+			assets = append(assets, asset)
+		}
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].ID < assets[j].ID
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.assets = assets
+	s.index = index
+	s.files = files
+
+	return nil
+}
+
+// readAssetFile returns the asset records in the given file,
+//
+// the YAML decoder handles JSON files as well, since JSON is a subset of YAML.
+func readAssetFile(file string) ([]*assetRecord, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(ErrAssetSource, err.Error())
+	}
+
+	records := []*assetRecord{}
+	if err := yaml.Unmarshal(b, &records); err == nil {
+		return records, nil
+	}
+
+	record := &assetRecord{}
+	if err := yaml.Unmarshal(b, record); err != nil {
+		return nil, errors.Wrap(ErrAssetSource, file+": "+err.Error())
+	}
+
+	return []*assetRecord{record}, nil
+}
+
+func toAsset(record *assetRecord) (*model.Asset, error) {
+	id := strings.TrimSpace(record.ID)
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errors.Wrap(ErrAssetSource, err.Error()+": "+id)
+	}
+
+	asset := &model.Asset{
+		ID:               id,
+		BMCUsername:      record.BMCUsername,
+		BMCPassword:      record.BMCPassword,
+		BMCCredentialKey: record.BMCCredential,
+		Vendor:           record.Vendor,
+		Model:            record.Model,
+		Serial:           record.Serial,
+		Facility:         record.Facility,
+	}
+
+	if record.BMCAddress != "" {
+		bmcAddress, err := model.ParseBMCAddress(record.BMCAddress)
+		if err != nil {
+			return nil, errors.Wrap(ErrAssetSource, err.Error())
+		}
+
+		asset.BMCAddress = bmcAddress
+	}
+
+	return asset, nil
+}
+
+// copyAsset returns a copy of the asset attributes loaded from the asset files,
+// so callers updating the returned asset don't modify the loaded data.
+func copyAsset(asset *model.Asset, withBmcCredentials bool) *model.Asset {
+	c := &model.Asset{
+		ID:       asset.ID,
+		Vendor:   asset.Vendor,
+		Model:    asset.Model,
+		Serial:   asset.Serial,
+		Facility: asset.Facility,
+
+		BMCAddress: asset.BMCAddress,
+	}
+
+	if withBmcCredentials {
+		c.BMCUsername = asset.BMCUsername
+		c.BMCPassword = asset.BMCPassword
+		c.BMCCredentialKey = asset.BMCCredentialKey
+	}
+
+	return c
+}
@@ -0,0 +1,329 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/metal-toolbox/bmc-common"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
+	"github.com/metal-toolbox/alloy/internal/helpers"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+const testAssetsYAML = `
+- id: 7b8a090d-3900-4c45-89e1-041044d27402
+  bmc_address: 192.168.1.1
+  bmc_username: root
+  bmc_password: calvin
+  vendor: dell
+- id: a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2
+  bmc_address: fd00::2
+  bmc_username: ADMIN
+  bmc_password: ADMIN
+  vendor: supermicro
+`
+
+const testAssetJSON = `{
+	"id": "f0c8e4ac-5cce-4370-93ce-e0a5ec0e5c4c",
+	"bmc_address": "192.168.1.3",
+	"bmc_username": "root",
+	"bmc_password": "calvin",
+	"facility": "ac1"
+}`
+
+func testStore(t *testing.T) (*Store, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, assetsDir), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"rack-a.yaml":  testAssetsYAML,
+		"single.json":  testAssetJSON,
+		"README.notes": "ignored",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, assetsDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := New(context.TODO(), dir, true, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, dir
+}
+
+func Test_AssetByID(t *testing.T) {
+	store, _ := testStore(t)
+
+	asset, err := store.AssetByID(context.TODO(), "a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2", true)
+	require.Nil(t, err)
+	assert.Equal(t, "fd00::2", asset.BMCAddress.String())
+	assert.Equal(t, "ADMIN", asset.BMCUsername)
+	assert.Equal(t, "supermicro", asset.Vendor)
+
+	asset, err = store.AssetByID(context.TODO(), "f0c8e4ac-5cce-4370-93ce-e0a5ec0e5c4c", false)
+	require.Nil(t, err)
+	assert.Equal(t, "ac1", asset.Facility)
+	// the BMC address is included without the credentials.
+	assert.Equal(t, "192.168.1.3", asset.BMCAddress.String())
+	assert.Empty(t, asset.BMCPassword)
+
+	_, err = store.AssetByID(context.TODO(), "1ce1b6c1-1b1f-4b2a-8a3c-0c55f07c3f10", true)
+	assert.ErrorIs(t, err, ErrAssetNotFound)
+}
+
+func Test_AssetByID_AssetFilesChanged(t *testing.T) {
+	store, dir := testStore(t)
+
+	assetID := "f0c8e4ac-5cce-4370-93ce-e0a5ec0e5c4c"
+	file := filepath.Join(dir, assetsDir, "single.json")
+
+	info, err := os.Stat(file)
+	require.Nil(t, err)
+
+	// the asset file changed with the modification time unchanged is not re-read.
+	require.Nil(t, os.WriteFile(file, []byte("invalid: ["), 0o600))
+	require.Nil(t, os.Chtimes(file, info.ModTime(), info.ModTime()))
+
+	asset, err := store.AssetByID(context.TODO(), assetID, true)
+	require.Nil(t, err)
+	assert.Equal(t, "ac1", asset.Facility)
+
+	// the asset file modified is re-read.
+	updated := strings.Replace(testAssetJSON, `"ac1"`, `"ac2"`, 1)
+	require.Nil(t, os.WriteFile(file, []byte(updated), 0o600))
+	require.Nil(t, os.Chtimes(file, info.ModTime().Add(time.Second), info.ModTime().Add(time.Second)))
+
+	asset, err = store.AssetByID(context.TODO(), assetID, true)
+	require.Nil(t, err)
+	assert.Equal(t, "ac2", asset.Facility)
+
+	// the asset file added is read.
+	added := "id: 1ce1b6c1-1b1f-4b2a-8a3c-0c55f07c3f10\nbmc_address: 192.168.1.4\n"
+	require.Nil(t, os.WriteFile(filepath.Join(dir, assetsDir, "added.yaml"), []byte(added), 0o600))
+
+	asset, err = store.AssetByID(context.TODO(), "1ce1b6c1-1b1f-4b2a-8a3c-0c55f07c3f10", false)
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.4", asset.BMCAddress.String())
+
+	// the asset file removed is no longer listed.
+	require.Nil(t, os.Remove(file))
+
+	_, err = store.AssetByID(context.TODO(), assetID, true)
+	assert.ErrorIs(t, err, ErrAssetNotFound)
+}
+
+func Test_AssetsByOffsetLimit(t *testing.T) {
+	store, _ := testStore(t)
+
+	cases := []struct {
+		name     string
+		offset   int
+		limit    int
+		expected []string
+	}{
+		{
+			"first page",
+			1,
+			2,
+			[]string{"7b8a090d-3900-4c45-89e1-041044d27402", "a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2"},
+		},
+		{
+			"last page",
+			2,
+			2,
+			[]string{"f0c8e4ac-5cce-4370-93ce-e0a5ec0e5c4c"},
+		},
+		{
+			"beyond last page",
+			3,
+			2,
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assets, total, err := store.AssetsByOffsetLimit(context.TODO(), tc.offset, tc.limit)
+			require.Nil(t, err)
+			assert.Equal(t, 3, total)
+
+			var got []string
+			for _, asset := range assets {
+				got = append(got, asset.ID)
+			}
+
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func Test_InvalidAssetFile(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{"invalid ID", "id: foo\nbmc_address: 192.168.1.1\n"},
+		{"invalid address", "id: 7b8a090d-3900-4c45-89e1-041044d27402\nbmc_address: 192.168.1\n"},
+		{"duplicate ID", testAssetsYAML + "- id: 7b8a090d-3900-4c45-89e1-041044d27402\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(dir, assetsDir), 0o750); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.WriteFile(filepath.Join(dir, assetsDir, "assets.yaml"), []byte(tc.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := New(context.TODO(), dir, true, logrus.New())
+			assert.ErrorIs(t, err, ErrAssetSource)
+		})
+	}
+}
+
+func Test_AssetUpdate(t *testing.T) {
+	store, dir := testStore(t)
+
+	asset, err := store.AssetByID(context.TODO(), "7b8a090d-3900-4c45-89e1-041044d27402", true)
+	require.Nil(t, err)
+
+	asset.Inventory = &common.Device{Common: common.Common{Vendor: "dell", Serial: "abc123"}}
+	asset.Errors = map[string]string{"bios": "not supported"}
+
+	err = store.AssetUpdate(context.TODO(), asset)
+	require.Nil(t, err)
+
+	snapshots, err := filepath.Glob(filepath.Join(dir, snapshotsDir, asset.ID, "*.json"))
+	require.Nil(t, err)
+	require.Len(t, snapshots, 1)
+
+	// a snapshot written within the same second does not overwrite the previous snapshot.
+	err = store.AssetUpdate(context.TODO(), asset)
+	require.Nil(t, err)
+
+	written, err := filepath.Glob(filepath.Join(dir, snapshotsDir, asset.ID, "*.json"))
+	require.Nil(t, err)
+	assert.Len(t, written, 2)
+
+	// an existing snapshot is not replaced.
+	err = helpers.WriteFileExclusive(snapshots[0], []byte(`{}`))
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	b, err := os.ReadFile(snapshots[0])
+	require.Nil(t, err)
+
+	got := &model.AssetSnapshot{}
+	require.Nil(t, json.Unmarshal(b, got))
+
+	assert.Equal(t, asset.ID, got.ID)
+	assert.Equal(t, "abc123", got.Inventory.Serial)
+	assert.Equal(t, "not supported", got.Errors["bios"])
+	assert.NotContains(t, string(b), "calvin")
+
+	// the listed asset includes the time of the latest snapshot
+	assets, _, err := store.AssetsByOffsetLimit(context.TODO(), 1, 10)
+	require.Nil(t, err)
+
+	for _, listed := range assets {
+		if listed.ID == asset.ID {
+			assert.WithinDuration(t, time.Now(), listed.LastCollectedAt, time.Minute)
+		}
+	}
+
+	// the listed assets don't include the collection status when not required.
+	store.collectionStatus = false
+
+	assets, _, err = store.AssetsByOffsetLimit(context.TODO(), 1, 10)
+	require.Nil(t, err)
+
+	for _, listed := range assets {
+		assert.True(t, listed.LastCollectedAt.IsZero())
+	}
+
+	err = store.AssetUpdate(context.TODO(), &model.Asset{ID: "foo"})
+	assert.ErrorIs(t, err, ErrAssetUpdate)
+}
+
+func Test_lastCollection(t *testing.T) {
+	store, _ := testStore(t)
+
+	assetID := "a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2"
+
+	update := func(kind model.CollectorError) {
+		t.Helper()
+
+		asset := &model.Asset{ID: assetID, Errors: map[string]string{}}
+		if kind != "" {
+			asset.Errors[string(kind)] = "error"
+		}
+
+		require.Nil(t, store.AssetUpdate(context.TODO(), asset))
+	}
+
+	update("")
+
+	collectedAt, failed := store.lastCollection(assetID)
+	assert.False(t, failed)
+	assert.False(t, collectedAt.IsZero())
+
+	// the collection skipped on an open BMC circuit is not recorded as failed
+	update(outofband.CircuitOpenError)
+
+	lastCollectedAt, failed := store.lastCollection(assetID)
+	assert.False(t, failed)
+	assert.Equal(t, collectedAt, lastCollectedAt)
+
+	// the last collection remains failed when a following collection is skipped
+	update(outofband.LoginError)
+	update(outofband.CircuitOpenError)
+
+	lastCollectedAt, failed = store.lastCollection(assetID)
+	assert.True(t, failed)
+	assert.Equal(t, collectedAt, lastCollectedAt)
+}
+
+func Test_parseSnapshotTime(t *testing.T) {
+	testcases := []struct {
+		name     string
+		file     string
+		expected time.Time
+		err      bool
+	}{
+		{"nanoseconds", "20240603T223000.000000123Z.json", time.Date(2024, 6, 3, 22, 30, 0, 123, time.UTC), false},
+		{"seconds, written by earlier releases", "20240603T223000Z.json", time.Date(2024, 6, 3, 22, 30, 0, 0, time.UTC), false},
+		{"invalid", "latest.json", time.Time{}, true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSnapshotTime(tc.file)
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			assert.True(t, tc.expected.Equal(got))
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"os"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
@@ -43,7 +45,7 @@ func (r *Store) createUpdateServerAttributes(ctx context.Context, server *fleetd
 	inventoryAttrs := attributeByNamespace(serverVendorAttributeNS, server.Attributes)
 	if inventoryAttrs == nil {
 		// create if none exists
-		_, err = r.CreateAttributes(ctx, server.UUID, *deviceVendorAttributes)
+		_, err = r.writer.CreateAttributes(ctx, server.UUID, *deviceVendorAttributes)
 		return err
 	}
 
@@ -53,7 +55,7 @@ func (r *Store) createUpdateServerAttributes(ctx context.Context, server *fleetd
 		// update vendor data since it seems to be invalid
 		r.logger.Warn("server vendor attributes data invalid, updating..")
 
-		_, err = r.UpdateAttributes(ctx, server.UUID, serverVendorAttributeNS, deviceVendorDataBytes)
+		_, err = r.writer.UpdateAttributes(ctx, server.UUID, serverVendorAttributeNS, deviceVendorDataBytes)
 
 		return err
 	}
@@ -65,7 +67,7 @@ func (r *Store) createUpdateServerAttributes(ctx context.Context, server *fleetd
 			return err
 		}
 
-		_, err = r.UpdateAttributes(ctx, server.UUID, serverVendorAttributeNS, updateBytes)
+		_, err = r.writer.UpdateAttributes(ctx, server.UUID, serverVendorAttributeNS, updateBytes)
 
 		return err
 	}
@@ -90,7 +92,7 @@ func (r *Store) publishUEFIVars(ctx context.Context, serverID uuid.UUID, asset *
 		Data:      []byte(vars),
 	}
 
-	_, err := r.CreateVersionedAttributes(ctx, serverID, va)
+	_, err := r.writer.CreateVersionedAttributes(ctx, serverID, va)
 
 	return err
 }
@@ -222,13 +224,13 @@ func (r *Store) createUpdateServerMetadataAttributes(ctx context.Context, server
 	// current asset metadata has no attributes set and no metadata attribute, create one
 	if _, ok := asset.Metadata[ssMetadataAttributeFound]; !ok {
 		r.logger.WithField("server.id", serverID.String()).Debug("creating metadata attributes")
-		_, err := r.CreateAttributes(ctx, serverID, attribute)
+		_, err := r.writer.CreateAttributes(ctx, serverID, attribute)
 		return err
 	}
 
 	r.logger.WithField("server.id", serverID.String()).Debug("updating metadata attributes")
 	// update vendor, model attributes
-	_, err := r.UpdateAttributes(ctx, serverID, serverMetadataAttributeNS, metadata)
+	_, err := r.writer.UpdateAttributes(ctx, serverID, serverMetadataAttributeNS, metadata)
 
 	return err
 }
@@ -245,7 +247,7 @@ func (r *Store) createUpdateServerBIOSConfiguration(ctx context.Context, serverI
 		Data:      bc,
 	}
 
-	_, err = r.CreateVersionedAttributes(ctx, serverID, va)
+	_, err = r.writer.CreateVersionedAttributes(ctx, serverID, va)
 
 	return err
 }
@@ -260,7 +262,7 @@ func (r *Store) createUpdateServerBMCErrorAttributes(ctx context.Context, server
 		}
 
 		// server has bmc errors registered, update the attributes to purge existing errors
-		_, err := r.UpdateAttributes(ctx, serverID, serverBMCErrorsAttributeNS, []byte(`{}`))
+		_, err := r.writer.UpdateAttributes(ctx, serverID, serverBMCErrorsAttributeNS, []byte(`{}`))
 
 		return err
 	}
@@ -278,7 +280,7 @@ func (r *Store) createUpdateServerBMCErrorAttributes(ctx context.Context, server
 
 	// 2. current data has no BMC error attributes object, create
 	if current == nil || len(current.Data) == 0 {
-		_, err = r.CreateAttributes(ctx, serverID, attribute)
+		_, err = r.writer.CreateAttributes(ctx, serverID, attribute)
 		return err
 	}
 
@@ -296,7 +298,7 @@ func (r *Store) createUpdateServerBMCErrorAttributes(ctx context.Context, server
 	}
 
 	// update vendor, model attributes
-	_, err = r.UpdateAttributes(ctx, serverID, serverBMCErrorsAttributeNS, newData)
+	_, err = r.writer.UpdateAttributes(ctx, serverID, serverBMCErrorsAttributeNS, newData)
 
 	return err
 }
@@ -436,6 +438,26 @@ func attributeByNamespace(ns string, attributes []fleetdbapi.Attributes) *fleetd
 	return nil
 }
 
+// bmcCredentialKey returns the key of the BMC credential in the secret provider from the server BMC attribute,
+// an empty string is returned when the credential is not referenced by key.
+func bmcCredentialKey(server *fleetdbapi.Server) string {
+	if server == nil {
+		return ""
+	}
+
+	attribute := attributeByNamespace(bmcAttributeNamespace, server.Attributes)
+	if attribute == nil {
+		return ""
+	}
+
+	data := map[string]string{}
+	if err := json.Unmarshal(attribute.Data, &data); err != nil {
+		return ""
+	}
+
+	return data[bmcCredentialAttributeKey]
+}
+
 // serverAttributes parses the server service attribute data
 // and returns a map containing the bmc address, server serial, vendor, model attributes
 // and optionally the BMC address and attributes.
@@ -538,3 +560,180 @@ func serverMetadataAttributes(attributes []fleetdbapi.Attributes) (map[string]st
 
 	return metadata, nil
 }
+
+// selectorAttributeListParams returns the server list attribute parameters for the asset selector.
+func selectorAttributeListParams(selector *model.AssetSelector) []fleetdbapi.AttributeListParams {
+	if selector.IsEmpty() {
+		return nil
+	}
+
+	params := []fleetdbapi.AttributeListParams{}
+
+	vendorAttribute := func(key string, operator fleetdbapi.OperatorComparitorType, value string) fleetdbapi.AttributeListParams {
+		return fleetdbapi.AttributeListParams{
+			Namespace: serverVendorAttributeNS,
+			Keys:      []string{key},
+			Operator:  operator,
+			Value:     value,
+		}
+	}
+
+	if selector.Vendor != "" {
+		params = append(params, vendorAttribute(serverVendorAttributeKey, fleetdbapi.OperatorComparitorEqual, selector.Vendor))
+	}
+
+	if selector.Model != "" {
+		params = append(params, vendorAttribute(serverModelAttributeKey, fleetdbapi.OperatorComparitorEqual, selector.Model))
+	}
+
+	// a prefix with LIKE wildcard characters is applied by the caller.
+	if selector.SerialPrefix != "" && !strings.ContainsAny(selector.SerialPrefix, "%_") {
+		params = append(params, vendorAttribute(serverSerialAttributeKey, fleetdbapi.OperatorComparitorLike, selector.SerialPrefix+"%"))
+	}
+
+	for _, attr := range selector.Attributes {
+		// the separator of the attribute query parameter cannot be escaped, these are applied by the caller.
+		if strings.Contains(attr.Namespace+attr.Key+attr.Value, "~") {
+			continue
+		}
+
+		param := fleetdbapi.AttributeListParams{Namespace: attr.Namespace}
+
+		if attr.Key != "" {
+			param.Keys = strings.Split(attr.Key, ".")
+		}
+
+		if attr.Value != "" {
+			param.Operator = fleetdbapi.OperatorComparitorEqual
+			param.Value = attr.Value
+		}
+
+		params = append(params, param)
+	}
+
+	return params
+}
+
+// attributesByNamespace returns the server attribute data by namespace.
+func attributesByNamespace(server *fleetdbapi.Server) map[string]json.RawMessage {
+	attributes := make(map[string]json.RawMessage, len(server.Attributes))
+	for _, attribute := range server.Attributes {
+		attributes[attribute.Namespace] = attribute.Data
+	}
+
+	return attributes
+}
+
+// lastCollectedAt returns the most recent time the server attributes, versioned attributes registered by Alloy were reported,
+// a zero time is returned when the server has none.
+func lastCollectedAt(server *fleetdbapi.Server) time.Time {
+	var last time.Time
+
+	latest := func(ts ...time.Time) {
+		for _, t := range ts {
+			if t.After(last) {
+				last = t
+			}
+		}
+	}
+
+	for _, attribute := range server.Attributes {
+		if strings.HasPrefix(attribute.Namespace, fleetDBNSPrefix+".") {
+			latest(attribute.UpdatedAt)
+		}
+	}
+
+	// versioned attributes are tallied when the data reported is unchanged, the last reported time is updated.
+	for _, attribute := range server.VersionedAttributes {
+		if strings.HasPrefix(attribute.Namespace, fleetDBNSPrefix+".") {
+			latest(attribute.CreatedAt, attribute.LastReportedAt)
+		}
+	}
+
+	return last
+}
+
+const (
+	collectionStatusSuccess = "success"
+	collectionStatusFailed  = "failed"
+)
+
+// collectionStatus is the collection status attribute data registered for a server on each collection.
+type collectionStatus struct {
+	// LastAttemptAt is the time of the last collection.
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	// LastSuccessAt is the time the inventory was last collected and registered, nil when never collected.
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	// Status is the result of the last collection - success or failed.
+	Status string `json:"status"`
+}
+
+// newCollectionStatus returns the collection status for a collection at the given time,
+// the last success time is retained from the current status when the collection failed.
+func newCollectionStatus(current *collectionStatus, success bool, now time.Time) *collectionStatus {
+	status := &collectionStatus{LastAttemptAt: now, Status: collectionStatusFailed}
+
+	if success {
+		status.Status = collectionStatusSuccess
+		status.LastSuccessAt = &now
+
+		return status
+	}
+
+	if current != nil {
+		status.LastSuccessAt = current.LastSuccessAt
+	}
+
+	return status
+}
+
+// serverCollectionStatus returns the collection status registered for the server, nil when none is registered.
+func serverCollectionStatus(ns string, attributes []fleetdbapi.Attributes) *collectionStatus {
+	attribute := attributeByNamespace(ns, attributes)
+	if attribute == nil || len(attribute.Data) == 0 {
+		return nil
+	}
+
+	status := &collectionStatus{}
+	if err := json.Unmarshal(attribute.Data, status); err != nil {
+		return nil
+	}
+
+	return status
+}
+
+// createUpdateServerCollectionStatus creates/updates the collection status attribute of a server.
+func (r *Store) createUpdateServerCollectionStatus(ctx context.Context, server *fleetdbapi.Server, success bool) error {
+	ns := serverCollectionStatusNS(r.appKind)
+	current := serverCollectionStatus(ns, server.Attributes)
+
+	data, err := json.Marshal(newCollectionStatus(current, success, time.Now().UTC()))
+	if err != nil {
+		return err
+	}
+
+	if attributeByNamespace(ns, server.Attributes) == nil {
+		_, err = r.writer.CreateAttributes(ctx, server.UUID, fleetdbapi.Attributes{Namespace: ns, Data: data})
+		return err
+	}
+
+	_, err = r.writer.UpdateAttributes(ctx, server.UUID, ns, data)
+
+	return err
+}
+
+// setCollectionStatus sets the asset last collected time, last collection result from the server collection status,
+// servers without a collection status retain the last collected time from the attributes reported.
+func (r *Store) setCollectionStatus(asset *model.Asset, server *fleetdbapi.Server) {
+	status := serverCollectionStatus(serverCollectionStatusNS(r.appKind), server.Attributes)
+	if status == nil {
+		return
+	}
+
+	asset.LastCollectFailed = status.Status == collectionStatusFailed
+
+	asset.LastCollectedAt = time.Time{}
+	if status.LastSuccessAt != nil {
+		asset.LastCollectedAt = *status.LastSuccessAt
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -20,6 +21,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/metal-toolbox/alloy/internal/app"
 	"github.com/metal-toolbox/alloy/internal/fixtures"
 	"github.com/metal-toolbox/alloy/internal/model"
 )
@@ -47,6 +49,7 @@ func testStoreInstance(t *testing.T, mockURL string) *Store {
 		logger:                       logrus.New(),
 		slugs:                        fixtures.FleetDBSlugMap(),
 		Client:                       mockClient,
+		writer:                       mockClient,
 		attributeNS:                  serverComponentAttributeNS(model.AppKindOutOfBand),
 		firmwareVersionedAttributeNS: serverComponentFirmwareNS(model.AppKindOutOfBand),
 		statusVersionedAttributeNS:   serverComponentStatusNS(model.AppKindOutOfBand),
@@ -453,6 +456,281 @@ func Test_FleetDB_CreateUpdateServerComponents_ObjectsAdded(t *testing.T) {
 	}
 }
 
+func Test_FleetDB_CreateUpdateServerComponents_ObjectsRemoved(t *testing.T) {
+	serverID, _ := uuid.Parse(fixtures.TestserverID_Dell_fc167440)
+
+	// asset device fixture returned by the inventory collector
+	device := &model.Asset{
+		ID:        serverID.String(),
+		Vendor:    "dell",
+		Inventory: fixtures.CopyDevice(fixtures.R6515_fc167440),
+	}
+
+	// remove a DIMM from the collected inventory
+	removedDIMM := device.Inventory.Memory[len(device.Inventory.Memory)-1]
+	device.Inventory.Memory = device.Inventory.Memory[:len(device.Inventory.Memory)-1]
+
+	var deleted bool
+
+	handler := http.NewServeMux()
+	// get components query
+	handler.HandleFunc(
+		fmt.Sprintf("/api/v1/servers/%s/components", serverID.String()),
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write(fixtures.FleetDBAPIR6515Components_fc167440_JSON())
+			case http.MethodDelete:
+				deleted = true
+
+				_, _ = w.Write([]byte(`{}`))
+			case http.MethodPost:
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				gotRetained := []*fleetdbapi.ServerComponent{}
+				if err := json.Unmarshal(b, &gotRetained); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.True(t, deleted, "expected components to be deleted before being re-created")
+				assert.Equal(t, len(fixtures.FleetDBAPIR6515Components_fc167440)-1, len(gotRetained))
+				assert.Nil(t, componentBySlugSerial(common.SlugPhysicalMem, removedDIMM.Serial, gotRetained))
+
+				_, _ = w.Write([]byte(`{}`))
+			default:
+				t.Fatal("unexpected request method: " + r.Method)
+			}
+		},
+	)
+
+	// get firmwares query
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				w.Header().Set("Content-Type", "application/json")
+
+				_, _ = w.Write([]byte(`{}`))
+			default:
+				t.Fatal("expected GET request, got: " + r.Method)
+			}
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	p := testStoreInstance(t, mock.URL)
+	p.config = &app.FleetDBAPIOptions{
+		ComponentRemoval: app.ComponentRemovalOptions{
+			Enabled:     true,
+			MaxCount:    app.DefaultComponentRemovalMaxCount,
+			MaxFraction: app.DefaultComponentRemovalMaxFraction,
+		},
+	}
+
+	err := p.createUpdateServerComponents(context.TODO(), serverID, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, deleted)
+}
+
+func Test_FleetDB_CreateUpdateServerComponents_RemovalRestored(t *testing.T) {
+	serverID, _ := uuid.Parse(fixtures.TestserverID_Dell_fc167440)
+
+	device := &model.Asset{
+		ID:        serverID.String(),
+		Vendor:    "dell",
+		Inventory: fixtures.CopyDevice(fixtures.R6515_fc167440),
+	}
+
+	// remove a DIMM from the collected inventory
+	device.Inventory.Memory = device.Inventory.Memory[:len(device.Inventory.Memory)-1]
+
+	var restored int
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		fmt.Sprintf("/api/v1/servers/%s/components", serverID.String()),
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write(fixtures.FleetDBAPIR6515Components_fc167440_JSON())
+			case http.MethodDelete:
+				_, _ = w.Write([]byte(`{}`))
+			case http.MethodPost:
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got := []*fleetdbapi.ServerComponent{}
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Fatal(err)
+				}
+
+				// the retained components fail to be re-created
+				if len(got) < len(fixtures.FleetDBAPIR6515Components_fc167440) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				restored = len(got)
+
+				_, _ = w.Write([]byte(`{}`))
+			default:
+				t.Fatal("unexpected request method: " + r.Method)
+			}
+		},
+	)
+
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	p := testStoreInstance(t, mock.URL)
+	p.config = &app.FleetDBAPIOptions{
+		ComponentRemoval: app.ComponentRemovalOptions{
+			Enabled:     true,
+			MaxCount:    app.DefaultComponentRemovalMaxCount,
+			MaxFraction: app.DefaultComponentRemovalMaxFraction,
+		},
+	}
+
+	err := p.createUpdateServerComponents(context.TODO(), serverID, device)
+	assert.ErrorIs(t, err, model.ErrInventoryQuery)
+
+	// the components listed before the delete are restored.
+	assert.Equal(t, len(fixtures.FleetDBAPIR6515Components_fc167440), restored)
+}
+
+func Test_FleetDB_ServerComponents(t *testing.T) {
+	serverID, _ := uuid.Parse(fixtures.TestserverID_Dell_fc167440)
+
+	components := fixtures.FleetDBAPIR6515Components_fc167440
+
+	testcases := []struct {
+		name      string
+		pageSize  int
+		total     int
+		wantCount int
+		wantErr   error
+	}{
+		{
+			"components listed across pages",
+			2,
+			len(components),
+			len(components),
+			nil,
+		},
+		{
+			"components listed in a single page",
+			len(components),
+			len(components),
+			len(components),
+			nil,
+		},
+		{
+			"components listed below the total",
+			2,
+			len(components) + 1,
+			0,
+			ErrComponentsIncomplete,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var deleted bool
+
+			handler := http.NewServeMux()
+			handler.HandleFunc(
+				fmt.Sprintf("/api/v1/servers/%s/components", serverID.String()),
+				func(w http.ResponseWriter, r *http.Request) {
+					switch r.Method {
+					case http.MethodGet:
+						assert.Equal(t, strconv.Itoa(fleetdbapi.MaxPaginationSize), r.URL.Query().Get("limit"))
+
+						page, err := strconv.Atoi(r.URL.Query().Get("page"))
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						// the server returns pages of pageSize regardless of the limit requested
+						start := min((page-1)*tc.pageSize, len(components))
+						end := min(start+tc.pageSize, len(components))
+
+						b, err := json.Marshal(fleetdbapi.ServerResponse{
+							Page:             page,
+							PageSize:         tc.pageSize,
+							TotalRecordCount: int64(tc.total),
+							Records:          components[start:end],
+						})
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						w.Header().Set("Content-Type", "application/json")
+						_, _ = w.Write(b)
+					case http.MethodDelete:
+						deleted = true
+						_, _ = w.Write([]byte(`{}`))
+					default:
+						_, _ = w.Write([]byte(`{}`))
+					}
+				},
+			)
+
+			mock := httptest.NewServer(handler)
+			defer mock.Close()
+
+			p := testStoreInstance(t, mock.URL)
+
+			got, err := p.serverComponents(context.TODO(), serverID)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Nil(t, got)
+
+				// components are not removed when the current components are incomplete.
+				device := &model.Asset{
+					ID:        serverID.String(),
+					Vendor:    "dell",
+					Inventory: fixtures.CopyDevice(fixtures.R6515_fc167440),
+				}
+				device.Inventory.Memory = device.Inventory.Memory[:len(device.Inventory.Memory)-1]
+
+				p.config = &app.FleetDBAPIOptions{
+					ComponentRemoval: app.ComponentRemovalOptions{
+						Enabled:     true,
+						MaxCount:    app.DefaultComponentRemovalMaxCount,
+						MaxFraction: app.DefaultComponentRemovalMaxFraction,
+					},
+				}
+
+				err = p.createUpdateServerComponents(context.TODO(), serverID, device)
+				assert.ErrorIs(t, err, model.ErrInventoryQuery)
+				assert.False(t, deleted)
+
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Len(t, got, tc.wantCount)
+		})
+	}
+}
+
 func Test_FleetDB_CreateUpdateServerAttributes_Create(t *testing.T) {
 	// test: createUpdateServerAttributes creates server attributes when its undefined in server service
 	serverID, _ := uuid.Parse(fixtures.TestserverID_Dell_fc167440)
@@ -1121,3 +1399,146 @@ func TestVendorDataUpdate(t *testing.T) {
 		})
 	}
 }
+
+func Test_selectorAttributeListParams(t *testing.T) {
+	assert.Nil(t, selectorAttributeListParams(nil))
+	assert.Nil(t, selectorAttributeListParams(&model.AssetSelector{}))
+
+	selector := &model.AssetSelector{
+		Vendor:       "dell",
+		Model:        "r6515",
+		SerialPrefix: "ABC",
+		Attributes: []model.AttributeSelector{
+			{Namespace: "sh.hollow.rack"},
+			{Namespace: "sh.hollow.rack", Key: "location.row", Value: "4"},
+			// the query parameter separator cannot be escaped
+			{Namespace: "sh.hollow.rack", Key: "name", Value: "a~b"},
+		},
+		CollectedAge: time.Hour,
+	}
+
+	expected := []fleetdbapi.AttributeListParams{
+		{Namespace: serverVendorAttributeNS, Keys: []string{"vendor"}, Operator: fleetdbapi.OperatorComparitorEqual, Value: "dell"},
+		{Namespace: serverVendorAttributeNS, Keys: []string{"model"}, Operator: fleetdbapi.OperatorComparitorEqual, Value: "r6515"},
+		{Namespace: serverVendorAttributeNS, Keys: []string{"serial"}, Operator: fleetdbapi.OperatorComparitorLike, Value: "ABC%"},
+		{Namespace: "sh.hollow.rack"},
+		{Namespace: "sh.hollow.rack", Keys: []string{"location", "row"}, Operator: fleetdbapi.OperatorComparitorEqual, Value: "4"},
+	}
+
+	assert.Equal(t, expected, selectorAttributeListParams(selector))
+}
+
+func Test_lastCollectedAt(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	server := &fleetdbapi.Server{
+		Attributes: []fleetdbapi.Attributes{
+			{Namespace: bmcAttributeNamespace, UpdatedAt: ts.Add(time.Hour)},
+			{Namespace: serverVendorAttributeNS, UpdatedAt: ts.Add(-time.Hour)},
+		},
+		VersionedAttributes: []fleetdbapi.VersionedAttributes{
+			{Namespace: serverBIOSConfigNS(model.AppKindOutOfBand), CreatedAt: ts.Add(-2 * time.Hour), LastReportedAt: ts},
+		},
+	}
+
+	assert.Equal(t, ts, lastCollectedAt(server))
+	assert.True(t, lastCollectedAt(&fleetdbapi.Server{}).IsZero())
+}
+
+func Test_newCollectionStatus(t *testing.T) {
+	lastSuccess := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	now := lastSuccess.Add(time.Hour)
+
+	current := &collectionStatus{LastAttemptAt: lastSuccess, LastSuccessAt: &lastSuccess, Status: collectionStatusSuccess}
+
+	// success
+	status := newCollectionStatus(current, true, now)
+	assert.Equal(t, &collectionStatus{LastAttemptAt: now, LastSuccessAt: &now, Status: collectionStatusSuccess}, status)
+
+	// failure retains the last success time
+	status = newCollectionStatus(current, false, now)
+	assert.Equal(t, &collectionStatus{LastAttemptAt: now, LastSuccessAt: &lastSuccess, Status: collectionStatusFailed}, status)
+
+	// failure without a previous status
+	status = newCollectionStatus(nil, false, now)
+	assert.Equal(t, &collectionStatus{LastAttemptAt: now, Status: collectionStatusFailed}, status)
+}
+
+func Test_FleetDB_CreateUpdateServerCollectionStatus(t *testing.T) {
+	serverID, _ := uuid.Parse(fixtures.TestserverID_Dell_fc167440)
+	ns := serverCollectionStatusNS(model.AppKindOutOfBand)
+	lastSuccess := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	testcases := []struct {
+		name              string
+		attributes        []fleetdbapi.Attributes
+		success           bool
+		expectCreate      bool
+		expectLastSuccess bool
+	}{
+		{
+			"status created on success",
+			nil,
+			true,
+			true,
+			true,
+		},
+		{
+			"status created on failure",
+			nil,
+			false,
+			true,
+			false,
+		},
+		{
+			"status updated on failure retains the last success",
+			[]fleetdbapi.Attributes{
+				{Namespace: ns, Data: []byte(`{"last_attempt_at":"2024-01-02T15:04:05Z","last_success_at":"2024-01-02T15:04:05Z","status":"success"}`)},
+			},
+			false,
+			false,
+			true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := testStoreInstance(t, "http://127.0.0.1:0")
+			p.appKind = model.AppKindOutOfBand
+			p.dryRun = newDryRunWriter(io.Discard)
+			p.writer = p.dryRun
+
+			server := &fleetdbapi.Server{UUID: serverID, Attributes: tc.attributes}
+
+			require.Nil(t, p.createUpdateServerCollectionStatus(context.TODO(), server, tc.success))
+
+			plan := p.dryRun.plans[serverID]
+			require.NotNil(t, plan)
+
+			written := plan.AttributesUpdated
+			if tc.expectCreate {
+				written = plan.AttributesCreated
+			}
+
+			require.Len(t, written, 1)
+			assert.Equal(t, ns, written[0].Namespace)
+
+			// the written status is read back into the asset
+			server.Attributes = []fleetdbapi.Attributes{{Namespace: ns, Data: written[0].Data}}
+			asset := &model.Asset{LastCollectedAt: lastSuccess.Add(time.Hour)}
+
+			p.setCollectionStatus(asset, server)
+
+			assert.Equal(t, !tc.success, asset.LastCollectFailed)
+
+			switch {
+			case tc.success:
+				assert.WithinDuration(t, time.Now(), asset.LastCollectedAt, time.Minute)
+			case tc.expectLastSuccess:
+				assert.Equal(t, lastSuccess, asset.LastCollectedAt)
+			default:
+				assert.True(t, asset.LastCollectedAt.IsZero())
+			}
+		})
+	}
+}
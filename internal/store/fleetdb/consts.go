@@ -16,6 +16,10 @@ const (
 	// fleetdb service BMC address attribute key found under the bmcAttributeNamespace
 	bmcIPAddressAttributeKey = "address"
 
+	// fleetdb service BMC credential key attribute found under the bmcAttributeNamespace,
+	// when set the BMC credential is resolved from the secret provider instead of the fleetdb credential.
+	bmcCredentialAttributeKey = "credential"
+
 	// fleetdb namespace prefix the data is stored in.
 	fleetDBNSPrefix = "sh.hollow.alloy"
 
@@ -61,3 +65,8 @@ func serverComponentFirmwareNS(appKind model.AppKind) string {
 func serverComponentStatusNS(appKind model.AppKind) string {
 	return fmt.Sprintf("%s.%s.status", fleetDBNSPrefix, appKind)
 }
+
+// serverCollectionStatusNS returns the namespace the server collection status is stored in.
+func serverCollectionStatusNS(appKind model.AppKind) string {
+	return fmt.Sprintf("%s.%s.collection_status", fleetDBNSPrefix, appKind)
+}
@@ -0,0 +1,454 @@
+package fleetdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+	"github.com/pkg/errors"
+	r3diff "github.com/r3labs/diff/v3"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/metrics"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+var (
+	ErrInventoryDiff = errors.New("error comparing inventory")
+)
+
+// InventoryDiff is the component level difference between two inventories of a server.
+type InventoryDiff struct {
+	Added      []*ComponentDiff `json:"added,omitempty"`
+	Removed    []*ComponentDiff `json:"removed,omitempty"`
+	Changed    []*ComponentDiff `json:"changed,omitempty"`
+	BIOSConfig []*Change        `json:"bios_config,omitempty"`
+}
+
+// ComponentDiff identifies a component added, removed or changed, along with the changes in its attributes.
+type ComponentDiff struct {
+	Slug    string    `json:"slug"`
+	Serial  string    `json:"serial"`
+	Vendor  string    `json:"vendor,omitempty"`
+	Model   string    `json:"model,omitempty"`
+	Changes []*Change `json:"changes,omitempty"`
+}
+
+// Change is a change in a component attribute or BIOS setting,
+// From is nil for values added and To is nil for values removed.
+type Change struct {
+	Path string `json:"path"`
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+// Empty returns true when no differences were identified.
+func (d *InventoryDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.BIOSConfig) == 0
+}
+
+// NewOffline returns a fleetdb Store that is not connected to a fleetdb API,
+// it can only be used to convert and compare inventories with the DiffInventory method.
+//
+// The component types are the ones registered in fleetdb by Alloy.
+func NewOffline(appKind model.AppKind, logger *logrus.Logger) *Store {
+	return &Store{
+		appKind:                      appKind,
+		logger:                       logger,
+		config:                       &app.FleetDBAPIOptions{},
+		slugs:                        offlineSlugs(),
+		firmwares:                    make(map[string][]*fleetdbapi.ComponentFirmwareVersion),
+		attributeNS:                  serverComponentAttributeNS(appKind),
+		firmwareVersionedAttributeNS: serverComponentFirmwareNS(appKind),
+		statusVersionedAttributeNS:   serverComponentStatusNS(appKind),
+	}
+}
+
+// NewReadOnly returns a fleetdb Store that queries the fleetdb API and does not write to it,
+// it can be used to compare inventories with the inventory registered in fleetdb with the DiffStoredInventory method.
+//
+// The component types are not created when none are registered, writes are recorded in a plan that is discarded.
+func NewReadOnly(ctx context.Context, appKind model.AppKind, cfg *app.FleetDBAPIOptions, logger *logrus.Logger) (*Store, error) {
+	apiclient, err := NewFleetDBAPIClient(ctx, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newStore(apiclient, appKind, cfg, logger)
+	s.dryRun = newDryRunWriter(io.Discard)
+	s.writer = s.dryRun
+
+	if err := s.cacheServerComponentTypes(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(s.slugs) == 0 {
+		s.slugs = offlineSlugs()
+	}
+
+	return s, nil
+}
+
+// offlineSlugs returns the component types lookup map for the component types registered in fleetdb by Alloy,
+// the component type IDs are not set.
+func offlineSlugs() map[string]*fleetdbapi.ServerComponentType {
+	slugs := make(map[string]*fleetdbapi.ServerComponentType, len(componentSlugs))
+
+	for _, slug := range componentSlugs {
+		slugs[strings.ToLower(slug)] = &fleetdbapi.ServerComponentType{
+			Name: slug,
+			Slug: strings.ToLower(slug),
+		}
+	}
+
+	return slugs
+}
+
+// DiffInventory returns the differences between the before and after inventory, BIOS configuration of an asset.
+//
+// Components are matched by their slug and serial, as when changes are registered in fleetdb.
+func (r *Store) DiffInventory(ctx context.Context, before, after *model.Asset) (*InventoryDiff, error) {
+	beforeComponents, err := r.inventoryComponents(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterComponents, err := r.inventoryComponents(after)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.diffInventory(ctx, beforeComponents, afterComponents, before.BiosConfig, after.BiosConfig)
+}
+
+// DiffStoredInventory returns the differences between the inventory, BIOS configuration registered in fleetdb
+// for the asset and the given asset inventory, BIOS configuration.
+func (r *Store) DiffStoredInventory(ctx context.Context, asset *model.Asset) (*InventoryDiff, error) {
+	ctx, span := otel.Tracer(pkgName).Start(ctx, "fleetdbapi.DiffStoredInventory")
+	defer span.End()
+
+	serverID, err := uuid.Parse(asset.ID)
+	if err != nil {
+		return nil, errors.Wrap(ErrInventoryDiff, "invalid asset ID: "+asset.ID)
+	}
+
+	current, err := r.serverComponents(ctx, serverID)
+	if err != nil {
+		metrics.FleetDBAPIQueryErrorCount.With(stageLabel).Inc()
+		span.SetStatus(codes.Error, "GetComponents() failed")
+
+		return nil, errors.Wrap(model.ErrInventoryQuery, err.Error())
+	}
+
+	currentComponents := componentPtrSlice(current)
+	r.filterByAttributeNamespace(currentComponents)
+
+	vattrs, _, err := r.GetVersionedAttributes(ctx, serverID, serverBIOSConfigNS(r.appKind))
+	if err != nil {
+		metrics.FleetDBAPIQueryErrorCount.With(stageLabel).Inc()
+		span.SetStatus(codes.Error, "GetVersionedAttributes() failed")
+
+		return nil, errors.Wrap(model.ErrInventoryQuery, err.Error())
+	}
+
+	var currentBiosConfig map[string]string
+
+	if latest := latestVersionedAttribute(vattrs); latest != nil {
+		if err := json.Unmarshal(latest.Data, &currentBiosConfig); err != nil {
+			return nil, errors.Wrap(ErrInventoryDiff, "BIOS configuration: "+err.Error())
+		}
+	}
+
+	newComponents, err := r.inventoryComponents(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.diffInventory(ctx, currentComponents, newComponents, currentBiosConfig, asset.BiosConfig)
+}
+
+func (r *Store) inventoryComponents(asset *model.Asset) ([]*fleetdbapi.ServerComponent, error) {
+	if asset.Inventory == nil {
+		return nil, nil
+	}
+
+	// the server ID is not relevant to the comparison and may not be set for assets read from files.
+	components, err := r.toComponentSlice(uuid.Nil, asset)
+	if err != nil {
+		return nil, errors.Wrap(ErrAssetObjectConversion, err.Error())
+	}
+
+	return components, nil
+}
+
+func (r *Store) diffInventory(
+	_ context.Context,
+	beforeComponents,
+	afterComponents []*fleetdbapi.ServerComponent,
+	beforeBiosConfig,
+	afterBiosConfig map[string]string,
+) (*InventoryDiff, error) {
+	diff := &InventoryDiff{BIOSConfig: diffStringMaps(beforeBiosConfig, afterBiosConfig)}
+
+	for _, before := range beforeComponents {
+		after := componentBySlugSerial(before.ComponentTypeSlug, before.Serial, afterComponents)
+		if after == nil {
+			diff.Removed = append(diff.Removed, toComponentDiff(before))
+			continue
+		}
+
+		// serverServiceComponentsUpdated modifies the object passed in, a copy is passed
+		// so the attributes of the after object remain available to list the changes.
+		changeObj := *after
+
+		updated, err := serverServiceComponentsUpdated(before, &changeObj)
+		if err != nil {
+			return nil, errors.Wrap(ErrInventoryDiff, err.Error())
+		}
+
+		if updated == nil {
+			continue
+		}
+
+		changes, err := componentChanges(before, after)
+		if err != nil {
+			return nil, err
+		}
+
+		cdiff := toComponentDiff(after)
+		cdiff.Changes = changes
+		diff.Changed = append(diff.Changed, cdiff)
+	}
+
+	for _, after := range afterComponents {
+		if componentBySlugSerial(after.ComponentTypeSlug, after.Serial, beforeComponents) == nil {
+			diff.Added = append(diff.Added, toComponentDiff(after))
+		}
+	}
+
+	for _, list := range [][]*ComponentDiff{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Slug == list[j].Slug {
+				return list[i].Serial < list[j].Serial
+			}
+
+			return list[i].Slug < list[j].Slug
+		})
+	}
+
+	return diff, nil
+}
+
+func toComponentDiff(component *fleetdbapi.ServerComponent) *ComponentDiff {
+	return &ComponentDiff{
+		Slug:   component.ComponentTypeSlug,
+		Serial: component.Serial,
+		Vendor: component.Vendor,
+		Model:  component.Model,
+	}
+}
+
+// componentChanges lists the changes in the vendor, model, attributes and latest versioned attributes of the component.
+func componentChanges(before, after *fleetdbapi.ServerComponent) ([]*Change, error) {
+	changes := []*Change{}
+
+	if before.Vendor != after.Vendor {
+		changes = append(changes, &Change{Path: "vendor", From: before.Vendor, To: after.Vendor})
+	}
+
+	if before.Model != after.Model {
+		changes = append(changes, &Change{Path: "model", From: before.Model, To: after.Model})
+	}
+
+	beforeData := map[string]json.RawMessage{}
+	afterData := map[string]json.RawMessage{}
+
+	for _, attr := range before.Attributes {
+		beforeData[attr.Namespace] = attr.Data
+	}
+
+	for _, attr := range after.Attributes {
+		afterData[attr.Namespace] = attr.Data
+	}
+
+	// versioned attributes and attributes are stored under different namespaces.
+	for ns, va := range latestVersionedAttributes(before.VersionedAttributes) {
+		beforeData[ns] = va.Data
+	}
+
+	for ns, va := range latestVersionedAttributes(after.VersionedAttributes) {
+		afterData[ns] = va.Data
+	}
+
+	namespaces := []string{}
+	for ns := range beforeData {
+		namespaces = append(namespaces, ns)
+	}
+
+	for ns := range afterData {
+		if _, exists := beforeData[ns]; !exists {
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	sort.Strings(namespaces)
+
+	for _, ns := range namespaces {
+		nsChanges, err := diffAttributeData(beforeData[ns], afterData[ns])
+		if err != nil {
+			return nil, errors.Wrap(ErrInventoryDiff, ns+": "+err.Error())
+		}
+
+		changes = append(changes, nsChanges...)
+	}
+
+	return changes, nil
+}
+
+// diffAttributeData returns the changes between the JSON attribute data objects.
+func diffAttributeData(before, after json.RawMessage) ([]*Change, error) {
+	var beforeObj, afterObj map[string]any
+
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &beforeObj); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &afterObj); err != nil {
+			return nil, err
+		}
+	}
+
+	changelog, err := r3diff.Diff(beforeObj, afterObj)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]*Change, 0, len(changelog))
+	for _, c := range changelog {
+		changes = append(changes, &Change{Path: strings.Join(c.Path, "."), From: c.From, To: c.To})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	return changes, nil
+}
+
+// latestVersionedAttributes returns the latest (created_at) versioned attribute for each namespace.
+func latestVersionedAttributes(vattrs []fleetdbapi.VersionedAttributes) map[string]*fleetdbapi.VersionedAttributes {
+	latest := map[string]*fleetdbapi.VersionedAttributes{}
+
+	for idx := range vattrs {
+		va := &vattrs[idx]
+		if current, exists := latest[va.Namespace]; !exists || va.CreatedAt.After(current.CreatedAt) {
+			latest[va.Namespace] = va
+		}
+	}
+
+	return latest
+}
+
+// latestVersionedAttribute returns the latest (created_at) versioned attribute in the list.
+func latestVersionedAttribute(vattrs []fleetdbapi.VersionedAttributes) *fleetdbapi.VersionedAttributes {
+	var latest *fleetdbapi.VersionedAttributes
+
+	for idx := range vattrs {
+		if latest == nil || vattrs[idx].CreatedAt.After(latest.CreatedAt) {
+			latest = &vattrs[idx]
+		}
+	}
+
+	return latest
+}
+
+func diffStringMaps(before, after map[string]string) []*Change {
+	changes := []*Change{}
+
+	for key, bvalue := range before {
+		avalue, exists := after[key]
+
+		switch {
+		case !exists:
+			changes = append(changes, &Change{Path: key, From: bvalue})
+		case avalue != bvalue:
+			changes = append(changes, &Change{Path: key, From: bvalue, To: avalue})
+		}
+	}
+
+	for key, avalue := range after {
+		if _, exists := before[key]; !exists {
+			changes = append(changes, &Change{Path: key, To: avalue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return changes
+}
+
+// WriteText writes the differences in a human readable form,
+// lines are prefixed with + for additions, - for removals and ~ for changes.
+func (d *InventoryDiff) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	if d.Empty() {
+		b.WriteString("no differences\n")
+	}
+
+	for _, c := range d.Removed {
+		fmt.Fprintf(&b, "- %s\n", c)
+	}
+
+	for _, c := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", c)
+	}
+
+	for _, c := range d.Changed {
+		fmt.Fprintf(&b, "~ %s\n", c)
+
+		for _, change := range c.Changes {
+			fmt.Fprintf(&b, "    %s\n", change)
+		}
+	}
+
+	if len(d.BIOSConfig) > 0 {
+		b.WriteString("BIOS configuration:\n")
+
+		for _, change := range d.BIOSConfig {
+			fmt.Fprintf(&b, "    %s\n", change)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func (c *ComponentDiff) String() string {
+	return fmt.Sprintf("%s serial=%s vendor=%s model=%s", c.Slug, c.Serial, c.Vendor, c.Model)
+}
+
+func (c *Change) String() string {
+	switch {
+	case c.From == nil:
+		return fmt.Sprintf("+ %s: %v", c.Path, c.To)
+	case c.To == nil:
+		return fmt.Sprintf("- %s: %v", c.Path, c.From)
+	default:
+		return fmt.Sprintf("~ %s: %v -> %v", c.Path, c.From, c.To)
+	}
+}
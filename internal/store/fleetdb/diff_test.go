@@ -0,0 +1,114 @@
+package fleetdb
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	common "github.com/metal-toolbox/bmc-common"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/fixtures"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_DiffInventory(t *testing.T) {
+	before := &model.Asset{
+		Vendor:     "equinix",
+		Inventory:  fixtures.CopyDevice(fixtures.E3C246D4INL),
+		BiosConfig: map[string]string{"boot_mode": "Bios", "sriov": "Enabled"},
+	}
+
+	after := &model.Asset{
+		Vendor:     "equinix",
+		Inventory:  fixtures.CopyDevice(fixtures.E3C246D4INL),
+		BiosConfig: map[string]string{"boot_mode": "Uefi", "tpm": "Enabled"},
+	}
+
+	// BIOS firmware updated
+	after.Inventory.BIOS.Firmware.Installed = "L2.08"
+
+	// drive replaced
+	after.Inventory.Drives[1] = &common.Drive{
+		Common: common.Common{Vendor: "intel", Model: "SSDSC2KB480G8", Serial: "NEW123"},
+	}
+
+	store := NewOffline(model.AppKindOutOfBand, logrus.New())
+
+	diff, err := store.DiffInventory(context.TODO(), before, after)
+	require.Nil(t, err)
+
+	require.Len(t, diff.Removed, 1)
+	assert.Equal(t, "drive", diff.Removed[0].Slug)
+	assert.Equal(t, "PHYF001209KL480BGN", diff.Removed[0].Serial)
+
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, "drive", diff.Added[0].Slug)
+	assert.Equal(t, "NEW123", diff.Added[0].Serial)
+
+	require.Len(t, diff.Changed, 1)
+	assert.Equal(t, "bios", diff.Changed[0].Slug)
+	assert.Contains(t, diff.Changed[0].Changes, &Change{Path: "firmware.installed", From: "L2.07B", To: "L2.08"})
+
+	assert.Equal(
+		t,
+		[]*Change{
+			{Path: "boot_mode", From: "Bios", To: "Uefi"},
+			{Path: "sriov", From: "Enabled"},
+			{Path: "tpm", To: "Enabled"},
+		},
+		diff.BIOSConfig,
+	)
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, diff.WriteText(buf))
+	assert.Contains(t, buf.String(), "- drive serial=PHYF001209KL480BGN")
+	assert.Contains(t, buf.String(), "+ drive serial=NEW123")
+	assert.Contains(t, buf.String(), "~ firmware.installed: L2.07B -> L2.08")
+
+	// no differences
+	diff, err = store.DiffInventory(context.TODO(), before, before)
+	require.Nil(t, err)
+	assert.True(t, diff.Empty())
+
+	buf.Reset()
+	require.Nil(t, diff.WriteText(buf))
+	assert.Equal(t, "no differences", strings.TrimSpace(buf.String()))
+}
+
+func Test_NewReadOnly(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		"/api/v1/server-component-types",
+		func(w http.ResponseWriter, r *http.Request) {
+			// component types are not created.
+			if r.Method != http.MethodGet {
+				t.Error("unexpected request method: " + r.Method)
+				w.WriteHeader(http.StatusMethodNotAllowed)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"records": []}`))
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	cfg := &app.FleetDBAPIOptions{Endpoint: mock.URL, DisableOAuth: true}
+
+	store, err := NewReadOnly(context.TODO(), model.AppKindOutOfBand, cfg, logrus.New())
+	require.Nil(t, err)
+
+	// the component types not registered are compared by their slugs.
+	assert.Equal(t, offlineSlugs(), store.slugs)
+	assert.NotNil(t, store.dryRun)
+}
@@ -0,0 +1,150 @@
+package fleetdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
+)
+
+// fleetDBWriter is the set of fleetdb API client methods that write to fleetdb.
+//
+// The store writes through this interface so that in dry-run mode the writes are recorded in a plan instead.
+type fleetDBWriter interface {
+	CreateAttributes(ctx context.Context, srvUUID uuid.UUID, attr fleetdbapi.Attributes) (*fleetdbapi.ServerResponse, error)
+	UpdateAttributes(ctx context.Context, srvUUID uuid.UUID, ns string, data json.RawMessage) (*fleetdbapi.ServerResponse, error)
+	CreateVersionedAttributes(ctx context.Context, srvUUID uuid.UUID, va fleetdbapi.VersionedAttributes) (*fleetdbapi.ServerResponse, error)
+	CreateComponents(ctx context.Context, srvUUID uuid.UUID, components fleetdbapi.ServerComponentSlice) (*fleetdbapi.ServerResponse, error)
+	UpdateComponents(ctx context.Context, srvUUID uuid.UUID, components fleetdbapi.ServerComponentSlice) (*fleetdbapi.ServerResponse, error)
+	DeleteServerComponents(ctx context.Context, srvUUID uuid.UUID) (*fleetdbapi.ServerResponse, error)
+	CreateServerComponentType(ctx context.Context, t fleetdbapi.ServerComponentType) (*fleetdbapi.ServerResponse, error)
+}
+
+// Plan lists the changes that would be registered in fleetdb for a server.
+type Plan struct {
+	ServerID                    string                          `json:"server_id"`
+	AttributesCreated           []*PlannedAttribute             `json:"attributes_created,omitempty"`
+	AttributesUpdated           []*PlannedAttribute             `json:"attributes_updated,omitempty"`
+	VersionedAttributesCreated  []*PlannedAttribute             `json:"versioned_attributes_created,omitempty"`
+	ComponentsAdded             fleetdbapi.ServerComponentSlice `json:"components_added,omitempty"`
+	ComponentsUpdated           fleetdbapi.ServerComponentSlice `json:"components_updated,omitempty"`
+	ComponentsRemoved           fleetdbapi.ServerComponentSlice `json:"components_removed,omitempty"`
+	ComponentsRemovalSkipped    fleetdbapi.ServerComponentSlice `json:"components_removal_skipped,omitempty"`
+	ComponentsRemovalSkipReason string                          `json:"components_removal_skip_reason,omitempty"`
+	Error                       string                          `json:"error,omitempty"`
+}
+
+// PlannedAttribute is an attribute or versioned attribute that would be created or updated.
+type PlannedAttribute struct {
+	Namespace string          `json:"namespace"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// dryRunWriter records the fleetdb writes in a plan for each server.
+type dryRunWriter struct {
+	plans map[uuid.UUID]*Plan
+	out   io.Writer
+	mu    sync.Mutex
+}
+
+func newDryRunWriter(out io.Writer) *dryRunWriter {
+	return &dryRunWriter{
+		plans: make(map[uuid.UUID]*Plan),
+		out:   out,
+	}
+}
+
+// plan returns the plan for the server, the caller is expected to hold the lock.
+func (w *dryRunWriter) plan(srvUUID uuid.UUID) *Plan {
+	p, exists := w.plans[srvUUID]
+	if !exists {
+		p = &Plan{ServerID: srvUUID.String()}
+		w.plans[srvUUID] = p
+	}
+
+	return p
+}
+
+// update runs the given func to update the plan for the server.
+func (w *dryRunWriter) update(srvUUID uuid.UUID, fn func(p *Plan)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	fn(w.plan(srvUUID))
+}
+
+// flush writes the plan for the server as JSON to the output and discards it.
+func (w *dryRunWriter) flush(srvUUID uuid.UUID, publishErr error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p := w.plan(srvUUID)
+	delete(w.plans, srvUUID)
+
+	if publishErr != nil {
+		p.Error = publishErr.Error()
+	}
+
+	b, err := json.MarshalIndent(p, "", " ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.out.Write(append(b, '\n'))
+
+	return err
+}
+
+func (w *dryRunWriter) CreateAttributes(_ context.Context, srvUUID uuid.UUID, attr fleetdbapi.Attributes) (*fleetdbapi.ServerResponse, error) {
+	w.update(srvUUID, func(p *Plan) {
+		p.AttributesCreated = append(p.AttributesCreated, &PlannedAttribute{Namespace: attr.Namespace, Data: attr.Data})
+	})
+
+	return &fleetdbapi.ServerResponse{}, nil
+}
+
+func (w *dryRunWriter) UpdateAttributes(_ context.Context, srvUUID uuid.UUID, ns string, data json.RawMessage) (*fleetdbapi.ServerResponse, error) {
+	w.update(srvUUID, func(p *Plan) {
+		p.AttributesUpdated = append(p.AttributesUpdated, &PlannedAttribute{Namespace: ns, Data: data})
+	})
+
+	return &fleetdbapi.ServerResponse{}, nil
+}
+
+func (w *dryRunWriter) CreateVersionedAttributes(_ context.Context, srvUUID uuid.UUID, va fleetdbapi.VersionedAttributes) (*fleetdbapi.ServerResponse, error) {
+	w.update(srvUUID, func(p *Plan) {
+		p.VersionedAttributesCreated = append(p.VersionedAttributesCreated, &PlannedAttribute{Namespace: va.Namespace, Data: va.Data})
+	})
+
+	return &fleetdbapi.ServerResponse{}, nil
+}
+
+func (w *dryRunWriter) CreateComponents(_ context.Context, srvUUID uuid.UUID, components fleetdbapi.ServerComponentSlice) (*fleetdbapi.ServerResponse, error) {
+	w.update(srvUUID, func(p *Plan) {
+		p.ComponentsAdded = append(p.ComponentsAdded, components...)
+	})
+
+	return &fleetdbapi.ServerResponse{}, nil
+}
+
+func (w *dryRunWriter) UpdateComponents(_ context.Context, srvUUID uuid.UUID, components fleetdbapi.ServerComponentSlice) (*fleetdbapi.ServerResponse, error) {
+	w.update(srvUUID, func(p *Plan) {
+		p.ComponentsUpdated = append(p.ComponentsUpdated, components...)
+	})
+
+	return &fleetdbapi.ServerResponse{}, nil
+}
+
+// DeleteServerComponents is not recorded in a plan, the components to be removed are recorded by removeServerComponents
+// since the delete is followed by re-creating the components retained.
+func (w *dryRunWriter) DeleteServerComponents(_ context.Context, _ uuid.UUID) (*fleetdbapi.ServerResponse, error) {
+	return &fleetdbapi.ServerResponse{}, nil
+}
+
+// CreateServerComponentType is not recorded in a plan since component types are not specific to a server.
+func (w *dryRunWriter) CreateServerComponentType(_ context.Context, _ fleetdbapi.ServerComponentType) (*fleetdbapi.ServerResponse, error) {
+	return &fleetdbapi.ServerResponse{}, nil
+}
@@ -0,0 +1,123 @@
+package fleetdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	common "github.com/metal-toolbox/bmc-common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/fixtures"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+func Test_FleetDB_DryRun_CreateUpdateServerComponents(t *testing.T) {
+	serverID, _ := uuid.Parse(fixtures.TestserverID_Dell_fc167440)
+
+	device := &model.Asset{
+		ID:        serverID.String(),
+		Vendor:    "dell",
+		Inventory: fixtures.CopyDevice(fixtures.R6515_fc167440),
+	}
+
+	// remove a DIMM from the collected inventory
+	removedDIMM := device.Inventory.Memory[len(device.Inventory.Memory)-1]
+	device.Inventory.Memory = device.Inventory.Memory[:len(device.Inventory.Memory)-1]
+
+	// update the BIOS firmware version
+	device.Inventory.BIOS.Firmware.Installed = "9.9.9"
+
+	handler := http.NewServeMux()
+	handler.HandleFunc(
+		fmt.Sprintf("/api/v1/servers/%s/components", serverID.String()),
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				t.Fatal("unexpected write in dry-run mode: " + r.Method)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(fixtures.FleetDBAPIR6515Components_fc167440_JSON())
+		},
+	)
+
+	handler.HandleFunc(
+		"/api/v1/server-component-firmwares",
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				t.Fatal("unexpected write in dry-run mode: " + r.Method)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+		},
+	)
+
+	mock := httptest.NewServer(handler)
+	defer mock.Close()
+
+	cases := []struct {
+		name          string
+		removal       bool
+		expectRemoved int
+		expectSkipped int
+	}{
+		{"component removal enabled", true, 1, 0},
+		{"component removal disabled", false, 0, 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+
+			p := testStoreInstance(t, mock.URL)
+			p.dryRun = newDryRunWriter(buf)
+			p.writer = p.dryRun
+			p.config = &app.FleetDBAPIOptions{
+				DryRun: true,
+				ComponentRemoval: app.ComponentRemovalOptions{
+					Enabled:     tc.removal,
+					MaxCount:    app.DefaultComponentRemovalMaxCount,
+					MaxFraction: app.DefaultComponentRemovalMaxFraction,
+				},
+			}
+
+			err := p.createUpdateServerComponents(context.TODO(), serverID, device)
+			require.Nil(t, err)
+
+			require.Nil(t, p.dryRun.flush(serverID, nil))
+
+			plan := &Plan{}
+			require.Nil(t, json.Unmarshal(buf.Bytes(), plan))
+
+			assert.Equal(t, serverID.String(), plan.ServerID)
+			assert.Empty(t, plan.Error)
+
+			require.Len(t, plan.ComponentsRemoved, tc.expectRemoved)
+			require.Len(t, plan.ComponentsRemovalSkipped, tc.expectSkipped)
+
+			removed := append(plan.ComponentsRemoved, plan.ComponentsRemovalSkipped...)
+			assert.Equal(t, common.SlugPhysicalMem, removed[0].Name)
+			assert.Equal(t, removedDIMM.Serial, removed[0].Serial)
+
+			var biosUpdated bool
+			for _, c := range plan.ComponentsUpdated {
+				if c.Name == common.SlugBIOS {
+					biosUpdated = true
+				}
+			}
+
+			assert.True(t, biosUpdated, "expected BIOS component update in plan")
+
+			// the plan is discarded once written
+			assert.Empty(t, p.dryRun.plans)
+		})
+	}
+}
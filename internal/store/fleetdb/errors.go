@@ -10,4 +10,6 @@ var (
 	ErrFleetDBAPIObject       = errors.New("serverService object error")
 	ErrChangeList             = errors.New("error building change list")
 	ErrFleetDBAttrObject      = errors.New("error in FleetDB API attribute object")
+	ErrComponentRemoval       = errors.New("component removal not permitted")
+	ErrComponentsIncomplete   = errors.New("server components listed are incomplete")
 )
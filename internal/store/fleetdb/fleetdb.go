@@ -2,6 +2,7 @@ package fleetdb
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"reflect"
 	"strings"
@@ -28,9 +29,34 @@ const (
 	pkgName = "internal/store"
 )
 
+// componentSlugs are the server component types registered in fleetdb.
+var componentSlugs = []string{
+	common.SlugBackplaneExpander,
+	common.SlugChassis,
+	common.SlugTPM,
+	common.SlugGPU,
+	common.SlugCPU,
+	common.SlugPhysicalMem,
+	common.SlugStorageController,
+	common.SlugBMC,
+	common.SlugBIOS,
+	common.SlugDrive,
+	common.SlugDriveTypePCIeNVMEeSSD,
+	common.SlugDriveTypeSATASSD,
+	common.SlugDriveTypeSATAHDD,
+	common.SlugNIC,
+	common.SlugPSU,
+	common.SlugCPLD,
+	common.SlugEnclosure,
+	common.SlugUnknown,
+	common.SlugMainboard,
+}
+
 // Store is an asset inventory store
 type Store struct {
 	*fleetdbapi.Client
+	writer                       fleetDBWriter
+	dryRun                       *dryRunWriter
 	logger                       *logrus.Logger
 	config                       *app.FleetDBAPIOptions
 	slugs                        map[string]*fleetdbapi.ServerComponentType
@@ -49,17 +75,12 @@ func New(ctx context.Context, appKind model.AppKind, cfg *app.FleetDBAPIOptions,
 		return nil, err
 	}
 
-	s := &Store{
-		Client:                       apiclient,
-		appKind:                      appKind,
-		logger:                       logger,
-		config:                       cfg,
-		slugs:                        make(map[string]*fleetdbapi.ServerComponentType),
-		firmwares:                    make(map[string][]*fleetdbapi.ComponentFirmwareVersion),
-		attributeNS:                  serverComponentAttributeNS(appKind),
-		firmwareVersionedAttributeNS: serverComponentFirmwareNS(appKind),
-		statusVersionedAttributeNS:   serverComponentStatusNS(appKind),
-		facilityCode:                 cfg.FacilityCode,
+	s := newStore(apiclient, appKind, cfg, logger)
+
+	// in dry-run mode writes are recorded in a plan for each server that is printed instead.
+	if cfg.DryRun {
+		s.dryRun = newDryRunWriter(os.Stdout)
+		s.writer = s.dryRun
 	}
 
 	// add component types if they don't exist
@@ -75,6 +96,11 @@ func New(ctx context.Context, appKind model.AppKind, cfg *app.FleetDBAPIOptions,
 		return nil, err
 	}
 
+	// component types are not created in dry-run mode.
+	if len(s.slugs) == 0 && s.dryRun != nil {
+		s.slugs = offlineSlugs()
+	}
+
 	if len(s.slugs) == 0 {
 		return nil, errors.Wrap(ErrSlugs, "required component slugs not found in fleetdb")
 	}
@@ -82,6 +108,23 @@ func New(ctx context.Context, appKind model.AppKind, cfg *app.FleetDBAPIOptions,
 	return s, nil
 }
 
+// newStore returns the Store for the fleetdb API client, with writes made through the client.
+func newStore(apiclient *fleetdbapi.Client, appKind model.AppKind, cfg *app.FleetDBAPIOptions, logger *logrus.Logger) *Store {
+	return &Store{
+		Client:                       apiclient,
+		writer:                       apiclient,
+		appKind:                      appKind,
+		logger:                       logger,
+		config:                       cfg,
+		slugs:                        make(map[string]*fleetdbapi.ServerComponentType),
+		firmwares:                    make(map[string][]*fleetdbapi.ComponentFirmwareVersion),
+		attributeNS:                  serverComponentAttributeNS(appKind),
+		firmwareVersionedAttributeNS: serverComponentFirmwareNS(appKind),
+		statusVersionedAttributeNS:   serverComponentStatusNS(appKind),
+		facilityCode:                 cfg.FacilityCode,
+	}
+}
+
 // Kind returns the repository store kind.
 func (r *Store) Kind() model.StoreKind {
 	return model.StoreKindFleetDB
@@ -107,7 +150,8 @@ func (r *Store) AssetByID(ctx context.Context, id string, fetchBmcCredentials bo
 
 	var credential *fleetdbapi.ServerCredential
 
-	if fetchBmcCredentials {
+	// the BMC credential is resolved from the secret provider when referenced by key.
+	if fetchBmcCredentials && bmcCredentialKey(server) == "" {
 		var err error
 
 		// get bmc credential
@@ -119,11 +163,40 @@ func (r *Store) AssetByID(ctx context.Context, id string, fetchBmcCredentials bo
 		}
 	}
 
-	return toAsset(server, credential, fetchBmcCredentials)
+	asset, err := toAsset(server, credential, fetchBmcCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fetchBmcCredentials {
+		setBMCAddress(asset, server)
+	}
+
+	r.setCollectionStatus(asset, server)
+
+	return asset, nil
 }
 
 // assetByID queries serverService for the hardware asset by ID and returns an Asset object
 func (r *Store) AssetsByOffsetLimit(ctx context.Context, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	return r.AssetsBySelector(ctx, nil, offset, limit)
+}
+
+// AssetsBySelector returns the assets at the given offset (page), limit values, selected by the selector.
+//
+// The vendor, model, serial prefix and attribute selectors are included in the server list query,
+// the collected before selector is not supported by the server list query and is to be applied by the caller.
+func (r *Store) AssetsBySelector(ctx context.Context, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	return r.assetsBySelector(ctx, selector, offset, limit, true)
+}
+
+// ListAssets returns the assets at the given offset (page), limit values, selected by the selector,
+// without the BMC credentials - the credentials are not queried for the servers listed.
+func (r *Store) ListAssets(ctx context.Context, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	return r.assetsBySelector(ctx, selector, offset, limit, false)
+}
+
+func (r *Store) assetsBySelector(ctx context.Context, selector *model.AssetSelector, offset, limit int, fetchBmcCredentials bool) (assets []*model.Asset, totalAssets int, err error) {
 	ctx, span := otel.Tracer(pkgName).Start(ctx, "fleetdbapi.AssetByOffsetLimit")
 	defer span.End()
 
@@ -136,11 +209,14 @@ func (r *Store) AssetsByOffsetLimit(ctx context.Context, offset, limit int) (ass
 
 	params := &fleetdbapi.ServerListParams{
 		FacilityCode: r.facilityCode,
-		AttributeListParams: []fleetdbapi.AttributeListParams{
-			{
-				Namespace: bmcAttributeNamespace,
+		AttributeListParams: append(
+			[]fleetdbapi.AttributeListParams{
+				{
+					Namespace: bmcAttributeNamespace,
+				},
 			},
-		},
+			selectorAttributeListParams(selector)...,
+		),
 		PaginationParams: &fleetdbapi.PaginationParams{
 			Limit:   limit,
 			Page:    offset,
@@ -160,11 +236,30 @@ func (r *Store) AssetsByOffsetLimit(ctx context.Context, offset, limit int) (ass
 
 	// collect bmc secrets and structure as alloy asset
 	for _, server := range serverPtrSlice(servers) {
-		credential, _, err := r.GetCredential(ctx, server.UUID, fleetdbapi.ServerCredentialTypeBMC)
-		if err != nil {
-			span.SetStatus(codes.Error, "GetCredential() failed")
+		if !fetchBmcCredentials {
+			asset, err := toListedAsset(server)
+			if err != nil {
+				r.logger.Warn(err)
+				continue
+			}
 
-			return nil, 0, errors.Wrap(model.ErrInventoryQuery, err.Error())
+			r.setCollectionStatus(asset, server)
+
+			assets = append(assets, asset)
+
+			continue
+		}
+
+		var credential *fleetdbapi.ServerCredential
+
+		// the BMC credential is resolved from the secret provider when referenced by key.
+		if bmcCredentialKey(server) == "" {
+			credential, _, err = r.GetCredential(ctx, server.UUID, fleetdbapi.ServerCredentialTypeBMC)
+			if err != nil {
+				span.SetStatus(codes.Error, "GetCredential() failed")
+
+				return nil, 0, errors.Wrap(model.ErrInventoryQuery, err.Error())
+			}
 		}
 
 		asset, err := toAsset(server, credential, true)
@@ -173,6 +268,8 @@ func (r *Store) AssetsByOffsetLimit(ctx context.Context, offset, limit int) (ass
 			continue
 		}
 
+		r.setCollectionStatus(asset, server)
+
 		assets = append(assets, asset)
 	}
 
@@ -180,7 +277,9 @@ func (r *Store) AssetsByOffsetLimit(ctx context.Context, offset, limit int) (ass
 }
 
 // AssetUpdate inserts/updates the asset data in the fleetdb store
-func (r *Store) AssetUpdate(ctx context.Context, asset *model.Asset) error {
+//
+// In dry-run mode the changes are printed as a plan instead.
+func (r *Store) AssetUpdate(ctx context.Context, asset *model.Asset) (err error) {
 	ctx, span := otel.Tracer(pkgName).Start(ctx, "fleetdbapi.AssetUpdate")
 	defer span.End()
 
@@ -193,6 +292,14 @@ func (r *Store) AssetUpdate(ctx context.Context, asset *model.Asset) error {
 		return errors.Wrap(ErrAssetObject, "invalid device ID")
 	}
 
+	if r.dryRun != nil {
+		defer func() {
+			if errPlan := r.dryRun.flush(id, err); errPlan != nil {
+				r.logger.WithField("id", id).WithError(errPlan).Error("dry-run plan output error")
+			}
+		}()
+	}
+
 	// 1. retrieve current server object.
 	server, hr, err := r.Get(ctx, id)
 	if err != nil {
@@ -222,7 +329,22 @@ func (r *Store) AssetUpdate(ctx context.Context, asset *model.Asset) error {
 	}
 
 	// publish server inventory
-	if errPublishInv := r.publishInventory(ctx, asset, server); errPublishInv != nil {
+	errPublishInv := r.publishInventory(ctx, asset, server)
+
+	// the collection status is registered for the collections to be prioritized by staleness,
+	// errors are logged and not returned to have the inventory update result returned.
+	//
+	// collections skipped on an open BMC circuit were not attempted and leave the status as is.
+	if !outofband.CollectionSkipped(asset) {
+		if errStatus := r.createUpdateServerCollectionStatus(ctx, server, errPublishInv == nil); errStatus != nil {
+			r.logger.WithFields(
+				logrus.Fields{
+					"id": id,
+				}).WithError(errStatus).Warn("asset collection status insert/update error")
+		}
+	}
+
+	if errPublishInv != nil {
 		r.logger.WithFields(
 			logrus.Fields{
 				"id": id,
@@ -287,6 +409,11 @@ func (r *Store) publishInventory(ctx context.Context, asset *model.Asset, server
 			return errors.New(string(outofband.LoginError))
 		}
 
+		// the collection was skipped
+		if asset.HasError(outofband.CircuitOpenError) {
+			return errors.New(string(outofband.CircuitOpenError))
+		}
+
 		if asset.HasError(outofband.InventoryError) {
 			return errors.New(string(outofband.InventoryError))
 		}
@@ -336,7 +463,7 @@ func (r *Store) createUpdateServerComponents(ctx context.Context, serverID uuid.
 	).Add(float64(len(newInventory)))
 
 	// retrieve current inventory from server service
-	currentInventory, _, err := r.GetComponents(ctx, serverID, &fleetdbapi.PaginationParams{})
+	currentInventory, err := r.serverComponents(ctx, serverID)
 	if err != nil {
 		// count error
 		metrics.FleetDBAPIQueryErrorCount.With(stageLabel).Inc()
@@ -385,7 +512,7 @@ func (r *Store) createUpdateServerComponents(ctx context.Context, serverID uuid.
 			),
 		).Add(float64(len(add)))
 
-		_, err = r.CreateComponents(ctx, serverID, add)
+		_, err = r.writer.CreateComponents(ctx, serverID, add)
 		if err != nil {
 			if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
 				r.logger.WithFields(logrus.Fields{
@@ -415,7 +542,7 @@ func (r *Store) createUpdateServerComponents(ctx context.Context, serverID uuid.
 			),
 		).Add(float64(len(update)))
 
-		_, err = r.UpdateComponents(ctx, serverID, update)
+		_, err = r.writer.UpdateComponents(ctx, serverID, update)
 		if err != nil {
 			r.logger.WithFields(logrus.Fields{
 				"update-components": update,
@@ -431,33 +558,271 @@ func (r *Store) createUpdateServerComponents(ctx context.Context, serverID uuid.
 		}
 	}
 
-	// Alloy should not be removing components based on diff data
+	// Components are removed only when enabled in the configuration,
 	// this is because data collected out of band differs from data collected inband
-	// and so this remove section is not going to be implemented.
-	//
-	// What can be done instead is that Alloy touches a timestamp value
-	// on each component and if that timestamp value has not been updated
-	// for a certain amount of time, compared to the rest of the component timestamps
-	// then the component may be eligible for removal.
+	// and so a component not reported by one of them may still be present on the server.
 	if len(remove) > 0 {
-		// count components removed
+		if err := r.removeServerComponents(ctx, serverID, currentInventory, currentInventoryPtrSlice, newInventory, remove); err != nil {
+			// set span status
+			span.SetStatus(codes.Error, "removeServerComponents() failed")
+
+			return err
+		}
+	}
+
+	r.logger.WithFields(
+		logrus.Fields{
+			"serverID": serverID,
+			"added":    len(add),
+			"updated":  len(update),
+			"removed":  len(remove),
+		}).Debug("registered inventory changes with server service")
+
+	return nil
+}
+
+// restoreServerComponents re-creates the server components deleted by a component removal that failed,
+// the components are otherwise created by the following collection.
+func (r *Store) restoreServerComponents(ctx context.Context, serverID uuid.UUID, components fleetdbapi.ServerComponentSlice) {
+	if len(components) == 0 {
+		return
+	}
+
+	if _, err := r.writer.CreateComponents(ctx, serverID, components); err != nil {
+		// count error
+		metrics.FleetDBAPIQueryErrorCount.With(stageLabel).Inc()
+
+		r.logger.WithFields(logrus.Fields{
+			"serverID": serverID,
+			"count":    len(components),
+		}).WithError(err).Error("restore server components returned error, components are created on the following collection")
+
+		return
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"serverID": serverID,
+		"count":    len(components),
+	}).Warn("server components restored after a failed component removal")
+}
+
+// removeServerComponents removes the given components from the server in fleetdb
+// when component removal is enabled and the removal passes the configured safeguards.
+//
+// FleetDB does not provide a means to delete individual server components,
+// and so the server components are deleted and the components that are retained are re-created
+// with their current attributes and latest versioned attributes. The re-created components are assigned new IDs,
+// and the versioned attributes history prior to the latest is not retained.
+//
+// The components are the complete list of server components with attributes in all namespaces, as listed by serverComponents,
+// the currentInventory is the list filtered to the namespaces of this instance the removal is validated with.
+//
+// The delete and re-create is not atomic, when the retained components fail to be re-created
+// the components listed before the delete are restored. When the restore fails as well,
+// the server has no components registered until the following collection creates them.
+func (r *Store) removeServerComponents(
+	ctx context.Context,
+	serverID uuid.UUID,
+	components fleetdbapi.ServerComponentSlice,
+	currentInventory,
+	newInventory []*fleetdbapi.ServerComponent,
+	remove fleetdbapi.ServerComponentSlice,
+) error {
+	ctx, span := otel.Tracer(pkgName).Start(ctx, "fleetdbapi.removeServerComponents")
+	defer span.End()
+
+	if r.config == nil || !r.config.ComponentRemoval.Enabled {
+		r.logger.WithFields(logrus.Fields{
+			"serverID": serverID,
+			"count":    len(remove),
+		}).Debug("component removal disabled, components not removed")
+
+		r.planComponentRemovalSkipped(serverID, remove, "component removal disabled")
+
+		return nil
+	}
+
+	if err := validateComponentRemoval(&r.config.ComponentRemoval, currentInventory, newInventory, remove); err != nil {
+		r.logger.WithFields(logrus.Fields{
+			"serverID": serverID,
+			"count":    len(remove),
+		}).WithError(err).Warn("component removal skipped")
+
+		r.planComponentRemovalSkipped(serverID, remove, err.Error())
+
+		// count removals skipped
 		metricFleetDBDataChanges.With(
 			metrics.AddLabels(
 				stageLabel,
 				prometheus.Labels{
-					"change_kind": "components-removed",
+					"change_kind": "components-removal-skipped",
 				},
 			),
 		).Add(float64(len(remove)))
+
+		return nil
 	}
 
-	r.logger.WithFields(
-		logrus.Fields{
-			"serverID":      serverID,
-			"added":         len(add),
-			"updated":       len(update),
-			"(not) removed": len(remove),
-		}).Debug("registered inventory changes with server service")
+	// the components are deleted and the retained components re-created on removal,
+	// the plan lists the components to be removed instead.
+	if r.dryRun != nil {
+		r.dryRun.update(serverID, func(p *Plan) {
+			p.ComponentsRemoved = append(p.ComponentsRemoved, remove...)
+		})
+
+		return nil
+	}
+
+	removePtrSlice := componentPtrSlice(remove)
+	retain := fleetdbapi.ServerComponentSlice{}
+
+	for _, component := range componentPtrSlice(components) {
+		if componentBySlugSerial(component.ComponentTypeSlug, component.Serial, removePtrSlice) != nil {
+			continue
+		}
+
+		retain = append(retain, *component)
+	}
+
+	if _, err := r.writer.DeleteServerComponents(ctx, serverID); err != nil {
+		// count error
+		metrics.FleetDBAPIQueryErrorCount.With(stageLabel).Inc()
+
+		return errors.Wrap(model.ErrInventoryQuery, "DeleteServerComponents: "+err.Error())
+	}
+
+	if len(retain) > 0 {
+		if _, err := r.writer.CreateComponents(ctx, serverID, retain); err != nil {
+			r.logger.WithFields(logrus.Fields{
+				"serverID":          serverID,
+				"retain-components": retain,
+			}).WithError(err).Error("re-create retained components returned error")
+
+			// count error
+			metrics.FleetDBAPIQueryErrorCount.With(stageLabel).Inc()
+
+			r.restoreServerComponents(ctx, serverID, components)
+
+			return errors.Wrap(model.ErrInventoryQuery, "CreateComponents: "+err.Error())
+		}
+	}
+
+	// count components removed
+	metricFleetDBDataChanges.With(
+		metrics.AddLabels(
+			stageLabel,
+			prometheus.Labels{
+				"change_kind": "components-removed",
+			},
+		),
+	).Add(float64(len(remove)))
+
+	// audit log entry for each component removed
+	for _, component := range removePtrSlice {
+		r.logger.WithFields(logrus.Fields{
+			"audit":       "component-removed",
+			"serverID":    serverID,
+			"componentID": component.UUID,
+			"slug":        component.ComponentTypeSlug,
+			"vendor":      component.Vendor,
+			"model":       component.Model,
+			"serial":      component.Serial,
+		}).Info("server component removed")
+	}
+
+	return nil
+}
+
+// serverComponents returns all the components registered for the server, the pages of components are listed
+// up to the total count reported by fleetdb. An error is returned when fewer components are listed than the total,
+// for the changes not to be identified from, and the components not to be removed on a partial list.
+func (r *Store) serverComponents(ctx context.Context, serverID uuid.UUID) (fleetdbapi.ServerComponentSlice, error) {
+	components := fleetdbapi.ServerComponentSlice{}
+
+	var total int
+
+	for page := 1; ; page++ {
+		listed, response, err := r.GetComponents(
+			ctx,
+			serverID,
+			&fleetdbapi.PaginationParams{Limit: fleetdbapi.MaxPaginationSize, Page: page},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		components = append(components, listed...)
+		total = int(response.TotalRecordCount)
+
+		if len(listed) == 0 || len(components) >= total {
+			break
+		}
+	}
+
+	if len(components) < total {
+		return nil, errors.Wrap(
+			ErrComponentsIncomplete,
+			fmt.Sprintf("listed %d of %d components", len(components), total),
+		)
+	}
+
+	return components, nil
+}
+
+// planComponentRemovalSkipped includes the components not removed in the dry-run plan.
+func (r *Store) planComponentRemovalSkipped(serverID uuid.UUID, remove fleetdbapi.ServerComponentSlice, reason string) {
+	if r.dryRun == nil {
+		return
+	}
+
+	r.dryRun.update(serverID, func(p *Plan) {
+		p.ComponentsRemovalSkipped = append(p.ComponentsRemovalSkipped, remove...)
+		p.ComponentsRemovalSkipReason = reason
+	})
+}
+
+// validateComponentRemoval returns an error when the components to be removed exceed the configured limits,
+// or when the collected inventory looks to be partial.
+func validateComponentRemoval(opts *app.ComponentRemovalOptions, currentInventory, newInventory []*fleetdbapi.ServerComponent, remove fleetdbapi.ServerComponentSlice) error {
+	if opts.MaxCount > 0 && len(remove) > opts.MaxCount {
+		return errors.Wrap(
+			ErrComponentRemoval,
+			fmt.Sprintf("%d components to be removed exceeds the limit: %d", len(remove), opts.MaxCount),
+		)
+	}
+
+	if opts.MaxFraction > 0 && len(currentInventory) > 0 {
+		fraction := float64(len(remove)) / float64(len(currentInventory))
+		if fraction > opts.MaxFraction {
+			return errors.Wrap(
+				ErrComponentRemoval,
+				fmt.Sprintf("%.2f of components to be removed exceeds the limit: %.2f", fraction, opts.MaxFraction),
+			)
+		}
+	}
+
+	// count of components by slug in the current and new inventory
+	currentCount := map[string]int{}
+	for _, component := range currentInventory {
+		currentCount[component.ComponentTypeSlug]++
+	}
+
+	newCount := map[string]int{}
+	for _, component := range newInventory {
+		newCount[component.ComponentTypeSlug]++
+	}
+
+	// none of the components of a kind were collected, while the store has them registered,
+	// the inventory is assumed to be partial.
+	for idx := range remove {
+		slug := remove[idx].ComponentTypeSlug
+		if newCount[slug] == 0 {
+			return errors.Wrap(
+				ErrComponentRemoval,
+				fmt.Sprintf("inventory looks partial, collected 0 %s components, store has %d", slug, currentCount[slug]),
+			)
+		}
+	}
 
 	return nil
 }
@@ -630,35 +995,13 @@ func (r *Store) createServerComponentTypes(ctx context.Context) error {
 		return nil
 	}
 
-	componentSlugs := []string{
-		common.SlugBackplaneExpander,
-		common.SlugChassis,
-		common.SlugTPM,
-		common.SlugGPU,
-		common.SlugCPU,
-		common.SlugPhysicalMem,
-		common.SlugStorageController,
-		common.SlugBMC,
-		common.SlugBIOS,
-		common.SlugDrive,
-		common.SlugDriveTypePCIeNVMEeSSD,
-		common.SlugDriveTypeSATASSD,
-		common.SlugDriveTypeSATAHDD,
-		common.SlugNIC,
-		common.SlugPSU,
-		common.SlugCPLD,
-		common.SlugEnclosure,
-		common.SlugUnknown,
-		common.SlugMainboard,
-	}
-
 	for _, slug := range componentSlugs {
 		sct := fleetdbapi.ServerComponentType{
 			Name: slug,
 			Slug: strings.ToLower(slug),
 		}
 
-		_, err := r.CreateServerComponentType(ctx, sct)
+		_, err := r.writer.CreateServerComponentType(ctx, sct)
 		if err != nil {
 			return err
 		}
@@ -3,13 +3,14 @@ package fleetdb
 import (
 	"context"
 	"encoding/json"
-	"net"
+	"fmt"
 	"testing"
 
 	common "github.com/metal-toolbox/bmc-common"
 	fleetdbapi "github.com/metal-toolbox/fleetdb/pkg/api/v1"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/metal-toolbox/alloy/internal/app"
 	"github.com/metal-toolbox/alloy/internal/fixtures"
 	"github.com/metal-toolbox/alloy/internal/model"
 )
@@ -127,8 +128,37 @@ func Test_toAsset(t *testing.T) {
 				Facility:    "",
 				BMCUsername: "user",
 				BMCPassword: "hunter2",
-				BMCAddress:  net.ParseIP("127.0.0.1"),
+				BMCAddress:  &model.BMCAddress{Host: "127.0.0.1"},
 				Metadata:    map[string]string{},
+				Attributes: map[string]json.RawMessage{
+					bmcAttributeNamespace: json.RawMessage(`{"address":"127.0.0.1"}`),
+				},
+			},
+			"",
+		},
+		{
+			"Credential referenced by key does not require the fleetdb credential",
+			&fleetdbapi.Server{
+				Attributes: []fleetdbapi.Attributes{
+					{
+						Namespace: bmcAttributeNamespace,
+						Data:      []byte(`{"address":"bmc-01.example.com","credential":"rack-a"}`),
+					},
+				},
+			},
+			nil,
+			&model.Asset{
+				ID:               "00000000-0000-0000-0000-000000000000",
+				Vendor:           "unknown",
+				Model:            "unknown",
+				Serial:           "unknown",
+				Facility:         "",
+				BMCCredentialKey: "rack-a",
+				BMCAddress:       &model.BMCAddress{Host: "bmc-01.example.com"},
+				Metadata:         map[string]string{},
+				Attributes: map[string]json.RawMessage{
+					bmcAttributeNamespace: json.RawMessage(`{"address":"bmc-01.example.com","credential":"rack-a"}`),
+				},
 			},
 			"",
 		},
@@ -147,6 +177,65 @@ func Test_toAsset(t *testing.T) {
 	}
 }
 
+func Test_toListedAsset(t *testing.T) {
+	cases := []struct {
+		name          string
+		server        *fleetdbapi.Server
+		expectedAsset *model.Asset
+		expectedErr   string
+	}{
+		{
+			"Attributes missing BMC IP Address raises error",
+			&fleetdbapi.Server{
+				Attributes: []fleetdbapi.Attributes{
+					{
+						Namespace: bmcAttributeNamespace,
+						Data:      []byte(`{"namespace":"foo"}`),
+					},
+				},
+			},
+			nil,
+			"expected BMC address attribute empty",
+		},
+		{
+			"Listed server returns *model.Asset object with the BMC address, without credentials",
+			&fleetdbapi.Server{
+				Attributes: []fleetdbapi.Attributes{
+					{
+						Namespace: bmcAttributeNamespace,
+						Data:      []byte(`{"address":"127.0.0.1","credential":"rack-a"}`),
+					},
+				},
+			},
+			&model.Asset{
+				ID:               "00000000-0000-0000-0000-000000000000",
+				Vendor:           "unknown",
+				Model:            "unknown",
+				Serial:           "unknown",
+				BMCCredentialKey: "rack-a",
+				BMCAddress:       &model.BMCAddress{Host: "127.0.0.1"},
+				Metadata:         map[string]string{},
+				Attributes: map[string]json.RawMessage{
+					bmcAttributeNamespace: json.RawMessage(`{"address":"127.0.0.1","credential":"rack-a"}`),
+				},
+			},
+			"",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset, err := toListedAsset(tc.server)
+			if tc.expectedErr != "" {
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedAsset, asset)
+		})
+	}
+}
+
 func Test_vendorDataUpdate(t *testing.T) {
 	type args struct {
 		new     map[string]string
@@ -389,3 +478,96 @@ func Test_FleetDBAPIChangeList(t *testing.T) {
 		})
 	}
 }
+
+func Test_validateComponentRemoval(t *testing.T) {
+	components := func(slug string, count int) []*fleetdbapi.ServerComponent {
+		returned := []*fleetdbapi.ServerComponent{}
+		for i := 0; i < count; i++ {
+			returned = append(returned, &fleetdbapi.ServerComponent{ComponentTypeSlug: slug, Serial: fmt.Sprintf("%s-%d", slug, i)})
+		}
+
+		return returned
+	}
+
+	current := append(components(common.SlugDrive, 12), components(common.SlugPhysicalMem, 8)...)
+
+	// nolint:govet // ignore struct alignment in test
+	cases := []struct {
+		name         string
+		opts         *app.ComponentRemovalOptions
+		newInventory []*fleetdbapi.ServerComponent
+		remove       []*fleetdbapi.ServerComponent
+		expectedErr  string
+	}{
+		{
+			"removal permitted",
+			&app.ComponentRemovalOptions{Enabled: true, MaxCount: 4, MaxFraction: 0.25},
+			append(components(common.SlugDrive, 12), components(common.SlugPhysicalMem, 7)...),
+			current[19:],
+			"",
+		},
+		{
+			"removal exceeds max count",
+			&app.ComponentRemovalOptions{Enabled: true, MaxCount: 1, MaxFraction: 0.25},
+			append(components(common.SlugDrive, 10), components(common.SlugPhysicalMem, 8)...),
+			current[10:12],
+			"exceeds the limit: 1",
+		},
+		{
+			"removal exceeds max fraction",
+			&app.ComponentRemovalOptions{Enabled: true, MaxCount: 10, MaxFraction: 0.1},
+			append(components(common.SlugDrive, 9), components(common.SlugPhysicalMem, 8)...),
+			current[9:12],
+			"exceeds the limit: 0.10",
+		},
+		{
+			"partial inventory",
+			&app.ComponentRemovalOptions{Enabled: true, MaxCount: 20, MaxFraction: 1},
+			components(common.SlugPhysicalMem, 8),
+			current[:12],
+			"collected 0 Drive components, store has 12",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			remove := fleetdbapi.ServerComponentSlice{}
+			for _, c := range tc.remove {
+				remove = append(remove, *c)
+			}
+
+			err := validateComponentRemoval(tc.opts, current, tc.newInventory, remove)
+			if tc.expectedErr != "" {
+				assert.ErrorIs(t, err, ErrComponentRemoval)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func Test_setBMCAddress(t *testing.T) {
+	cases := []struct {
+		name     string
+		data     string
+		expected *model.BMCAddress
+	}{
+		{"BMC address set", `{"address":"127.0.0.1"}`, &model.BMCAddress{Host: "127.0.0.1"}},
+		{"BMC address missing", `{"namespace":"foo"}`, nil},
+		{"BMC address invalid", `{"address":"127.0.0.1:foo"}`, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &fleetdbapi.Server{
+				Attributes: []fleetdbapi.Attributes{{Namespace: bmcAttributeNamespace, Data: []byte(tc.data)}},
+			}
+
+			asset := &model.Asset{}
+			setBMCAddress(asset, server)
+			assert.Equal(t, tc.expected, asset.BMCAddress)
+		})
+	}
+}
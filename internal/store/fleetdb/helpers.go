@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"time"
@@ -149,7 +148,10 @@ func serverPtrSlice(servers []fleetdbapi.Server) []*fleetdbapi.Server {
 }
 
 func toAsset(server *fleetdbapi.Server, credential *fleetdbapi.ServerCredential, expectCredentials bool) (*model.Asset, error) {
-	if err := validateRequiredAttributes(server, credential, expectCredentials); err != nil {
+	credentialKey := bmcCredentialKey(server)
+
+	// the fleetdb credential is not required when the credential is referenced by key.
+	if err := validateRequiredAttributes(server, credential, expectCredentials && credentialKey == ""); err != nil {
 		return nil, errors.Wrap(ErrFleetDBAPIObject, err.Error())
 	}
 
@@ -171,13 +173,60 @@ func toAsset(server *fleetdbapi.Server, credential *fleetdbapi.ServerCredential,
 		Vendor:   serverAttributes[serverVendorAttributeKey],
 		Metadata: serverMetadataAttributes,
 		Facility: server.FacilityCode,
+
+		Attributes:      attributesByNamespace(server),
+		LastCollectedAt: lastCollectedAt(server),
+	}
+
+	if expectCredentials {
+		if credential != nil {
+			asset.BMCUsername = credential.Username
+			asset.BMCPassword = credential.Password
+		}
+
+		asset.BMCCredentialKey = credentialKey
+
+		asset.BMCAddress, err = model.ParseBMCAddress(serverAttributes[bmcIPAddressAttributeKey])
+		if err != nil {
+			return nil, errors.Wrap(ErrFleetDBAPIObject, err.Error())
+		}
+	}
+
+	return asset, nil
+}
+
+// toListedAsset returns the asset for a server listed without its BMC credentials,
+// the BMC address and credential key are set as they are with the credentials.
+func toListedAsset(server *fleetdbapi.Server) (*model.Asset, error) {
+	asset, err := toAsset(server, nil, false)
+	if err != nil {
+		return nil, err
 	}
 
-	if credential != nil {
-		asset.BMCUsername = credential.Username
-		asset.BMCPassword = credential.Password
-		asset.BMCAddress = net.ParseIP(serverAttributes[bmcIPAddressAttributeKey])
+	serverAttributes, err := serverAttributes(server.Attributes, true)
+	if err != nil {
+		return nil, errors.Wrap(ErrFleetDBAPIObject, err.Error())
+	}
+
+	asset.BMCCredentialKey = bmcCredentialKey(server)
+
+	asset.BMCAddress, err = model.ParseBMCAddress(serverAttributes[bmcIPAddressAttributeKey])
+	if err != nil {
+		return nil, errors.Wrap(ErrFleetDBAPIObject, err.Error())
 	}
 
 	return asset, nil
 }
+
+// setBMCAddress sets the BMC address of an asset looked up without its BMC credentials,
+// the BMC address is left unset when the server has no valid BMC address attribute.
+func setBMCAddress(asset *model.Asset, server *fleetdbapi.Server) {
+	serverAttributes, err := serverAttributes(server.Attributes, true)
+	if err != nil {
+		return
+	}
+
+	if address, err := model.ParseBMCAddress(serverAttributes[bmcIPAddressAttributeKey]); err == nil {
+		asset.BMCAddress = address
+	}
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+	"github.com/metal-toolbox/alloy/internal/secrets"
+)
+
+var (
+	ErrCredentialResolve = errors.New("error resolving BMC credential")
+)
+
+// secretResolver is a Repository that resolves the BMC credentials referenced by key,
+// for the assets returned by the wrapped Repository.
+type secretResolver struct {
+	Repository
+	provider secrets.SecretProvider
+	logger   *logrus.Entry
+}
+
+// WithSecretProvider returns a Repository that resolves the BMC credentials referenced by key
+// from the given SecretProvider, for the assets returned by the given Repository.
+func WithSecretProvider(repository Repository, provider secrets.SecretProvider, logger *logrus.Logger) Repository {
+	return &secretResolver{
+		Repository: repository,
+		provider:   provider,
+		logger:     logger.WithField("component", "store.secrets"),
+	}
+}
+
+// AssetByID returns one asset from the inventory identified by its identifier.
+func (s *secretResolver) AssetByID(ctx context.Context, assetID string, fetchBmcCredentials bool) (*model.Asset, error) {
+	asset, err := s.Repository.AssetByID(ctx, assetID, fetchBmcCredentials)
+	if err != nil || asset == nil || !fetchBmcCredentials {
+		return asset, err
+	}
+
+	if err := s.resolve(ctx, asset); err != nil {
+		return nil, err
+	}
+
+	return asset, nil
+}
+
+// AssetsByOffsetLimit returns the assets at the given offset (page), limit values.
+//
+// Assets for which the credential could not be resolved are returned without the BMC credentials.
+func (s *secretResolver) AssetsByOffsetLimit(ctx context.Context, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	return s.AssetsBySelector(ctx, nil, offset, limit)
+}
+
+// AssetsBySelector returns the assets at the given offset (page), limit values, selected by the selector.
+//
+// Assets for which the credential could not be resolved are returned without the BMC credentials.
+func (s *secretResolver) AssetsBySelector(ctx context.Context, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	assets, totalAssets, err = AssetsBySelector(ctx, s.Repository, selector, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, asset := range assets {
+		if err := s.resolve(ctx, asset); err != nil {
+			s.logger.WithError(err).WithField("serverID", asset.ID).Warn("BMC credential not resolved")
+		}
+	}
+
+	return assets, totalAssets, nil
+}
+
+// ListAssets returns the assets at the given offset (page), limit values, selected by the selector,
+// without the BMC credentials.
+func (s *secretResolver) ListAssets(ctx context.Context, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	return ListAssets(ctx, s.Repository, selector, offset, limit)
+}
+
+// resolve sets the asset BMC credentials from the secret provider, if the asset references a credential by key.
+func (s *secretResolver) resolve(ctx context.Context, asset *model.Asset) error {
+	if asset.BMCCredentialKey == "" {
+		return nil
+	}
+
+	credential, err := s.provider.Credential(ctx, asset.BMCCredentialKey)
+	if err != nil {
+		return errors.Wrap(ErrCredentialResolve, s.provider.Kind()+": "+err.Error())
+	}
+
+	asset.BMCUsername = credential.Username
+	asset.BMCPassword = credential.Password
+
+	return nil
+}
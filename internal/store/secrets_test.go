@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/model"
+	"github.com/metal-toolbox/alloy/internal/secrets"
+)
+
+const testSecretsCSV = `id,address,username,password,vendor,credential
+7b8a090d-3900-4c45-89e1-041044d27402,192.168.1.1,,,dell,rack-a
+a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2,192.168.1.2,root,calvin,dell,
+f0c8e4ac-5cce-4370-93ce-e0a5ec0e5c4c,192.168.1.3,,,dell,rack-b
+`
+
+func Test_SecretProvider(t *testing.T) {
+	t.Setenv("ALLOY_BMC_CREDENTIAL_RACK_A_USERNAME", "root")
+	t.Setenv("ALLOY_BMC_CREDENTIAL_RACK_A_PASSWORD", "hunter2")
+
+	csvFile := filepath.Join(t.TempDir(), "assets.csv")
+	require.Nil(t, os.WriteFile(csvFile, []byte(testSecretsCSV), 0o600))
+
+	cfg := &app.Configuration{
+		CsvFile:        csvFile,
+		SecretsOptions: &app.SecretsOptions{Provider: secrets.ProviderKindEnv},
+	}
+
+	repository, err := NewRepository(context.TODO(), model.StoreKindCsv, model.AppKindOutOfBand, cfg, logrus.New())
+	require.Nil(t, err)
+
+	// the credential referenced by key is resolved
+	asset, err := repository.AssetByID(context.TODO(), "7b8a090d-3900-4c45-89e1-041044d27402", true)
+	require.Nil(t, err)
+	assert.Equal(t, "root", asset.BMCUsername)
+	assert.Equal(t, "hunter2", asset.BMCPassword)
+
+	// the credential in the store is retained
+	asset, err = repository.AssetByID(context.TODO(), "a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2", true)
+	require.Nil(t, err)
+	assert.Equal(t, "calvin", asset.BMCPassword)
+
+	_, err = repository.AssetByID(context.TODO(), "f0c8e4ac-5cce-4370-93ce-e0a5ec0e5c4c", true)
+	assert.ErrorIs(t, err, ErrCredentialResolve)
+
+	assets, total, err := repository.AssetsByOffsetLimit(context.TODO(), 1, 10)
+	require.Nil(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, "hunter2", assets[0].BMCPassword)
+	assert.Empty(t, assets[2].BMCPassword)
+}
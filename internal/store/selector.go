@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+// SelectorRepository is a Repository that applies the asset selector in the store query.
+type SelectorRepository interface {
+	Repository
+
+	// AssetsBySelector returns the assets at the given offset (page), limit values, selected by the selector.
+	//
+	// The store may apply only the parts of the selector it supports,
+	// callers are to use the AssetsBySelector func which applies the complete selector to the assets returned.
+	AssetsBySelector(ctx context.Context, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error)
+}
+
+// ListingRepository is a Repository that lists the assets without their BMC credentials,
+// for callers that look up the credentials of each asset when it is collected.
+type ListingRepository interface {
+	Repository
+
+	// ListAssets returns the assets at the given offset (page), limit values, selected by the selector,
+	// with the BMC address set and without the BMC credentials.
+	//
+	// The store may apply only the parts of the selector it supports,
+	// callers are to use the ListAssets func which applies the complete selector to the assets returned.
+	ListAssets(ctx context.Context, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error)
+}
+
+// AssetsBySelector returns the assets at the given offset (page), limit values from the repository, selected by the selector.
+//
+// The selector is applied in the store query when the repository is a SelectorRepository,
+// and to the assets returned, the totalAssets is the count of assets in the store before the selector is applied to the returned assets.
+func AssetsBySelector(ctx context.Context, repository Repository, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	if selector.IsEmpty() {
+		return repository.AssetsByOffsetLimit(ctx, offset, limit)
+	}
+
+	if selecting, ok := repository.(SelectorRepository); ok {
+		assets, totalAssets, err = selecting.AssetsBySelector(ctx, selector, offset, limit)
+	} else {
+		assets, totalAssets, err = repository.AssetsByOffsetLimit(ctx, offset, limit)
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return selectAssets(selector, assets), totalAssets, nil
+}
+
+// ListAssets returns the assets at the given offset (page), limit values from the repository, selected by the selector,
+// without the BMC credentials when the repository is a ListingRepository.
+//
+// Repositories that don't list assets without credentials return the assets as AssetsBySelector does.
+func ListAssets(ctx context.Context, repository Repository, selector *model.AssetSelector, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	listing, ok := repository.(ListingRepository)
+	if !ok {
+		return AssetsBySelector(ctx, repository, selector, offset, limit)
+	}
+
+	assets, totalAssets, err = listing.ListAssets(ctx, selector, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if selector.IsEmpty() {
+		return assets, totalAssets, nil
+	}
+
+	return selectAssets(selector, assets), totalAssets, nil
+}
+
+// selectAssets returns the assets matched by the selector.
+func selectAssets(selector *model.AssetSelector, assets []*model.Asset) []*model.Asset {
+	selected := make([]*model.Asset, 0, len(assets))
+
+	for _, asset := range assets {
+		if selector.Match(asset) {
+			selected = append(selected, asset)
+		}
+	}
+
+	return selected
+}
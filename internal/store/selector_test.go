@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/model"
+	"github.com/metal-toolbox/alloy/internal/store/mock"
+)
+
+// selectingRepository is a SelectorRepository that applies the vendor selector in its query.
+type selectingRepository struct {
+	*mock.Mock
+	assets   []*model.Asset
+	selector *model.AssetSelector
+}
+
+func (s *selectingRepository) AssetsByOffsetLimit(_ context.Context, _, _ int) (assets []*model.Asset, totalAssets int, err error) {
+	return s.assets, len(s.assets), nil
+}
+
+func (s *selectingRepository) AssetsBySelector(_ context.Context, selector *model.AssetSelector, _, _ int) (assets []*model.Asset, totalAssets int, err error) {
+	s.selector = selector
+
+	for _, asset := range s.assets {
+		if asset.Vendor == selector.Vendor {
+			assets = append(assets, asset)
+		}
+	}
+
+	return assets, len(assets), nil
+}
+
+func Test_AssetsBySelector(t *testing.T) {
+	repository := &selectingRepository{
+		assets: []*model.Asset{
+			{ID: "1", Vendor: "dell", Model: "r6515"},
+			{ID: "2", Vendor: "dell", Model: "r640"},
+			{ID: "3", Vendor: "supermicro", Model: "x11dph-t"},
+		},
+	}
+
+	ids := func(assets []*model.Asset) []string {
+		s := []string{}
+		for _, asset := range assets {
+			s = append(s, asset.ID)
+		}
+
+		return s
+	}
+
+	// the vendor is selected by the repository, the model by the caller.
+	selector := &model.AssetSelector{Vendor: "dell", Model: "r6515"}
+
+	assets, total, err := AssetsBySelector(context.TODO(), repository, selector, 1, 10)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1"}, ids(assets))
+	assert.Equal(t, 2, total)
+	assert.Equal(t, selector, repository.selector)
+
+	// the selector is applied through the repository wrappers.
+	wrapped := WithCredentialFallback(repository, nil)
+
+	assets, _, err = AssetsBySelector(context.TODO(), wrapped, &model.AssetSelector{Vendor: "supermicro"}, 1, 10)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"3"}, ids(assets))
+
+	// the selector is applied to the assets returned by repositories that don't support it.
+	mockstore, err := mock.New(3)
+	require.Nil(t, err)
+
+	assets, total, err = AssetsBySelector(context.TODO(), mockstore, &model.AssetSelector{Vendor: "dell"}, 1, 10)
+	require.Nil(t, err)
+	assert.Len(t, assets, 0)
+	assert.Equal(t, 3, total)
+
+	assets, _, err = AssetsBySelector(context.TODO(), mockstore, nil, 1, 10)
+	require.Nil(t, err)
+	assert.Len(t, assets, 3)
+}
+
+// listingRepository is a ListingRepository that lists the assets without their BMC credentials.
+type listingRepository struct {
+	*selectingRepository
+	listed int
+}
+
+func (l *listingRepository) ListAssets(_ context.Context, _ *model.AssetSelector, _, _ int) (assets []*model.Asset, totalAssets int, err error) {
+	l.listed++
+
+	for _, asset := range l.assets {
+		listed := *asset
+		listed.BMCUsername, listed.BMCPassword = "", ""
+		assets = append(assets, &listed)
+	}
+
+	return assets, len(assets), nil
+}
+
+func Test_ListAssets(t *testing.T) {
+	repository := &listingRepository{
+		selectingRepository: &selectingRepository{
+			assets: []*model.Asset{
+				{ID: "1", Vendor: "dell", Model: "r6515", BMCUsername: "root", BMCPassword: "calvin"},
+				{ID: "2", Vendor: "dell", Model: "r640", BMCUsername: "root", BMCPassword: "calvin"},
+				{ID: "3", Vendor: "supermicro", Model: "x11dph-t", BMCUsername: "ADMIN", BMCPassword: "ADMIN"},
+			},
+		},
+	}
+
+	// the assets are listed through the repository wrappers without credentials, the selector is applied to the assets listed.
+	wrapped := WithCredentialFallback(repository, []DefaultCredential{{Name: "dell", Vendor: "dell", Username: "root", Password: "calvin"}})
+
+	assets, total, err := ListAssets(context.TODO(), wrapped, &model.AssetSelector{Vendor: "dell", Model: "r640"}, 1, 10)
+	require.Nil(t, err)
+	require.Len(t, assets, 1)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 1, repository.listed)
+	assert.Equal(t, "2", assets[0].ID)
+	assert.Empty(t, assets[0].BMCUsername)
+	assert.Empty(t, assets[0].BMCCredentials)
+
+	// repositories that don't list assets without credentials return the assets selected.
+	mockstore, err := mock.New(3)
+	require.Nil(t, err)
+
+	assets, _, err = ListAssets(context.TODO(), mockstore, nil, 1, 10)
+	require.Nil(t, err)
+	assert.Len(t, assets, 3)
+}
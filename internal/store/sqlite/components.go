@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	common "github.com/metal-toolbox/bmc-common"
+)
+
+// component is a device component row to be inserted in the components table.
+type component struct {
+	slug string
+	common.Common
+}
+
+// deviceComponents returns the components in the device inventory as a flat list.
+func deviceComponents(device *common.Device) []*component {
+	if device == nil {
+		return nil
+	}
+
+	components := []*component{}
+
+	add := func(slug string, c *common.Common) {
+		components = append(components, &component{slug: slug, Common: *c})
+	}
+
+	if device.BIOS != nil {
+		add(common.SlugBIOS, &device.BIOS.Common)
+	}
+
+	if device.BMC != nil {
+		add(common.SlugBMC, &device.BMC.Common)
+	}
+
+	if device.Mainboard != nil {
+		add(common.SlugMainboard, &device.Mainboard.Common)
+	}
+
+	for _, c := range device.CPLDs {
+		if c != nil {
+			add(common.SlugCPLD, &c.Common)
+		}
+	}
+
+	for _, c := range device.TPMs {
+		if c != nil {
+			add(common.SlugTPM, &c.Common)
+		}
+	}
+
+	for _, c := range device.GPUs {
+		if c != nil {
+			add(common.SlugGPU, &c.Common)
+		}
+	}
+
+	for _, c := range device.CPUs {
+		if c != nil {
+			add(common.SlugCPU, &c.Common)
+		}
+	}
+
+	for _, c := range device.Memory {
+		if c != nil {
+			add(common.SlugPhysicalMem, &c.Common)
+		}
+	}
+
+	for _, c := range device.NICs {
+		if c != nil {
+			add(common.SlugNIC, &c.Common)
+		}
+	}
+
+	for _, c := range device.Drives {
+		if c != nil {
+			add(common.SlugDrive, &c.Common)
+		}
+	}
+
+	for _, c := range device.StorageControllers {
+		if c != nil {
+			add(common.SlugStorageController, &c.Common)
+		}
+	}
+
+	for _, c := range device.PSUs {
+		if c != nil {
+			add(common.SlugPSU, &c.Common)
+		}
+	}
+
+	for _, c := range device.Enclosures {
+		if c != nil {
+			add(common.SlugEnclosure, &c.Common)
+		}
+	}
+
+	return components
+}
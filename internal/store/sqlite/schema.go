@@ -0,0 +1,79 @@
+package sqlite
+
+// migrations are the schema changes applied in order to the database,
+// the number of migrations applied is tracked in the database user_version pragma.
+//
+// Migrations are append only, existing entries must not be modified.
+var migrations = []string{
+	`
+CREATE TABLE servers (
+	id           TEXT PRIMARY KEY,
+	facility     TEXT NOT NULL DEFAULT '',
+	vendor       TEXT NOT NULL DEFAULT '',
+	model        TEXT NOT NULL DEFAULT '',
+	serial       TEXT NOT NULL DEFAULT '',
+	bmc_address  TEXT NOT NULL DEFAULT '',
+	bmc_username TEXT NOT NULL DEFAULT '',
+	bmc_password TEXT NOT NULL DEFAULT '',
+	created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE revisions (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	server_id    TEXT NOT NULL REFERENCES servers(id) ON DELETE CASCADE,
+	collect_kind TEXT NOT NULL,
+	vendor       TEXT NOT NULL DEFAULT '',
+	model        TEXT NOT NULL DEFAULT '',
+	serial       TEXT NOT NULL DEFAULT '',
+	collected_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX revisions_server_id ON revisions(server_id, id);
+
+CREATE TABLE components (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	revision_id   INTEGER NOT NULL REFERENCES revisions(id) ON DELETE CASCADE,
+	slug          TEXT NOT NULL,
+	vendor        TEXT NOT NULL DEFAULT '',
+	model         TEXT NOT NULL DEFAULT '',
+	serial        TEXT NOT NULL DEFAULT '',
+	product_name  TEXT NOT NULL DEFAULT '',
+	description   TEXT NOT NULL DEFAULT '',
+	status_health TEXT NOT NULL DEFAULT '',
+	status_state  TEXT NOT NULL DEFAULT '',
+	metadata      TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX components_revision_id ON components(revision_id);
+
+CREATE TABLE firmware (
+	component_id INTEGER PRIMARY KEY REFERENCES components(id) ON DELETE CASCADE,
+	installed    TEXT NOT NULL DEFAULT '',
+	available    TEXT NOT NULL DEFAULT '',
+	software_id  TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE bios_config (
+	revision_id INTEGER NOT NULL REFERENCES revisions(id) ON DELETE CASCADE,
+	key         TEXT NOT NULL,
+	value       TEXT NOT NULL,
+	PRIMARY KEY (revision_id, key)
+);
+
+CREATE TABLE collection_errors (
+	revision_id INTEGER NOT NULL REFERENCES revisions(id) ON DELETE CASCADE,
+	kind        TEXT NOT NULL,
+	error       TEXT NOT NULL,
+	PRIMARY KEY (revision_id, kind)
+);
+
+-- latest_revisions lists the most recent revision for each server.
+CREATE VIEW latest_revisions AS
+	SELECT r.* FROM revisions r
+	WHERE r.id = (SELECT MAX(id) FROM revisions WHERE server_id = r.server_id);
+`,
+	// bmc_credential is the key of the BMC credential in the secret provider.
+	`
+ALTER TABLE servers ADD COLUMN bmc_credential TEXT NOT NULL DEFAULT '';
+`,
+}
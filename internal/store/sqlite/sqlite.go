@@ -0,0 +1,471 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
+	"github.com/metal-toolbox/alloy/internal/model"
+
+	// registers the sqlite database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+var (
+	ErrAssetNotFound = errors.New("not found")
+	ErrDatabase      = errors.New("sqlite database error")
+	ErrAssetUpdate   = errors.New("error recording asset revision")
+)
+
+// Store is an inventory store backed by an embedded SQLite database.
+//
+// Assets and their BMC credentials are read from the servers table,
+// each AssetUpdate records the collected data as a new revision of the server,
+// with the components, firmware, BIOS configuration and collection errors in their own tables.
+type Store struct {
+	db      *sql.DB
+	logger  *logrus.Entry
+	appKind model.AppKind
+}
+
+// New returns a SQLite store with the database at the given path,
+// the database is created and its schema migrated if required.
+func New(ctx context.Context, appKind model.AppKind, dbFile string, logger *logrus.Logger) (*Store, error) {
+	if dbFile == "" {
+		return nil, errors.Wrap(ErrDatabase, "database file not defined")
+	}
+
+	// the database is created with restricted permissions since it holds BMC credentials.
+	f, err := os.OpenFile(dbFile, os.O_RDONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	f.Close()
+
+	db, err := sql.Open("sqlite", dataSourceName(dbFile))
+	if err != nil {
+		return nil, errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	// SQLite permits a single writer, serialize access instead of failing on a locked database.
+	db.SetMaxOpenConns(1)
+
+	s := &Store{
+		db:      db,
+		appKind: appKind,
+		logger:  logger.WithField("component", "store.sqlite"),
+	}
+
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// dataSourceName returns the SQLite URI for the database file with the connection pragmas,
+// the file path is escaped for the characters in the path not to be read as the URI query, fragment or escapes.
+func dataSourceName(dbFile string) string {
+	dsn := &url.URL{
+		Scheme: "file",
+		Opaque: (&url.URL{Path: dbFile}).EscapedPath(),
+		RawQuery: url.Values{
+			"_pragma": {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"},
+		}.Encode(),
+	}
+
+	return dsn.String()
+}
+
+// Kind returns the repository store kind.
+func (s *Store) Kind() model.StoreKind {
+	return model.StoreKindSqlite
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies the schema migrations not yet applied to the database.
+func (s *Store) migrate(ctx context.Context) error {
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	if version > len(migrations) {
+		return errors.Wrap(
+			ErrDatabase,
+			fmt.Sprintf("database schema version %d is newer than supported version %d", version, len(migrations)),
+		)
+	}
+
+	for idx := version; idx < len(migrations); idx++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return errors.Wrap(ErrDatabase, err.Error())
+		}
+
+		if _, err := tx.ExecContext(ctx, migrations[idx]); err != nil {
+			_ = tx.Rollback()
+			return errors.Wrap(ErrDatabase, fmt.Sprintf("schema migration %d: %s", idx+1, err.Error()))
+		}
+
+		// pragma statements don't accept bind parameters.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", idx+1)); err != nil {
+			_ = tx.Rollback()
+			return errors.Wrap(ErrDatabase, err.Error())
+		}
+
+		if err := tx.Commit(); err != nil {
+			return errors.Wrap(ErrDatabase, err.Error())
+		}
+
+		s.logger.WithField("version", idx+1).Debug("database schema migrated")
+	}
+
+	return nil
+}
+
+const selectServers = `SELECT id, facility, vendor, model, serial, bmc_address, bmc_username, bmc_password, bmc_credential FROM servers`
+
+// AssetByID returns one asset from the inventory identified by its identifier.
+func (s *Store) AssetByID(ctx context.Context, assetID string, fetchBmcCredentials bool) (*model.Asset, error) {
+	row := s.db.QueryRowContext(ctx, selectServers+` WHERE id = ?`, assetID)
+
+	asset, err := scanAsset(row, fetchBmcCredentials)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.Wrap(ErrAssetNotFound, assetID)
+		}
+
+		return nil, err
+	}
+
+	return asset, nil
+}
+
+// AssetsByOffsetLimit returns the assets at the given offset (page), limit values, ordered by the asset ID.
+func (s *Store) AssetsByOffsetLimit(ctx context.Context, offset, limit int) (assets []*model.Asset, totalAssets int, err error) {
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM servers`).Scan(&totalAssets); err != nil {
+		return nil, 0, errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	if offset < 1 || limit < 1 {
+		return nil, totalAssets, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, selectServers+` ORDER BY id LIMIT ? OFFSET ?`, limit, (offset-1)*limit)
+	if err != nil {
+		return nil, 0, errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		asset, err := scanAsset(rows, true)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		assets = append(assets, asset)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	for _, asset := range assets {
+		if asset.LastCollectedAt, err = s.lastCollectedAt(ctx, asset.ID); err != nil {
+			return nil, 0, err
+		}
+
+		if asset.LastCollectFailed, err = s.lastCollectFailed(ctx, asset.ID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return assets, totalAssets, nil
+}
+
+// lastCollectedAt returns the time the most recent revision for the server with the inventory collected was recorded,
+// a zero time is returned when the server has no such revisions.
+func (s *Store) lastCollectedAt(ctx context.Context, serverID string) (time.Time, error) {
+	var collectedAt time.Time
+
+	query, args := inventoryFailedQuery(
+		`SELECT collected_at FROM revisions r WHERE server_id = ? AND NOT EXISTS (
+			SELECT 1 FROM collection_errors e WHERE e.revision_id = r.id AND e.kind IN (%s)
+		) ORDER BY id DESC LIMIT 1`,
+		serverID,
+	)
+
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&collectedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	return collectedAt, nil
+}
+
+// lastCollectFailed returns true when the inventory was not collected in the most recent revision for the server,
+// revisions of collections skipped on an open BMC circuit are not considered.
+func (s *Store) lastCollectFailed(ctx context.Context, serverID string) (bool, error) {
+	var failed bool
+
+	query, args := inventoryFailedQuery(
+		`SELECT EXISTS (
+			SELECT 1 FROM collection_errors e WHERE e.revision_id = (
+				SELECT MAX(id) FROM revisions r WHERE server_id = ? AND NOT EXISTS (
+					SELECT 1 FROM collection_errors s WHERE s.revision_id = r.id AND s.kind = ?
+				)
+			) AND e.kind IN (%s)
+		)`,
+		serverID,
+		string(outofband.CircuitOpenError),
+	)
+
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&failed); err != nil {
+		return false, errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	return failed, nil
+}
+
+// inventoryFailedQuery returns the query with the collection error kinds set when the inventory was not collected,
+// included as parameters in the query IN clause, and the query arguments - the given arguments followed by the error kinds.
+func inventoryFailedQuery(query string, args ...any) (string, []any) {
+	placeholders := make([]string, 0, len(outofband.InventoryFailedErrors))
+
+	for _, kind := range outofband.InventoryFailedErrors {
+		args = append(args, string(kind))
+		placeholders = append(placeholders, "?")
+	}
+
+	return fmt.Sprintf(query, strings.Join(placeholders, ", ")), args
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAsset(row scanner, withBmcCredentials bool) (*model.Asset, error) {
+	var address string
+
+	asset := &model.Asset{}
+
+	err := row.Scan(
+		&asset.ID,
+		&asset.Facility,
+		&asset.Vendor,
+		&asset.Model,
+		&asset.Serial,
+		&address,
+		&asset.BMCUsername,
+		&asset.BMCPassword,
+		&asset.BMCCredentialKey,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		return nil, errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	if !withBmcCredentials {
+		asset.BMCUsername = ""
+		asset.BMCPassword = ""
+		asset.BMCCredentialKey = ""
+
+		// the BMC address is included when valid, without the credentials it is not required.
+		if address != "" {
+			asset.BMCAddress, _ = model.ParseBMCAddress(address)
+		}
+
+		return asset, nil
+	}
+
+	if address != "" {
+		asset.BMCAddress, err = model.ParseBMCAddress(address)
+		if err != nil {
+			return nil, errors.Wrap(ErrDatabase, "invalid BMC address for server "+asset.ID+": "+err.Error())
+		}
+	}
+
+	return asset, nil
+}
+
+// AssetUpdate records the collected data for the asset as a new revision.
+//
+// Previous revisions are retained, the latest_revisions view lists the most recent revision for each server.
+func (s *Store) AssetUpdate(ctx context.Context, asset *model.Asset) error {
+	if asset == nil {
+		return errors.Wrap(ErrAssetUpdate, "asset object is nil")
+	}
+
+	if _, err := uuid.Parse(asset.ID); err != nil {
+		return errors.Wrap(ErrAssetUpdate, "invalid asset ID: "+asset.ID)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(ErrDatabase, err.Error())
+	}
+
+	revisionID, err := s.insertRevision(ctx, tx, asset)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"assetID":  asset.ID,
+		"revision": revisionID,
+	}).Debug("asset revision recorded")
+
+	return nil
+}
+
+func (s *Store) insertRevision(ctx context.Context, tx *sql.Tx, asset *model.Asset) (int64, error) {
+	var exists int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM servers WHERE id = ?`, asset.ID).Scan(&exists); err != nil {
+		return 0, errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	if exists == 0 {
+		return 0, errors.Wrap(ErrAssetNotFound, asset.ID)
+	}
+
+	dvendor, dmodel, dserial := asset.Vendor, asset.Model, asset.Serial
+	if asset.Inventory != nil {
+		dvendor, dmodel, dserial = asset.Inventory.Vendor, asset.Inventory.Model, asset.Inventory.Serial
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO revisions (server_id, collect_kind, vendor, model, serial, collected_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		asset.ID,
+		string(s.appKind),
+		dvendor,
+		dmodel,
+		dserial,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return 0, errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	revisionID, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	for _, c := range deviceComponents(asset.Inventory) {
+		if err := insertComponent(ctx, tx, revisionID, c); err != nil {
+			return 0, err
+		}
+	}
+
+	for key, value := range asset.BiosConfig {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO bios_config (revision_id, key, value) VALUES (?, ?, ?)`,
+			revisionID,
+			key,
+			value,
+		); err != nil {
+			return 0, errors.Wrap(ErrAssetUpdate, err.Error())
+		}
+	}
+
+	for kind, cerr := range asset.Errors {
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO collection_errors (revision_id, kind, error) VALUES (?, ?, ?)`,
+			revisionID,
+			kind,
+			cerr,
+		); err != nil {
+			return 0, errors.Wrap(ErrAssetUpdate, err.Error())
+		}
+	}
+
+	return revisionID, nil
+}
+
+func insertComponent(ctx context.Context, tx *sql.Tx, revisionID int64, c *component) error {
+	var health, state string
+	if c.Status != nil {
+		health, state = c.Status.Health, c.Status.State
+	}
+
+	metadata := []byte("{}")
+	if len(c.Metadata) > 0 {
+		var err error
+
+		metadata, err = json.Marshal(c.Metadata)
+		if err != nil {
+			return errors.Wrap(ErrAssetUpdate, err.Error())
+		}
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO components
+			(revision_id, slug, vendor, model, serial, product_name, description, status_health, status_state, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		revisionID,
+		c.slug,
+		c.Vendor,
+		c.Model,
+		c.Serial,
+		c.ProductName,
+		c.Description,
+		health,
+		state,
+		string(metadata),
+	)
+	if err != nil {
+		return errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	if c.Firmware == nil {
+		return nil
+	}
+
+	componentID, err := result.LastInsertId()
+	if err != nil {
+		return errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO firmware (component_id, installed, available, software_id) VALUES (?, ?, ?, ?)`,
+		componentID,
+		c.Firmware.Installed,
+		c.Firmware.Available,
+		c.Firmware.SoftwareID,
+	); err != nil {
+		return errors.Wrap(ErrAssetUpdate, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/metal-toolbox/bmc-common"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/device/outofband"
+	"github.com/metal-toolbox/alloy/internal/model"
+)
+
+var testServers = []*model.Asset{
+	{ID: "7b8a090d-3900-4c45-89e1-041044d27402", Vendor: "dell", Facility: "ac1", BMCUsername: "root", BMCPassword: "calvin"},
+	{ID: "a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2", Vendor: "supermicro", Facility: "ac1", BMCUsername: "ADMIN", BMCPassword: "ADMIN"},
+	{ID: "f0c8e4ac-5cce-4370-93ce-e0a5ec0e5c4c", Vendor: "dell", Facility: "ac2", BMCUsername: "root", BMCPassword: "calvin"},
+}
+
+func testStore(t *testing.T) (*Store, string) {
+	t.Helper()
+
+	dbFile := filepath.Join(t.TempDir(), "alloy.db")
+
+	store, err := New(context.TODO(), model.AppKindOutOfBand, dbFile, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { store.Close() })
+
+	for idx, server := range testServers {
+		_, err := store.db.Exec(
+			`INSERT INTO servers (id, facility, vendor, bmc_address, bmc_username, bmc_password) VALUES (?, ?, ?, ?, ?, ?)`,
+			server.ID,
+			server.Facility,
+			server.Vendor,
+			fmt.Sprintf("192.168.1.%d", idx+1),
+			server.BMCUsername,
+			server.BMCPassword,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return store, dbFile
+}
+
+func Test_Migrate(t *testing.T) {
+	store, dbFile := testStore(t)
+	store.Close()
+
+	// re-opening an existing database retains its data
+	store, err := New(context.TODO(), model.AppKindOutOfBand, dbFile, logrus.New())
+	require.Nil(t, err)
+
+	defer store.Close()
+
+	var version int
+	require.Nil(t, store.db.QueryRow("PRAGMA user_version").Scan(&version))
+	assert.Equal(t, len(migrations), version)
+
+	_, total, err := store.AssetsByOffsetLimit(context.TODO(), 1, 10)
+	require.Nil(t, err)
+	assert.Equal(t, len(testServers), total)
+}
+
+func Test_New_EscapedPath(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"alloy?mode=ro.db", "alloy#1.db", "alloy%20.db", "alloy db.db"} {
+		t.Run(name, func(t *testing.T) {
+			dbFile := filepath.Join(dir, name)
+
+			store, err := New(context.TODO(), model.AppKindOutOfBand, dbFile, logrus.New())
+			require.Nil(t, err)
+
+			defer store.Close()
+
+			// the pragmas are applied to the database at the path as given.
+			var journalMode string
+			require.Nil(t, store.db.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+			assert.Equal(t, "wal", journalMode)
+
+			var file string
+			require.Nil(t, store.db.QueryRow("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&file))
+			assert.Equal(t, dbFile, file)
+		})
+	}
+
+	// no database was created at the path truncated at the query, fragment or unescaped.
+	entries, err := os.ReadDir(dir)
+	require.Nil(t, err)
+
+	for _, entry := range entries {
+		assert.Contains(t, []string{"alloy?mode=ro.db", "alloy#1.db", "alloy%20.db", "alloy db.db"},
+			strings.TrimSuffix(strings.TrimSuffix(entry.Name(), "-wal"), "-shm"))
+	}
+}
+
+func Test_AssetByID(t *testing.T) {
+	store, _ := testStore(t)
+
+	asset, err := store.AssetByID(context.TODO(), "a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2", true)
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.2", asset.BMCAddress.String())
+	assert.Equal(t, "ADMIN", asset.BMCUsername)
+	assert.Equal(t, "supermicro", asset.Vendor)
+
+	asset, err = store.AssetByID(context.TODO(), "a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2", false)
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.1.2", asset.BMCAddress.String())
+	assert.Empty(t, asset.BMCPassword)
+
+	_, err = store.AssetByID(context.TODO(), "1ce1b6c1-1b1f-4b2a-8a3c-0c55f07c3f10", true)
+	assert.ErrorIs(t, err, ErrAssetNotFound)
+}
+
+func Test_AssetsByOffsetLimit(t *testing.T) {
+	store, _ := testStore(t)
+
+	cases := []struct {
+		name     string
+		offset   int
+		limit    int
+		expected int
+	}{
+		{"first page", 1, 2, 2},
+		{"last page", 2, 2, 1},
+		{"beyond last page", 3, 2, 0},
+		{"limit higher than total", 1, 10, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assets, total, err := store.AssetsByOffsetLimit(context.TODO(), tc.offset, tc.limit)
+			require.Nil(t, err)
+			assert.Equal(t, 3, total)
+			assert.Equal(t, tc.expected, len(assets))
+		})
+	}
+}
+
+func Test_AssetUpdate(t *testing.T) {
+	store, _ := testStore(t)
+
+	asset, err := store.AssetByID(context.TODO(), "7b8a090d-3900-4c45-89e1-041044d27402", true)
+	require.Nil(t, err)
+
+	asset.Inventory = &common.Device{
+		Common: common.Common{Vendor: "dell", Serial: "abc123"},
+		BIOS:   &common.BIOS{Common: common.Common{Vendor: "dell", Firmware: &common.Firmware{Installed: "2.6.4"}}},
+		Drives: []*common.Drive{
+			{Common: common.Common{Vendor: "intel", Serial: "d1"}},
+			nil,
+			{Common: common.Common{Vendor: "intel", Serial: "d2", Status: &common.Status{Health: "OK"}}},
+		},
+	}
+	asset.BiosConfig = map[string]string{"boot_mode": "Uefi"}
+	asset.Errors = map[string]string{"tpm": "not supported"}
+
+	// each update is recorded as a revision
+	for i := 0; i < 2; i++ {
+		require.Nil(t, store.AssetUpdate(context.TODO(), asset))
+	}
+
+	// the latest revision has one drive less
+	asset.Inventory.Drives = asset.Inventory.Drives[:1]
+	require.Nil(t, store.AssetUpdate(context.TODO(), asset))
+
+	var revisions int
+	require.Nil(t, store.db.QueryRow(`SELECT COUNT(*) FROM revisions WHERE server_id = ?`, asset.ID).Scan(&revisions))
+	assert.Equal(t, 3, revisions)
+
+	var latest, components int
+	require.Nil(t, store.db.QueryRow(`SELECT id FROM latest_revisions WHERE server_id = ?`, asset.ID).Scan(&latest))
+	require.Nil(t, store.db.QueryRow(`SELECT COUNT(*) FROM components WHERE revision_id = ?`, latest).Scan(&components))
+	assert.Equal(t, 2, components)
+
+	var installed string
+	require.Nil(t, store.db.QueryRow(
+		`SELECT f.installed FROM firmware f JOIN components c ON c.id = f.component_id WHERE c.revision_id = ? AND c.slug = ?`,
+		latest,
+		common.SlugBIOS,
+	).Scan(&installed))
+	assert.Equal(t, "2.6.4", installed)
+
+	var bootMode, tpmErr string
+	require.Nil(t, store.db.QueryRow(`SELECT value FROM bios_config WHERE revision_id = ? AND key = 'boot_mode'`, latest).Scan(&bootMode))
+	require.Nil(t, store.db.QueryRow(`SELECT error FROM collection_errors WHERE revision_id = ? AND kind = 'tpm'`, latest).Scan(&tpmErr))
+	assert.Equal(t, "Uefi", bootMode)
+	assert.Equal(t, "not supported", tpmErr)
+
+	// the listed assets include the time of the latest revision
+	assets, _, err := store.AssetsByOffsetLimit(context.TODO(), 1, 10)
+	require.Nil(t, err)
+
+	for _, listed := range assets {
+		if listed.ID == asset.ID {
+			assert.WithinDuration(t, time.Now(), listed.LastCollectedAt, time.Minute)
+		} else {
+			assert.True(t, listed.LastCollectedAt.IsZero())
+		}
+	}
+
+	err = store.AssetUpdate(context.TODO(), &model.Asset{ID: "1ce1b6c1-1b1f-4b2a-8a3c-0c55f07c3f10"})
+	assert.ErrorIs(t, err, ErrAssetNotFound)
+
+	err = store.AssetUpdate(context.TODO(), &model.Asset{ID: "foo"})
+	assert.ErrorIs(t, err, ErrAssetUpdate)
+}
+
+func Test_AssetUpdate_LastCollectFailed(t *testing.T) {
+	store, _ := testStore(t)
+
+	assetID := "a9bf4e60-0a1b-4a1d-8c5f-5d3cd3a4f1e2"
+
+	listed := func() *model.Asset {
+		t.Helper()
+
+		assets, _, err := store.AssetsByOffsetLimit(context.TODO(), 1, 10)
+		require.Nil(t, err)
+
+		for _, asset := range assets {
+			if asset.ID == assetID {
+				return asset
+			}
+		}
+
+		t.Fatal("asset not listed: " + assetID)
+
+		return nil
+	}
+
+	// collected with a BIOS configuration error
+	asset := &model.Asset{
+		ID:        assetID,
+		Inventory: &common.Device{Common: common.Common{Vendor: "supermicro", Serial: "abc123"}},
+		Errors:    map[string]string{string(outofband.GetBiosConfigError): "timeout"},
+	}
+	require.Nil(t, store.AssetUpdate(context.TODO(), asset))
+
+	collected := listed()
+	assert.False(t, collected.LastCollectFailed)
+	assert.False(t, collected.LastCollectedAt.IsZero())
+
+	skip := &model.Asset{ID: assetID, Errors: map[string]string{string(outofband.CircuitOpenError): "circuit open"}}
+
+	// the collection skipped on an open BMC circuit is not recorded as failed
+	require.Nil(t, store.AssetUpdate(context.TODO(), skip))
+
+	skipped := listed()
+	assert.False(t, skipped.LastCollectFailed)
+	assert.Equal(t, collected.LastCollectedAt, skipped.LastCollectedAt)
+
+	// the login failed, the last collected time is retained
+	require.Nil(t, store.AssetUpdate(context.TODO(), &model.Asset{ID: assetID, Errors: map[string]string{string(outofband.LoginError): "unauthorized"}}))
+
+	failed := listed()
+	assert.True(t, failed.LastCollectFailed)
+	assert.Equal(t, collected.LastCollectedAt, failed.LastCollectedAt)
+
+	// the last collection remains failed when a following collection is skipped
+	require.Nil(t, store.AssetUpdate(context.TODO(), skip))
+
+	skipped = listed()
+	assert.True(t, skipped.LastCollectFailed)
+	assert.Equal(t, collected.LastCollectedAt, skipped.LastCollectedAt)
+}
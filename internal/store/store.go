@@ -5,9 +5,12 @@ import (
 
 	"github.com/metal-toolbox/alloy/internal/app"
 	"github.com/metal-toolbox/alloy/internal/model"
+	"github.com/metal-toolbox/alloy/internal/secrets"
 	"github.com/metal-toolbox/alloy/internal/store/csv"
+	"github.com/metal-toolbox/alloy/internal/store/file"
 	"github.com/metal-toolbox/alloy/internal/store/fleetdb"
 	"github.com/metal-toolbox/alloy/internal/store/mock"
+	"github.com/metal-toolbox/alloy/internal/store/sqlite"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -20,7 +23,8 @@ type Repository interface {
 	// Kind returns the repository store kind.
 	Kind() model.StoreKind
 
-	// AssetByID returns one asset from the inventory identified by its identifier.
+	// AssetByID returns one asset from the inventory identified by its identifier,
+	// the BMC address is included when known to the store, the BMC credentials when fetchBmcCredentials is set.
 	AssetByID(ctx context.Context, assetID string, fetchBmcCredentials bool) (*model.Asset, error)
 
 	AssetsByOffsetLimit(ctx context.Context, offset, limit int) (assets []*model.Asset, totalAssets int, err error)
@@ -29,13 +33,51 @@ type Repository interface {
 	AssetUpdate(ctx context.Context, asset *model.Asset) error
 }
 
+// NewRepository returns the Repository for the store kind.
+//
+// With a secret provider configured, the BMC credentials referenced by key in the store are resolved from the provider.
+// For out of band collection with a BMC credentials file configured,
+// the default credentials in the file are included as the fallback BMC credentials of the assets.
 func NewRepository(ctx context.Context, storeKind model.StoreKind, appKind model.AppKind, cfg *app.Configuration, logger *logrus.Logger) (Repository, error) {
+	repository, err := newRepository(ctx, storeKind, appKind, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.SecretsOptions != nil && cfg.SecretsOptions.Provider != "" {
+		provider, err := secrets.NewProvider(cfg.SecretsOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		repository = WithSecretProvider(repository, provider, logger)
+	}
+
+	if appKind != model.AppKindOutOfBand || cfg.OutofbandOptions == nil || cfg.OutofbandOptions.CredentialsFile == "" {
+		return repository, nil
+	}
+
+	defaults, err := LoadDefaultCredentials(cfg.OutofbandOptions.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return WithCredentialFallback(repository, defaults), nil
+}
+
+func newRepository(ctx context.Context, storeKind model.StoreKind, appKind model.AppKind, cfg *app.Configuration, logger *logrus.Logger) (Repository, error) {
 	switch storeKind {
 	case model.StoreKindFleetDB:
 		return fleetdb.New(ctx, appKind, cfg.FleetDBAPIOptions, logger)
 
 	case model.StoreKindCsv:
-		return csv.New(ctx, cfg.CsvFile, logger)
+		return csv.New(ctx, cfg.CsvFile, cfg.CsvOutputDir, logger)
+
+	case model.StoreKindFile:
+		return file.New(ctx, cfg.FileStoreDir, listsCollectionStatus(cfg), logger)
+
+	case model.StoreKindSqlite:
+		return sqlite.New(ctx, appKind, cfg.SqliteFile, logger)
 
 	case model.StoreKindMock:
 		assets := 10
@@ -45,3 +87,13 @@ func NewRepository(ctx context.Context, storeKind model.StoreKind, appKind model
 		return nil, errors.Wrap(ErrStore, "unsupported store kind: "+string(storeKind))
 	}
 }
+
+// listsCollectionStatus returns true when the assets listed are to include the time they were last collected,
+// for the collections ordered by staleness, or the assets selected by the time they were last collected.
+func listsCollectionStatus(cfg *app.Configuration) bool {
+	if cfg.OutofbandOptions != nil && cfg.OutofbandOptions.Schedule.Order == app.ScheduleOrderStaleness {
+		return true
+	}
+
+	return cfg.AssetSelector.SelectsCollected()
+}
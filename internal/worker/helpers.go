@@ -11,7 +11,13 @@ import (
 	"github.com/metal-toolbox/alloy/internal/metrics"
 )
 
+// concurrencyLimit returns true when the conditions dispatched reached the concurrency,
+// as adjusted by the adaptive concurrency when enabled.
 func (w *Worker) concurrencyLimit() bool {
+	if w.adaptive != nil {
+		return int(w.dispatched) >= w.adaptive.Limit()
+	}
+
 	return int(w.dispatched) >= w.concurrency
 }
 
@@ -65,3 +71,59 @@ func (w *Worker) eventNak(event events.Message) {
 		w.logger.WithError(err).Warn("event Nak error")
 	}
 }
+
+// eventNakWithDelay naks the event for it to be redelivered after the delay,
+// the event is nak'ed for immediate redelivery when it is not a NATS message.
+func (w *Worker) eventNakWithDelay(event events.Message, delay time.Duration) {
+	msg, err := events.AsNatsMsg(event)
+	if err != nil {
+		w.eventNak(event)
+		return
+	}
+
+	if err := msg.NakWithDelay(delay); err != nil {
+		metrics.NATSError("nak")
+		w.logger.WithError(err).Warn("event Nak error")
+	}
+}
+
+// eventLastDelivery returns true when the event is not redelivered once nak'ed, with the given max deliveries of the consumer,
+// the delivery count is not known for events that are not NATS messages.
+func eventLastDelivery(event events.Message, maxDeliver int) bool {
+	msg, err := events.AsNatsMsg(event)
+	if err != nil {
+		return false
+	}
+
+	metadata, err := msg.Metadata()
+	if err != nil {
+		return false
+	}
+
+	return lastDelivery(metadata.NumDelivered, maxDeliver)
+}
+
+// lastDelivery returns true when the delivery is the last of the max deliveries,
+// the deliveries are not limited when the max deliveries is not positive.
+func lastDelivery(numDelivered uint64, maxDeliver int) bool {
+	return maxDeliver > 0 && numDelivered >= uint64(maxDeliver)
+}
+
+// consumerMaxDeliver returns the count of deliveries of a condition configured on the stream consumer,
+// the rivets default is returned when the consumer configuration cannot be looked up.
+func (w *Worker) consumerMaxDeliver() int {
+	js, ok := w.stream.(*events.NatsJetstream)
+	if !ok || w.cfg.NatsOptions == nil || w.cfg.NatsOptions.Stream == nil || w.cfg.NatsOptions.Consumer == nil {
+		return defaultConditionMaxDeliver
+	}
+
+	info, err := events.AsNatsJetStreamContext(js).ConsumerInfo(w.cfg.NatsOptions.Stream.Name, w.cfg.NatsOptions.Consumer.Name)
+	if err != nil {
+		metrics.NATSError("consumer-info")
+		w.logger.WithError(err).Warn("stream consumer info error, the condition deliveries are assumed to be the default")
+
+		return defaultConditionMaxDeliver
+	}
+
+	return info.Config.MaxDeliver
+}
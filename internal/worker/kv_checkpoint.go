@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/metal-toolbox/rivets/v2/events"
+	"github.com/metal-toolbox/rivets/v2/events/pkg/kv"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/alloy/internal/collector"
+)
+
+var (
+	// checkpointsKVBucket holds the checkpoints of the controller sweeps over the assets in the store.
+	checkpointsKVBucket = "alloy-checkpoints"
+)
+
+// kvCheckpointStore is a collector.CheckpointStore that keeps the checkpoint in a key of a NATS KV bucket.
+type kvCheckpointStore struct {
+	kv  nats.KeyValue
+	key string
+}
+
+// NewKVCheckpointStore returns a collector.CheckpointStore that keeps the checkpoint in the given key of the alloy-checkpoints NATS KV bucket.
+func NewKVCheckpointStore(s events.Stream, key string, replicaCount int) (collector.CheckpointStore, error) {
+	kvOptions := []kv.Option{
+		kv.WithDescription("Alloy sweep checkpoints"),
+	}
+
+	if replicaCount > 1 {
+		kvOptions = append(kvOptions, kv.WithReplicas(replicaCount))
+	}
+
+	js, ok := s.(*events.NatsJetstream)
+	if !ok {
+		return nil, errors.New("checkpoint KV store is only supported on NATS")
+	}
+
+	bucket, err := kv.CreateOrBindKVBucket(js, checkpointsKVBucket, kvOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kvCheckpointStore{kv: bucket, key: key}, nil
+}
+
+func (s *kvCheckpointStore) Get(_ context.Context) (*collector.Checkpoint, error) {
+	entry, err := s.kv.Get(s.key)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return nil, nil // nolint:nilnil // a key that does not exist is not an error
+		}
+
+		return nil, err
+	}
+
+	checkpoint := &collector.Checkpoint{}
+	if err := json.Unmarshal(entry.Value(), checkpoint); err != nil {
+		return nil, errors.Wrap(err, "checkpoint "+s.key)
+	}
+
+	return checkpoint, nil
+}
+
+func (s *kvCheckpointStore) Put(_ context.Context, checkpoint *collector.Checkpoint) error {
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.kv.Put(s.key, b)
+
+	return err
+}
+
+func (s *kvCheckpointStore) Delete(_ context.Context) error {
+	if err := s.kv.Delete(s.key); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/metal-toolbox/rivets/v2/events"
+	"github.com/metal-toolbox/rivets/v2/events/pkg/kv"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+
+	"github.com/metal-toolbox/alloy/internal/collector"
+)
+
+var (
+	// circuitsKVBucket holds the BMC circuit state shared by the out of band workers.
+	circuitsKVBucket = "alloy-bmc-circuits"
+
+	// circuitsKVTTL expires the state of BMCs not collected from.
+	circuitsKVTTL = 7 * 24 * time.Hour
+
+	// circuitUpdateAttempts is the count of attempts to update a circuit state updated concurrently by other workers.
+	circuitUpdateAttempts = 5
+)
+
+// kvCircuitStore is a collector.CircuitStore that keeps the BMC circuit state in a NATS KV bucket.
+type kvCircuitStore struct {
+	kv nats.KeyValue
+}
+
+func newKVCircuitStore(s events.Stream, replicaCount int) (*kvCircuitStore, error) {
+	kvOptions := []kv.Option{
+		kv.WithDescription("Alloy BMC circuit breaker state"),
+		kv.WithTTL(circuitsKVTTL),
+	}
+
+	if replicaCount > 1 {
+		kvOptions = append(kvOptions, kv.WithReplicas(replicaCount))
+	}
+
+	js, ok := s.(*events.NatsJetstream)
+	if !ok {
+		return nil, errors.New("circuit KV store is only supported on NATS")
+	}
+
+	bucket, err := kv.CreateOrBindKVBucket(js, circuitsKVBucket, kvOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kvCircuitStore{kv: bucket}, nil
+}
+
+// circuitKey returns the KV key for the BMC address, the ':' in IPv6 addresses is not a valid key character.
+func circuitKey(key string) string {
+	return strings.ReplaceAll(key, ":", "_")
+}
+
+func (s *kvCircuitStore) Get(_ context.Context, key string) (*collector.CircuitState, error) {
+	entry, err := s.kv.Get(circuitKey(key))
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return nil, nil // nolint:nilnil // a key that does not exist is not an error
+		}
+
+		return nil, err
+	}
+
+	state := &collector.CircuitState{}
+	if err := json.Unmarshal(entry.Value(), state); err != nil {
+		return nil, errors.Wrap(err, "circuit state for "+key)
+	}
+
+	return state, nil
+}
+
+// Update applies the update to the circuit state at its current revision,
+// the update is retried when the state was updated by another worker in the meantime.
+func (s *kvCircuitStore) Update(_ context.Context, key string, update func(state *collector.CircuitState)) (*collector.CircuitState, error) {
+	for attempt := 0; attempt < circuitUpdateAttempts; attempt++ {
+		state := &collector.CircuitState{}
+
+		var revision uint64
+
+		entry, err := s.kv.Get(circuitKey(key))
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(entry.Value(), state); err != nil {
+				return nil, errors.Wrap(err, "circuit state for "+key)
+			}
+
+			revision = entry.Revision()
+		case errors.Is(err, nats.ErrKeyNotFound):
+		default:
+			return nil, err
+		}
+
+		update(state)
+
+		b, err := json.Marshal(state)
+		if err != nil {
+			return nil, err
+		}
+
+		// the key is created when it does not exist, and updated only at the revision read otherwise.
+		if revision == 0 {
+			_, err = s.kv.Create(circuitKey(key), b)
+		} else {
+			_, err = s.kv.Update(circuitKey(key), b, revision)
+		}
+
+		if err == nil {
+			return state, nil
+		}
+
+		if !errors.Is(err, nats.ErrKeyExists) {
+			return nil, err
+		}
+	}
+
+	return nil, errors.New("circuit state for " + key + " updated concurrently, attempts exhausted")
+}
@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/collector"
+)
+
+// revisionKV is a nats.KeyValue that keeps the latest value and revision of the keys in memory,
+// the concurrent func is invoked once the value is read, to update the key as another worker would.
+type revisionKV struct {
+	nats.KeyValue
+	values     map[string][]byte
+	revisions  map[string]uint64
+	revision   uint64
+	concurrent func()
+	mu         sync.Mutex
+}
+
+type revisionEntry struct {
+	nats.KeyValueEntry
+	value    []byte
+	revision uint64
+}
+
+func (e *revisionEntry) Value() []byte    { return e.value }
+func (e *revisionEntry) Revision() uint64 { return e.revision }
+
+func (kv *revisionKV) Get(key string) (nats.KeyValueEntry, error) {
+	kv.mu.Lock()
+	value, exists := kv.values[key]
+	entry := &revisionEntry{value: value, revision: kv.revisions[key]}
+	kv.mu.Unlock()
+
+	if concurrent := kv.concurrent; concurrent != nil {
+		kv.concurrent = nil
+		concurrent()
+	}
+
+	if !exists {
+		return nil, nats.ErrKeyNotFound
+	}
+
+	return entry, nil
+}
+
+func (kv *revisionKV) Create(key string, value []byte) (uint64, error) {
+	return kv.Update(key, value, 0)
+}
+
+func (kv *revisionKV) Update(key string, value []byte, last uint64) (uint64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if kv.revisions[key] != last {
+		return 0, nats.ErrKeyExists
+	}
+
+	kv.revision++
+	kv.values[key] = value
+	kv.revisions[key] = kv.revision
+
+	return kv.revision, nil
+}
+
+func Test_kvCircuitStore_Update(t *testing.T) {
+	failure := func(state *collector.CircuitState) { state.Failures++ }
+
+	testcases := []struct {
+		name       string
+		existing   *collector.CircuitState
+		concurrent bool
+		expected   int
+	}{
+		{"key created", nil, false, 1},
+		{"key updated", &collector.CircuitState{Failures: 2}, false, 3},
+		{"key created concurrently", nil, true, 2},
+		{"key updated concurrently", &collector.CircuitState{Failures: 2}, true, 4},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			kv := &revisionKV{values: map[string][]byte{}, revisions: map[string]uint64{}}
+			store := &kvCircuitStore{kv: kv}
+
+			if tc.existing != nil {
+				b, err := json.Marshal(tc.existing)
+				require.Nil(t, err)
+
+				_, err = kv.Create(circuitKey("10.0.0.1"), b)
+				require.Nil(t, err)
+			}
+
+			// another worker counts a failure after the state is read, the update is retried.
+			if tc.concurrent {
+				kv.concurrent = func() {
+					_, err := store.Update(context.TODO(), "10.0.0.1", failure)
+					require.Nil(t, err)
+				}
+			}
+
+			state, err := store.Update(context.TODO(), "10.0.0.1", failure)
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, state.Failures)
+
+			got, err := store.Get(context.TODO(), "10.0.0.1")
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, got.Failures)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/metal-toolbox/rivets/v2/events"
+	"github.com/metal-toolbox/rivets/v2/events/pkg/kv"
+	"github.com/metal-toolbox/rivets/v2/events/registry"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/metal-toolbox/alloy/internal/collector"
+	"github.com/metal-toolbox/alloy/internal/metrics"
+)
+
+var (
+	// shardRefreshInterval is the interval at which the live replicas are listed from the liveness registry.
+	shardRefreshInterval = 10 * time.Second
+)
+
+// ShardMembership registers a controller replica in the liveness registry,
+// and rebalances the replica shard as replicas join and leave the registry.
+//
+// Replicas with the same name share the assets, a replica that stops checking in
+// is removed from the registry once its liveness TTL expires.
+type ShardMembership struct {
+	id     registry.ControllerID
+	name   string
+	kv     nats.KeyValue
+	shard  *collector.Shard
+	logger *logrus.Logger
+}
+
+// NewShardMembership registers the controller replica with the given name in the liveness registry,
+// and returns the ShardMembership with the shard set with the replicas currently live.
+//
+// The caller is expected to invoke Run to keep the registration and shard up to date.
+func NewShardMembership(s events.Stream, name string, replicaCount int, logger *logrus.Logger) (*ShardMembership, error) {
+	js, ok := s.(*events.NatsJetstream)
+	if !ok {
+		return nil, errors.New("sharding is only supported on NATS")
+	}
+
+	opts := []kv.Option{
+		kv.WithTTL(livenessTTL),
+	}
+
+	// any setting of replicaCount (even 1) chokes NATS in non-clustered mode
+	if replicaCount != 1 {
+		opts = append(opts, kv.WithReplicas(replicaCount))
+	}
+
+	bucket, err := kv.CreateOrBindKVBucket(js, registry.RegistryName, opts...)
+	if err != nil {
+		metrics.NATSError("initialize liveness registry")
+		return nil, errors.Wrap(err, "liveness registry")
+	}
+
+	if err := registry.SetHandle(bucket); err != nil {
+		return nil, errors.Wrap(err, "liveness registry")
+	}
+
+	id := registry.GetID(name)
+	if err := registry.RegisterController(id); err != nil {
+		metrics.NATSError("liveness register")
+		return nil, errors.Wrap(err, "liveness registration")
+	}
+
+	m := &ShardMembership{
+		id:     id,
+		name:   name,
+		kv:     bucket,
+		shard:  collector.NewShard(id.String()),
+		logger: logger,
+	}
+
+	m.refresh()
+
+	return m, nil
+}
+
+// Shard returns the shard of the assets owned by this replica.
+func (m *ShardMembership) Shard() *collector.Shard {
+	return m.shard
+}
+
+// Run checks in with the liveness registry and rebalances the shard when replicas join or leave,
+// the replica is removed from the registry when the context is canceled.
+func (m *ShardMembership) Run(ctx context.Context) {
+	checkin := time.NewTicker(checkinCadence)
+	defer checkin.Stop()
+
+	refresh := time.NewTicker(shardRefreshInterval)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case <-checkin.C:
+			if err := registry.ControllerCheckin(m.id); err != nil {
+				m.logger.WithError(err).WithField("id", m.id.String()).Warn("replica checkin failed")
+				metrics.NATSError("liveness checkin")
+
+				if err = refreshWorkerToken(m.id); err != nil {
+					m.logger.WithError(err).WithField("id", m.id.String()).Warn("unable to refresh replica liveness token")
+				}
+			}
+
+		case <-refresh.C:
+			m.refresh()
+
+		case <-ctx.Done():
+			// the replica shares are rebalanced by the other replicas without waiting for the TTL to expire.
+			if err := registry.DeregisterController(m.id); err != nil {
+				m.logger.WithError(err).WithField("id", m.id.String()).Warn("replica de-registration failed")
+			}
+
+			m.logger.Info("shard membership stopping on done context")
+
+			return
+		}
+	}
+}
+
+// refresh lists the live replicas in the registry and rebalances the shard when they changed.
+func (m *ShardMembership) refresh() {
+	keys, err := m.kv.Keys()
+	if err != nil && !errors.Is(err, nats.ErrNoKeysFound) {
+		m.logger.WithError(err).Warn("liveness registry query error, shard unchanged")
+		metrics.NATSError("liveness registry list")
+
+		return
+	}
+
+	replicas := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		if strings.HasPrefix(key, m.name+"/") {
+			replicas = append(replicas, key)
+		}
+	}
+
+	if m.shard.SetReplicas(replicas) {
+		m.logger.WithFields(logrus.Fields{
+			"id":       m.id.String(),
+			"replicas": len(m.shard.Replicas()),
+			"share":    m.shard.Share(),
+		}).Info("shard rebalanced")
+	}
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strings"
 	"sync"
@@ -20,6 +21,7 @@ import (
 	"github.com/metal-toolbox/rivets/v2/events/registry"
 	"github.com/nats-io/nats.go"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
 )
@@ -39,6 +41,17 @@ const (
 	//
 	// This value should be set to less than the event stream Ack timeout value.
 	taskInprogressTick = 3 * time.Minute
+
+	// maxHeld is the count of conditions held on their BMC subnet or rack limit, above which no conditions are pulled.
+	maxHeld = 100
+
+	// deferMinDelay is the minimum delay conditions are redelivered after,
+	// when deferred outside the asset maintenance windows.
+	deferMinDelay = time.Minute
+
+	// defaultConditionMaxDeliver is the count of deliveries of a condition as configured on the stream consumer by rivets,
+	// assumed when the stream consumer configuration cannot be looked up.
+	defaultConditionMaxDeliver = 5
 )
 
 var (
@@ -48,6 +61,10 @@ var (
 
 	errAssetNotFound = errors.New("asset not found in inventory")
 
+	errAssetMismatch = errors.New("condition asset does not match this host")
+
+	errMethodMismatch = errors.New("condition method not fulfilled by this worker")
+
 	errCollector = errors.New("collector error")
 )
 
@@ -56,17 +73,41 @@ type Worker struct {
 	stream       events.Stream
 	id           registry.ControllerID
 	cfg          *app.Configuration
+	breaker      *collector.CircuitBreaker
+	windows      *collector.MaintenanceWindows
+	adaptive     *collector.AdaptiveConcurrency
+	limiter      *collector.BMCLimiter
 	syncWG       *sync.WaitGroup
 	logger       *logrus.Logger
+	appKind      model.AppKind
 	name         string
+	assetID      string
 	facilityCode string
 	concurrency  int
 	replicaCount int
+	maxDeliver   int
 	dispatched   int32
+	paused       bool
+	held         []*conditionEvent
 }
 
+// conditionEvent is a condition message pulled from the stream, with the asset of an out of band condition
+// looked up once without its BMC credentials, for the BMC limits, maintenance windows and the task.
+type conditionEvent struct {
+	msg          events.Message
+	asset        *model.Asset
+	assetErr     error
+	limit        *collector.BMCLimitToken
+	inProgressAt time.Time
+}
+
+// New returns a worker that fulfills inventory conditions for the given app kind.
+//
+// The assetID parameter is required for inband workers, which only fulfill conditions for the host they run on.
 func New(
 	ctx context.Context,
+	appKind model.AppKind,
+	assetID,
 	facilityCode string,
 	replicaCount int,
 	stream events.Stream,
@@ -81,25 +122,69 @@ func New(
 		concurrency = cfg.Concurrency
 	}
 
-	repository, err := store.NewRepository(ctx, cfg.StoreKind, model.AppKindOutOfBand, cfg, logger)
+	switch appKind {
+	case model.AppKindOutOfBand:
+	case model.AppKindInband:
+		if assetID == "" {
+			return nil, errors.New("inband worker requires an asset ID")
+		}
+
+		// inventory is collected from the host one condition at a time.
+		concurrency = 1
+	default:
+		return nil, errors.New("unsupported worker app kind: " + string(appKind))
+	}
+
+	repository, err := store.NewRepository(ctx, cfg.StoreKind, appKind, cfg, logger)
 	if err != nil {
 		return nil, err
 	}
 
+	// maintenance windows limit when BMCs are queried, and so apply to out of band workers.
+	var windows *collector.MaintenanceWindows
+
+	var adaptive *collector.AdaptiveConcurrency
+
+	var limiter *collector.BMCLimiter
+
+	if appKind == model.AppKindOutOfBand && cfg.OutofbandOptions != nil {
+		if windows, err = collector.NewMaintenanceWindows(cfg.OutofbandOptions.MaintenanceWindows); err != nil {
+			return nil, err
+		}
+
+		if limiter, err = collector.NewBMCLimiter(cfg.OutofbandOptions.BMCLimits); err != nil {
+			return nil, err
+		}
+
+		// the conditions in flight are adjusted from the configured concurrency.
+		if cfg.OutofbandOptions.AdaptiveConcurrency.Enabled {
+			adaptive = collector.NewAdaptiveConcurrency(cfg.OutofbandOptions.AdaptiveConcurrency, concurrency, "worker", logger)
+		}
+	}
+
 	return &Worker{
+		appKind:      appKind,
 		name:         id,
+		assetID:      assetID,
 		facilityCode: facilityCode,
 		replicaCount: replicaCount,
 		cfg:          cfg,
 		syncWG:       syncWG,
 		logger:       logger,
 		repository:   repository,
+		windows:      windows,
+		adaptive:     adaptive,
+		limiter:      limiter,
 		stream:       stream,
 		concurrency:  concurrency,
+		maxDeliver:   defaultConditionMaxDeliver,
 	}, nil
 }
 
 func (w *Worker) Run(ctx context.Context) {
+	// the adaptive concurrency stops measuring the BMC query times once the worker returns.
+	defer w.adaptive.Close()
+
 	tickerFetchEvents := time.NewTicker(fetchEventsInterval).C
 
 	if err := w.stream.Open(); err != nil {
@@ -116,6 +201,8 @@ func (w *Worker) Run(ctx context.Context) {
 
 	w.logger.Info("connected to event stream.")
 
+	w.maxDeliver = w.consumerMaxDeliver()
+
 	// register worker in NATS active-controllers kv bucket
 	w.startWorkerLivenessCheckin(ctx)
 
@@ -123,6 +210,16 @@ func (w *Worker) Run(ctx context.Context) {
 		w.logger.WithError(err).Error("failed to create/bind to status kv" + inventoryStatusKVBucket)
 	}
 
+	// the BMC circuit state is shared with the other out of band workers.
+	if w.appKind == model.AppKindOutOfBand && w.cfg.OutofbandOptions != nil && w.cfg.OutofbandOptions.CircuitBreaker.Enabled {
+		circuits, err := newKVCircuitStore(w.stream, w.replicaCount)
+		if err != nil {
+			w.logger.WithError(err).Error("failed to create/bind to circuits kv " + circuitsKVBucket + ", circuit breaker disabled")
+		} else {
+			w.breaker = collector.NewCircuitBreaker(circuits, w.cfg.OutofbandOptions.CircuitBreaker, w.logger)
+		}
+	}
+
 	v := version.Current()
 	w.logger.WithFields(
 		logrus.Fields{
@@ -130,6 +227,7 @@ func (w *Worker) Run(ctx context.Context) {
 			"commit":      v.GitCommit,
 			"branch":      v.GitBranch,
 			"concurrency": w.concurrency,
+			"kind":        w.appKind,
 		},
 	).Info("Alloy controller running")
 
@@ -137,13 +235,12 @@ Loop:
 	for {
 		select {
 		case <-tickerFetchEvents:
-			if w.concurrencyLimit() {
-				continue
-			}
-
-			w.processEvents(ctx)
+			w.fetchEvents(ctx)
 
 		case <-ctx.Done():
+			// the held conditions are returned to the stream, to be redelivered to another worker.
+			w.returnHeld()
+
 			if w.dispatched > 0 {
 				continue
 			}
@@ -153,6 +250,22 @@ Loop:
 	}
 }
 
+// fetchEvents dispatches the held conditions and pulls new conditions from the stream, within the concurrency limit.
+//
+// While the facility is outside its maintenance windows, the conditions are left on the stream to be pulled
+// once the window opens, and the held conditions are kept in flight.
+func (w *Worker) fetchEvents(ctx context.Context) {
+	closed := w.facilityClosed()
+
+	w.dispatchHeld(ctx, closed)
+
+	if closed || w.concurrencyLimit() || len(w.held) >= maxHeld {
+		return
+	}
+
+	w.processEvents(ctx)
+}
+
 func (w *Worker) processEvents(ctx context.Context) {
 	// XXX: consider having a separate context for message retrieval
 	msgs, err := w.stream.PullMsg(ctx, 1)
@@ -178,21 +291,79 @@ func (w *Worker) processEvents(ctx context.Context) {
 			return
 		}
 
-		// spawn msg process handler
-		w.syncWG.Add(1)
+		ce := w.newConditionEvent(ctx, msg)
+
+		// conditions for BMCs in a subnet or rack at the limit are held in flight until it is below the limit,
+		// for the worker to fulfill conditions for BMCs in other subnets, racks meanwhile.
+		if limited := w.acquireBMCLimit(ce); limited != "" {
+			w.held = append(w.held, ce)
+
+			metrics.CollectionsDeferred.With(
+				prometheus.Labels{"stage": "worker", "reason": collector.DeferReasonBMCLimit},
+			).Inc()
+
+			w.logger.WithFields(logrus.Fields{
+				"assetID": ce.asset.ID,
+				"limited": limited,
+				"held":    len(w.held),
+			}).Debug("condition held on BMC limit")
+
+			continue
+		}
+
+		w.dispatch(ctx, ce)
+	}
+}
+
+// dispatch spawns the routine processing the condition, the BMC limit counted for the condition is released once processed.
+func (w *Worker) dispatch(ctx context.Context, ce *conditionEvent) {
+	w.syncWG.Add(1)
+
+	atomic.AddInt32(&w.dispatched, 1)
+
+	go func() {
+		defer w.syncWG.Done()
+		defer atomic.AddInt32(&w.dispatched, -1)
+		defer w.limiter.Release(ce.limit)
+
+		w.processSingleEvent(ctx, ce)
+	}()
+}
+
+// dispatchHeld dispatches the held conditions with their BMC subnet, rack below the limit in the order held,
+// within the concurrency limit. The conditions still held are acked as in progress, for them not to be redelivered.
+func (w *Worker) dispatchHeld(ctx context.Context, closed bool) {
+	remaining := w.held[:0]
+
+	for _, ce := range w.held {
+		if !closed && ctx.Err() == nil && !w.concurrencyLimit() && w.acquireBMCLimit(ce) == "" {
+			w.dispatch(ctx, ce)
+			continue
+		}
+
+		if time.Since(ce.inProgressAt) >= taskInprogressTick {
+			w.eventAckInProgress(ce.msg)
+			ce.inProgressAt = time.Now()
+		}
 
-		go func(msg events.Message) {
-			defer w.syncWG.Done()
+		remaining = append(remaining, ce)
+	}
 
-			atomic.AddInt32(&w.dispatched, 1)
-			defer atomic.AddInt32(&w.dispatched, -1)
+	w.held = remaining
+}
 
-			w.processSingleEvent(ctx, msg)
-		}(msg)
+// returnHeld naks the held conditions for them to be redelivered.
+func (w *Worker) returnHeld() {
+	for _, ce := range w.held {
+		w.eventNak(ce.msg)
 	}
+
+	w.held = nil
 }
 
-func (w *Worker) processSingleEvent(ctx context.Context, e events.Message) {
+func (w *Worker) processSingleEvent(ctx context.Context, ce *conditionEvent) {
+	e := ce.msg
+
 	// extract parent trace context from the event if any.
 	ctx = e.ExtractOtelTraceContext(ctx)
 
@@ -212,6 +383,18 @@ func (w *Worker) processSingleEvent(ctx context.Context, e events.Message) {
 		return
 	}
 
+	// conditions for another host, or of the other inventory kind are returned to the stream
+	// for the worker they are intended for, without publishing a status.
+	if err := w.conditionForWorker(condition); err != nil {
+		w.logger.WithError(err).WithField("conditionID", condition.ID.String()).Debug("condition not for this worker")
+		w.eventNak(e)
+
+		metrics.RegisterEventCounter(false, "nack")
+		metrics.RegisterSpanEvent(span, condition, w.id.String(), "", "sent nack, condition not for this worker", err)
+
+		return
+	}
+
 	// check and see if the task is or has-been handled by another worker
 	currentState, err := rctypes.CheckConditionInProgress(
 		condition.ID.String(),
@@ -259,11 +442,19 @@ func (w *Worker) processSingleEvent(ctx context.Context, e events.Message) {
 		return
 	}
 
-	w.doWork(ctx, condition, e)
+	if w.deferOutsideWindow(ctx, condition, ce) {
+		metrics.RegisterSpanEvent(span, condition, w.id.String(), "", "deferred, outside maintenance window", nil)
+
+		return
+	}
+
+	w.doWork(ctx, condition, ce)
 }
 
 // doWork executes the task and updates the nats JS with the event status along with publishing the task status.
-func (w *Worker) doWork(ctx context.Context, condition *rctypes.Condition, e events.Message) {
+func (w *Worker) doWork(ctx context.Context, condition *rctypes.Condition, ce *conditionEvent) {
+	e := ce.msg
+
 	ctx, span := otel.Tracer(pkgName).Start(
 		ctx,
 		"worker.do",
@@ -295,7 +486,7 @@ func (w *Worker) doWork(ctx context.Context, condition *rctypes.Condition, e eve
 	}
 
 	// update task state, status
-	task.Status = "Collecting inventory outofband for device"
+	task.Status = fmt.Sprintf("Collecting inventory %s for device", task.Parameters.Method)
 	task.SetState(rctypes.Active)
 
 	w.logger.WithFields(logrus.Fields{
@@ -307,7 +498,7 @@ func (w *Worker) doWork(ctx context.Context, condition *rctypes.Condition, e eve
 	publisher.Publish(ctx, task)
 
 	// check no error
-	err = w.runTaskWithMonitor(ctx, task, e)
+	err = w.runTaskWithMonitor(ctx, task, ce)
 	switch err {
 	case nil:
 		// work completed successfully
@@ -393,7 +584,7 @@ func (w *Worker) doWork(ctx context.Context, condition *rctypes.Condition, e eve
 }
 
 // runTaskWithMonitor runs the task method based on the parameters, while ack'ing its progress to the NATS JS.
-func (w *Worker) runTaskWithMonitor(ctx context.Context, task *Task, e events.Message) error {
+func (w *Worker) runTaskWithMonitor(ctx context.Context, task *Task, ce *conditionEvent) error {
 	ctx, span := otel.Tracer(pkgName).Start(
 		ctx,
 		"worker.runTaskWithMonitor",
@@ -418,7 +609,7 @@ func (w *Worker) runTaskWithMonitor(ctx context.Context, task *Task, e events.Me
 		for {
 			select {
 			case <-ticker.C:
-				w.eventAckInProgress(e)
+				w.eventAckInProgress(ce.msg)
 			case <-doneCh:
 				break Loop
 			}
@@ -433,41 +624,225 @@ func (w *Worker) runTaskWithMonitor(ctx context.Context, task *Task, e events.Me
 	defer cancel()
 
 	switch task.Parameters.Method {
+	// the method matches the worker kind, as checked by conditionForWorker.
 	case rctypes.InbandInventory:
-		// close doneCh here until inband inventory method is implemented
-		close(doneCh)
-		return errors.Wrap(errTaskFirmwareParam, "inband inventory collector not implemented")
+		return w.inventoryInband(taskCtx, task, doneCh)
 	case rctypes.OutofbandInventory:
-		return w.inventoryOutofband(taskCtx, task, doneCh)
+		return w.inventoryOutofband(taskCtx, ce, doneCh)
 	default:
 		close(doneCh)
 		return errors.Wrap(errTaskFirmwareParam, "invalid method: "+string(task.Parameters.Method))
 	}
 }
 
-func (w *Worker) inventoryOutofband(ctx context.Context, task *Task, doneCh chan<- struct{}) error {
+// conditionForWorker returns an error when the condition is to be fulfilled by another worker,
+// that is when the condition method is the inventory of the other kind,
+// or when an inband condition is for an asset other than the host this worker runs on.
+//
+// Conditions with invalid parameters are left to be failed by the task.
+func (w *Worker) conditionForWorker(condition *rctypes.Condition) error {
+	task, err := newTaskFromCondition(condition)
+	if err != nil {
+		return nil
+	}
+
+	switch task.Parameters.Method {
+	case rctypes.InbandInventory, rctypes.OutofbandInventory:
+		if string(task.Parameters.Method) != string(w.appKind) {
+			return errors.Wrap(errMethodMismatch, string(task.Parameters.Method))
+		}
+	default:
+		return nil
+	}
+
+	// the inband queryor collects inventory from the host this worker runs on,
+	// the collected data must not be published for another asset.
+	if w.appKind == model.AppKindInband && task.Parameters.AssetID.String() != w.assetID {
+		return errors.Wrap(errAssetMismatch, task.Parameters.AssetID.String())
+	}
+
+	return nil
+}
+
+func (w *Worker) inventoryInband(ctx context.Context, _ *Task, doneCh chan<- struct{}) error {
 	ctx, span := otel.Tracer(pkgName).Start(
 		ctx,
-		"worker.inventoryOutofband",
+		"worker.inventoryInband",
 	)
 	defer span.End()
 
 	defer close(doneCh)
 
-	// fetch asset inventory from inventory store
-	asset, err := w.repository.AssetByID(ctx, task.Parameters.AssetID.String(), true)
+	c, err := collector.NewDeviceCollectorWithStore(w.repository, model.AppKindInband, w.cfg, nil, w.logger)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
-			return errors.Wrap(errAssetNotFound, err.Error())
+		return errors.Wrap(errCollector, err.Error())
+	}
+
+	return c.CollectInband(ctx, &model.Asset{ID: w.assetID}, false)
+}
+
+func (w *Worker) inventoryOutofband(ctx context.Context, ce *conditionEvent, doneCh chan<- struct{}) error {
+	ctx, span := otel.Tracer(pkgName).Start(
+		ctx,
+		"worker.inventoryOutofband",
+	)
+	defer span.End()
+
+	defer close(doneCh)
+
+	// the asset looked up for the condition, the collector looks up its BMC credentials.
+	if ce.assetErr != nil {
+		if strings.Contains(ce.assetErr.Error(), "no rows in result set") {
+			return errors.Wrap(errAssetNotFound, ce.assetErr.Error())
 		}
 
-		return errors.Wrap(model.ErrInventoryQuery, err.Error())
+		return errors.Wrap(model.ErrInventoryQuery, ce.assetErr.Error())
 	}
 
-	c, err := collector.NewDeviceCollectorWithStore(w.repository, w.cfg.AppKind, w.logger)
+	asset := ce.asset
+	if asset == nil {
+		return errAssetNotFound
+	}
+
+	c, err := collector.NewDeviceCollectorWithStore(w.repository, model.AppKindOutOfBand, w.cfg, w.breaker, w.logger)
 	if err != nil {
 		return errors.Wrap(errCollector, err.Error())
 	}
 
-	return c.CollectOutofband(ctx, asset, false)
+	err = c.CollectOutofband(ctx, asset, false)
+
+	if ctx.Err() == nil {
+		w.adaptive.Done(asset)
+	}
+
+	return err
+}
+
+// facilityClosed returns true while the facility is outside its maintenance windows,
+// the pause and resume of the worker are logged as the window closes, opens.
+func (w *Worker) facilityClosed() bool {
+	open, next, entry := w.windows.FacilityOpen(w.facilityCode, time.Now())
+
+	switch {
+	case !open && !w.paused:
+		w.paused = true
+		metrics.MaintenanceWindowClosed.Set(1)
+		metrics.CollectionsDeferred.With(
+			prometheus.Labels{"stage": "worker", "reason": collector.DeferReasonFacilityWindow},
+		).Inc()
+
+		w.logger.WithFields(logrus.Fields{
+			"facility": w.facilityCode,
+			"window":   entry,
+			"next":     collector.FormatWindowTime(next),
+		}).Info("conditions paused, facility outside its maintenance windows")
+
+	case open && w.paused:
+		w.paused = false
+		metrics.MaintenanceWindowClosed.Set(0)
+
+		w.logger.WithField("facility", w.facilityCode).Info("conditions resumed, facility maintenance window open")
+	}
+
+	return !open
+}
+
+// deferOutsideWindow defers the condition when the asset is outside its maintenance windows,
+// returns true when the condition was deferred.
+//
+// The condition is nak'ed to be redelivered once the window opens, when no window opens or the condition
+// is on its last delivery it is acked with a failed status instead, for the orchestrator to queue it again.
+// Conditions for which the asset was not found are left to be failed by the task.
+func (w *Worker) deferOutsideWindow(ctx context.Context, condition *rctypes.Condition, ce *conditionEvent) bool {
+	if w.windows == nil || ce.asset == nil {
+		return false
+	}
+
+	task, err := newTaskFromCondition(condition)
+	if err != nil {
+		return false
+	}
+
+	e, asset := ce.msg, ce.asset
+
+	open, next, entry := w.windows.AssetOpen(asset, time.Now())
+	if open {
+		return false
+	}
+
+	metrics.CollectionsDeferred.With(
+		prometheus.Labels{"stage": "worker", "reason": collector.DeferReasonAssetWindow},
+	).Inc()
+
+	le := w.logger.WithFields(logrus.Fields{
+		"conditionID": condition.ID.String(),
+		"assetID":     asset.ID,
+		"window":      entry,
+		"next":        collector.FormatWindowTime(next),
+	})
+
+	delay := collector.DeferDelay(next, time.Now(), deferMinDelay)
+	if delay == 0 || eventLastDelivery(e, w.maxDeliver) {
+		task.SetState(rctypes.Failed)
+		task.Status = "deferred, asset outside its maintenance windows until " + collector.FormatWindowTime(next)
+
+		if publisher, err := newStatusKVPublisher(w.stream, w.logger, w.id.String(), w.facilityCode, w.replicaCount); err != nil {
+			le.WithError(err).Warn("status KV init - internal error")
+		} else {
+			publisher.Publish(ctx, task)
+		}
+
+		w.eventAckComplete(e)
+
+		metrics.RegisterEventCounter(false, "ack")
+		le.Info("condition failed, asset outside its maintenance windows beyond the condition redeliveries")
+
+		return true
+	}
+
+	w.eventNakWithDelay(e, delay)
+
+	le.WithField("delay", delay.String()).Info("condition deferred, asset outside its maintenance windows")
+
+	return true
+}
+
+// newConditionEvent returns the condition event for the message, the asset of an out of band condition
+// is looked up in the store without its BMC credentials, which are looked up by the collector.
+//
+// Conditions that cannot be deserialized are left to be acked by processSingleEvent.
+func (w *Worker) newConditionEvent(ctx context.Context, msg events.Message) *conditionEvent {
+	ce := &conditionEvent{msg: msg, inProgressAt: time.Now()}
+
+	if w.appKind != model.AppKindOutOfBand {
+		return ce
+	}
+
+	condition, err := conditionFromEvent(msg)
+	if err != nil {
+		return ce
+	}
+
+	task, err := newTaskFromCondition(condition)
+	if err != nil || task.Parameters.Method != rctypes.OutofbandInventory {
+		return ce
+	}
+
+	ce.asset, ce.assetErr = w.repository.AssetByID(ctx, task.Parameters.AssetID.String(), false)
+
+	return ce
+}
+
+// acquireBMCLimit counts the condition in flight for the BMC subnet, rack of the condition asset,
+// the count is released once the condition is processed. The subnet or rack at the limit is returned otherwise.
+//
+// Conditions for which the asset was not found are not limited.
+func (w *Worker) acquireBMCLimit(ce *conditionEvent) (limited string) {
+	if w.limiter == nil || ce.asset == nil {
+		return ""
+	}
+
+	ce.limit, limited = w.limiter.TryAcquire(ce.asset)
+
+	return limited
 }
@@ -0,0 +1,287 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	rctypes "github.com/metal-toolbox/rivets/v2/condition"
+	"github.com/metal-toolbox/rivets/v2/events"
+	"github.com/metal-toolbox/rivets/v2/events/registry"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/metal-toolbox/alloy/internal/app"
+	"github.com/metal-toolbox/alloy/internal/collector"
+	"github.com/metal-toolbox/alloy/internal/model"
+	"github.com/metal-toolbox/alloy/internal/store"
+)
+
+// assetStore is a store.Repository returning the assets by ID.
+type assetStore struct {
+	store.Repository
+	assets map[string]*model.Asset
+}
+
+func (s *assetStore) AssetByID(_ context.Context, assetID string, _ bool) (*model.Asset, error) {
+	asset, exists := s.assets[assetID]
+	if !exists {
+		return nil, errors.New("no rows in result set")
+	}
+
+	return asset, nil
+}
+
+func newTestCondition(t *testing.T, method rctypes.InventoryMethod, assetID uuid.UUID) *rctypes.Condition {
+	t.Helper()
+
+	parameters, err := json.Marshal(&rctypes.InventoryTaskParameters{Method: method, AssetID: assetID})
+	require.Nil(t, err)
+
+	return &rctypes.Condition{ID: uuid.New(), Kind: rctypes.Inventory, Parameters: parameters}
+}
+
+func Test_conditionForWorker(t *testing.T) {
+	host := uuid.New()
+	other := uuid.New()
+
+	testcases := []struct {
+		name      string
+		appKind   model.AppKind
+		method    rctypes.InventoryMethod
+		assetID   uuid.UUID
+		expectErr error
+	}{
+		{"inband, this host", model.AppKindInband, rctypes.InbandInventory, host, nil},
+		{"inband, another host", model.AppKindInband, rctypes.InbandInventory, other, errAssetMismatch},
+		{"inband worker, outofband condition", model.AppKindInband, rctypes.OutofbandInventory, host, errMethodMismatch},
+		{"outofband, any asset", model.AppKindOutOfBand, rctypes.OutofbandInventory, other, nil},
+		{"outofband worker, inband condition", model.AppKindOutOfBand, rctypes.InbandInventory, other, errMethodMismatch},
+		{"invalid method left to the task", model.AppKindOutOfBand, rctypes.InventoryMethod("foo"), other, nil},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &Worker{appKind: tc.appKind, assetID: host.String(), logger: logrus.New()}
+
+			err := w.conditionForWorker(newTestCondition(t, tc.method, tc.assetID))
+			if tc.expectErr == nil {
+				assert.Nil(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, tc.expectErr)
+		})
+	}
+}
+
+func Test_processSingleEvent_NotForWorker(t *testing.T) {
+	w := &Worker{
+		id:      registry.GetID("test"),
+		appKind: model.AppKindInband,
+		assetID: uuid.New().String(),
+		logger:  logrus.New(),
+	}
+
+	data, err := json.Marshal(newTestCondition(t, rctypes.InbandInventory, uuid.New()))
+	require.Nil(t, err)
+
+	// the condition is nak'ed for the host it is intended for, and not acked as failed.
+	msg := events.NewMockMessage(t)
+	msg.EXPECT().ExtractOtelTraceContext(mock.Anything).Return(context.TODO())
+	msg.EXPECT().Data().Return(data)
+	msg.EXPECT().Nak().Return(nil).Once()
+
+	w.processSingleEvent(context.TODO(), &conditionEvent{msg: msg})
+}
+
+func Test_fetchEvents_FacilityWindow(t *testing.T) {
+	// the window opens daily in two hours, for an hour.
+	start := time.Now().UTC().Add(2 * time.Hour)
+
+	testcases := []struct {
+		name   string
+		window app.WindowOptions
+		pulled bool
+	}{
+		{"closed", app.WindowOptions{Start: fmt.Sprintf("%d %d * * *", start.Minute(), start.Hour()), Duration: time.Hour}, false},
+		{"open", app.WindowOptions{Start: "* * * * *", Duration: time.Minute}, true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			windows, err := collector.NewMaintenanceWindows([]app.MaintenanceWindowOptions{
+				{Facility: "dc13", Windows: []app.WindowOptions{tc.window}},
+			})
+			require.Nil(t, err)
+
+			// conditions are not pulled from the stream while the facility window is closed.
+			stream := events.NewMockStream(t)
+			if tc.pulled {
+				stream.EXPECT().PullMsg(mock.Anything, 1).Return(nil, nats.ErrTimeout).Once()
+			}
+
+			w := &Worker{
+				facilityCode: "dc13",
+				windows:      windows,
+				stream:       stream,
+				concurrency:  1,
+				logger:       logrus.New(),
+			}
+
+			w.fetchEvents(context.TODO())
+			assert.Equal(t, !tc.pulled, w.paused)
+		})
+	}
+}
+
+func Test_deferOutsideWindow(t *testing.T) {
+	// the window opens daily in two hours, for an hour.
+	start := time.Now().UTC().Add(2 * time.Hour)
+
+	windows, err := collector.NewMaintenanceWindows([]app.MaintenanceWindowOptions{
+		{Vendor: "dell", Windows: []app.WindowOptions{{Start: fmt.Sprintf("%d %d * * *", start.Minute(), start.Hour()), Duration: time.Hour}}},
+		{Vendor: "hpe", Windows: []app.WindowOptions{{Start: "* * * * *", Duration: time.Minute}}},
+		// february 30th, the window never opens.
+		{Vendor: "supermicro", Windows: []app.WindowOptions{{Start: "0 0 30 2 *", Duration: time.Hour}}},
+	})
+	require.Nil(t, err)
+
+	testcases := []struct {
+		name     string
+		vendor   string
+		deferred bool
+		acked    bool
+	}{
+		{"window closed, nak'ed until the window opens", "dell", true, false},
+		{"window open", "hpe", false, false},
+		{"no window matching", "asrockrack", false, false},
+		{"no window opening, acked", "supermicro", true, true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assetID := uuid.New()
+
+			w := &Worker{
+				id:      registry.GetID("test"),
+				appKind: model.AppKindOutOfBand,
+				windows: windows,
+				logger:  logrus.New(),
+			}
+
+			// the mock message is not a NATS message, and so is nak'ed without the delay.
+			msg := events.NewMockMessage(t)
+
+			switch {
+			case tc.acked:
+				msg.EXPECT().Ack().Return(nil).Once()
+			case tc.deferred:
+				msg.EXPECT().Nak().Return(nil).Once()
+			}
+
+			ce := &conditionEvent{msg: msg, asset: &model.Asset{ID: assetID.String(), Vendor: tc.vendor}}
+
+			deferred := w.deferOutsideWindow(context.TODO(), newTestCondition(t, rctypes.OutofbandInventory, assetID), ce)
+			assert.Equal(t, tc.deferred, deferred)
+		})
+	}
+}
+
+func Test_lastDelivery(t *testing.T) {
+	testcases := []struct {
+		name         string
+		numDelivered uint64
+		maxDeliver   int
+		expected     bool
+	}{
+		{"redelivered", 4, 5, false},
+		{"last delivery", 5, 5, true},
+		{"last delivery of the configured max deliveries", 10, 10, true},
+		{"below the configured max deliveries", 5, 10, false},
+		{"unlimited deliveries", 100, -1, false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, lastDelivery(tc.numDelivered, tc.maxDeliver))
+		})
+	}
+}
+
+func Test_consumerMaxDeliver(t *testing.T) {
+	// the consumer configuration is not looked up on a stream that is not a NATS JetStream.
+	w := &Worker{
+		stream: events.NewMockStream(t),
+		cfg:    &app.Configuration{NatsOptions: &events.NatsOptions{}},
+		logger: logrus.New(),
+	}
+
+	assert.Equal(t, defaultConditionMaxDeliver, w.consumerMaxDeliver())
+}
+
+func Test_fetchEvents_BMCLimit(t *testing.T) {
+	limiter, err := collector.NewBMCLimiter(app.BMCLimitsOptions{
+		Subnets: []app.SubnetLimitOptions{{CIDR: "10.1.2.0/24", Limit: 1}},
+	})
+	require.Nil(t, err)
+
+	// a condition for the subnet is in flight.
+	inflight, _ := limiter.TryAcquire(&model.Asset{ID: "inflight", BMCAddress: &model.BMCAddress{Host: "10.1.2.10"}})
+	require.NotNil(t, inflight)
+
+	assetID := uuid.New()
+
+	data, err := json.Marshal(newTestCondition(t, rctypes.OutofbandInventory, assetID))
+	require.Nil(t, err)
+
+	msg := events.NewMockMessage(t)
+	msg.EXPECT().Data().Return(data)
+
+	stream := events.NewMockStream(t)
+	stream.EXPECT().PullMsg(mock.Anything, 1).Return([]events.Message{msg}, nil).Once()
+
+	w := &Worker{
+		appKind: model.AppKindOutOfBand,
+		limiter: limiter,
+		repository: &assetStore{assets: map[string]*model.Asset{
+			assetID.String(): {ID: assetID.String(), BMCAddress: &model.BMCAddress{Host: "10.1.2.11"}},
+		}},
+		stream:      stream,
+		syncWG:      &sync.WaitGroup{},
+		concurrency: 2,
+		logger:      logrus.New(),
+	}
+
+	// the condition is held in flight, and not nak'ed.
+	w.fetchEvents(context.TODO())
+	require.Len(t, w.held, 1)
+	assert.Equal(t, int32(0), w.dispatched)
+
+	// the held condition is acked as in progress before the stream redelivers it.
+	msg.EXPECT().InProgress().Return(nil).Once()
+
+	w.held[0].inProgressAt = time.Now().Add(-taskInprogressTick)
+	w.dispatchHeld(context.TODO(), false)
+	require.Len(t, w.held, 1)
+
+	// the held condition is not dispatched while the facility is outside its windows, with the subnet below the limit.
+	limiter.Release(inflight)
+
+	w.dispatchHeld(context.TODO(), true)
+	require.Len(t, w.held, 1)
+
+	// the held conditions are returned to the stream once the worker stops.
+	msg.EXPECT().Nak().Return(nil).Once()
+
+	w.returnHeld()
+	assert.Len(t, w.held, 0)
+}